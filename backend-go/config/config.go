@@ -1,21 +1,51 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
+	// 运行环境: development / production / test，决定未设置环境变量时使用的默认值
+	Env string
+
 	Server struct {
-		Host string
-		Port string
+		Host           string
+		Port           string
+		RequestTimeout time.Duration
+		RouteTimeouts  map[string]time.Duration
+		// 包含这些片段的路径不设置超时。超时中间件会缓存整个响应，处理函数调用 Flush 之前客户端收不到任何内容，
+		// Flush 之后已无法再返回 504，超时只会取消请求的 context；长时间边生成边输出的接口（SSE、下载、导出）应加入该列表
+		TimeoutExcludePaths []string
+		// 运行模式: server 只提供 HTTP 接口，worker 只处理队列任务，all 两者都运行
+		Mode string
+		// 允许跨域访问的前端地址，* 表示允许所有来源
+		CORSOrigins []string
+		// Gin 的运行模式: debug 打印路由注册等调试日志，release / test 不打印
+		GinMode string
+	}
+
+	// 响应 gzip 压缩，响应体不足 MinSize 字节时不压缩
+	Compression struct {
+		Enabled bool
+		MinSize int
 	}
 
 	Database struct {
-		Driver string
-		DSN    string
+		Driver   string
+		DSN      string
+		LogLevel string // silent / error / warn / info
+		// 启动时自动迁移表结构，关闭时通过 POST /api/admin/migrate 手动执行
+		AutoMigrate bool
 	}
 
 	Redis struct {
@@ -26,15 +56,242 @@ type Config struct {
 	}
 
 	ChromaDB struct {
-		Host string
-		Port string
+		Host           string
+		Port           string
+		Collection     string
+		DistanceMetric string
+		// 完整地址，设置了 CHROMA_URL 时使用它，否则由 Host 和 Port 拼接为 http 地址
+		URL string
+		// https 时跳过证书校验，用于自签名证书
+		TLSSkipVerify bool
+		// 按文件元数据中 RouteKey 字段的值选择集合，Routes 为取值到集合名的映射，未匹配时使用 Collection
+		RouteKey string
+		Routes   map[string]string
+		// 创建集合时额外写入的元数据，系统字段（距离度量、模型、维度、结构版本）不能覆盖
+		CollectionMetadata map[string]string
+		// 单次请求的超时时间
+		Timeout time.Duration
+		// 所有请求共用一个连接池: 每个地址保留的空闲连接数、最大连接数（0 表示不限制）、空闲连接的关闭时间
+		MaxIdleConnsPerHost int
+		MaxConnsPerHost     int
+		IdleConnTimeout     time.Duration
+		KeepAlive           bool // 关闭后每个请求都新建连接，用于排查代理或负载均衡的连接问题
+		// 集合中的向量数量达到该值后，新处理的文件写入下一个分片集合（集合名加 _1、_2 后缀），0 表示不分片
+		ShardMaxVectors int
+		// 写入向量时集合不存在则按配置创建后重试一次，关闭时直接失败
+		AutoCreateCollection bool
 	}
 
 	Upload struct {
-		Dir      string
-		MaxSize  int64
-		AllowExt []string
+		Dir string
+		// 上传目录不存在时是否自动创建；目录由挂载的存储卷提供时可关闭，挂载失败时拒绝启动而不是写入容器内的空目录
+		CreateDir     bool
+		TempDir       string // 上传、解压过程中的临时文件，完成后才移动到 Dir
+		MaxSize       int64
+		AllowExt      []string
+		MaxConcurrent int
+		QueueTimeout  time.Duration
+		// 解析 multipart 表单时每个请求最多在内存中缓存的字节数，超出部分写入临时文件
+		MaxMultipartMemory int64
+		// 保存的原始文件名的最大字节数，超出时截断文件名主体、保留扩展名
+		FilenameMaxBytes int
+
+		// ZIP 压缩包解压限制
+		ArchiveMaxEntries      int
+		ArchiveMaxUncompressed int64
+		ArchiveMaxRatio        int
+	}
+
+	Processing struct {
+		ChunkSize     int
+		ChunkOverlap  int
+		ChunkStrategy string
+		ExtractTables bool
+		// 读取 PDF 文档信息中的标题、作者、创建时间
+		ExtractMetadata bool
+		// PDF 文本提取方式: simple 按行从左到右，columns 识别多栏排版后按阅读顺序
+		LayoutMode     string
+		DedupEnabled   bool
+		DedupThreshold float64
+		// 删除在大量页面相同位置重复出现的页眉页脚行，HeaderFooterMinRatio 为重复页数占总页数的最小比例
+		StripHeaderFooter    bool
+		HeaderFooterMinRatio float64
+		EmbeddingBatchSize   int
+		MaxPages             int
+		// 单个文档允许的最大块数，0 表示不限制；超过时 MaxChunksAction 为 reject 拒绝处理，truncate 只保留前 MaxChunks 个块
+		MaxChunks       int
+		MaxChunksAction string
+		// 没有提取到任何文本的文档: error 标记为失败，completed_empty 标记为 completed_empty 状态
+		EmptyTextAction string
+		// 同步处理: 不超过 SyncMaxSize 的文件可在请求中直接处理，超过 SyncTimeout 时改为异步
+		SyncMaxSize int64
+		SyncTimeout time.Duration
+		SyncAuto    bool // 未指定 sync 参数时小文件自动同步处理
+		// 向量存储方式，压测时可跳过存储（noop）或写入临时集合（temp），避免污染正式集合
+		StoreMode      string
+		TempCollection string
+		// 存储完成后核对向量库中该文件的向量数量，少于成功向量化的块数时任务失败
+		VerifyVectors bool
+		// 保存块文本时每条 INSERT 语句写入的块数
+		ChunkInsertBatchSize int
+	}
+
+	Embedding struct {
+		BaseURL          string
+		APIKey           string
+		Model            string
+		MaxRequestTokens int
+		MaxChunkTokens   int
+		PricePer1KTokens float64
+		Dimension        int
+		ProbeInterval    time.Duration
+		// 单次向量化请求的超时时间，大批量向量化通常比 ChromaDB 请求慢，单独配置
+		Timeout time.Duration
+
+		// 备用向量化服务，主服务连续失败 FailoverAttempts 次后改用备用服务，BaseURL 为空时不启用
+		FallbackBaseURL  string
+		FallbackAPIKey   string
+		FallbackModel    string
+		FailoverAttempts int
+
+		// 熔断: BreakerWindow 内连续失败 BreakerThreshold 次后，BreakerCooldown 内的请求直接失败，0 表示不熔断
+		BreakerThreshold int
+		BreakerWindow    time.Duration
+		BreakerCooldown  time.Duration
+
+		// 向量化前的文本预处理，默认关闭
+		NormalizeUnicode    bool
+		StripControlChars   bool
+		NormalizeWhitespace bool
+		Lowercase           bool
+	}
+
+	Stream struct {
+		PollInterval      time.Duration
+		HeartbeatInterval time.Duration
+		MaxDuration       time.Duration
+	}
+
+	Search struct {
+		// 请求未指定 top_k 时返回的结果数量，以及 top_k 的上限
+		DefaultTopK int
+		MaxTopK     int
+		// 查询文本的估算 token 上限，超过时返回 400，TruncateQuery 为 true 时截断后检索
+		MaxQueryTokens int
+		TruncateQuery  bool
+		SnippetLength  int
+		HighlightPre   string
+		HighlightPost  string
+		// 检索结果缓存的条目数，0 表示不缓存
+		CacheSize int
+		CacheTTL  time.Duration
+		// 可以通过 /api/metadata/values 查询取值的元数据字段
+		FacetKeys []string
+		// 请求中会转换为 Chroma where 条件的部分的上限: file_ids 的数量，author 和 filter 取值的字节数，0 表示不限制
+		MaxFileIDs     int
+		MaxFilterBytes int
 	}
+
+	// 文本向量化调试接口 POST /api/embed: 输入的估算 token 上限，每个密钥（未配置密钥时按客户端 IP）在 RateWindow 内最多请求 RateLimit 次
+	EmbedAPI struct {
+		MaxTokens  int
+		RateLimit  int
+		RateWindow time.Duration
+	}
+
+	// 统计接口中比率保留的小数位数
+	Stats struct {
+		RateDecimals int
+		// 吞吐量统计默认的时间窗口和分桶大小，请求中可通过 window、bucket 参数覆盖
+		ThroughputWindow     time.Duration
+		ThroughputBucket     time.Duration
+		ThroughputMaxBuckets int
+	}
+
+	// 文本块列表接口的分页大小
+	Chunks struct {
+		DefaultPageSize int
+		MaxPageSize     int
+	}
+
+	Log struct {
+		Output     string
+		File       string
+		MaxSizeMB  int
+		MaxBackups int
+		MaxAgeDays int
+		Compress   bool
+		// 访问日志中记录请求头、请求体和响应体，敏感字段会被脱敏
+		Bodies       bool
+		MaxBodyBytes int
+		RedactFields []string
+	}
+
+	Auth struct {
+		AdminAPIKey string
+		// API 密钥到租户 ID 的映射，配置后 /api 下的接口都需要携带密钥，每个租户只能访问自己的文件
+		TenantAPIKeys map[string]string
+	}
+
+	// 文件目录导入导出
+	Catalog struct {
+		// 导入时 ID 已存在的处理方式: skip 跳过 / regenerate 使用新的 ID
+		ImportOnConflict string
+	}
+
+	Lock struct {
+		TTL  time.Duration
+		Wait time.Duration
+	}
+
+	Queue struct {
+		// 按文件大小分级，大文件进入并发数更低的独立队列，为空时所有文件使用默认队列
+		SizeTiers []SizeTier
+		// 等待执行的任务数超过该值时拒绝批量处理，上传和单个文件处理的响应中附带提示，0 表示不限制
+		BackpressureThreshold int
+	}
+
+	// PDF 页面渲染为图片，通过 pdftoppm 完成
+	PageImage struct {
+		DPI      int
+		Format   string // png / jpeg
+		CacheDir string
+		Command  string
+		Timeout  time.Duration
+	}
+
+	Retention struct {
+		// 处理完成超过 Days 天的文件删除原始文件，0 表示不自动清理
+		Days          int
+		CheckInterval time.Duration
+		// 只记录将被清理的文件，不实际删除
+		DryRun bool
+		// 同时删除向量和块文本，默认只删除原始文件
+		DeleteVectors bool
+	}
+
+	// 处理失败的邮件告警，SMTPHost 和 To 都设置时才启用
+	Alert struct {
+		SMTPHost     string
+		SMTPPort     string
+		SMTPUsername string
+		SMTPPassword string
+		From         string
+		To           []string
+		// 文件的失败次数达到该值时告警，0 表示不告警
+		ErrorCountThreshold int
+		// 重试耗尽的任务总数超过该值时告警，0 表示不告警
+		FailedTasksThreshold int
+		// 告警合并后按该间隔发送，避免短时间内大量邮件
+		BatchInterval time.Duration
+	}
+}
+
+// SizeTier 文件大小分级: 不小于 MinSize 的文件进入名为 size_<Name> 的队列，最多同时处理 Concurrency 个
+type SizeTier struct {
+	Name        string
+	MinSize     int64
+	Concurrency int
 }
 
 var AppConfig *Config
@@ -45,20 +302,56 @@ func InitConfig() {
 		log.Printf("未找到 .env 文件，使用环境变量: %v", err)
 	}
 
+	env := getEnv("APP_ENV", "development")
+	envProfile, ok := profiles[env]
+	if !ok {
+		log.Fatalf("不支持的 APP_ENV: %s，可选 development / production / test", env)
+	}
+	log.Printf("运行环境: %s", env)
+
+	chromaURL, err := chromaBaseURL(getEnv("CHROMA_URL", ""), getEnv("CHROMA_HOST", "localhost"), getEnv("CHROMA_PORT", "8000"))
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
 	AppConfig = &Config{
+		Env: env,
 		Server: struct {
-			Host string
-			Port string
+			Host                string
+			Port                string
+			RequestTimeout      time.Duration
+			RouteTimeouts       map[string]time.Duration
+			TimeoutExcludePaths []string
+			Mode                string
+			CORSOrigins         []string
+			GinMode             string
+		}{
+			Host:                getEnv("HOST", "0.0.0.0"),
+			Port:                getEnv("PORT", "8080"),
+			RequestTimeout:      getEnvDuration("REQUEST_TIMEOUT", 30*time.Second),
+			RouteTimeouts:       getEnvDurationMap("ROUTE_TIMEOUTS", "/api/upload-files=10m,/api/admin/import=10m"),
+			TimeoutExcludePaths: getEnvList("TIMEOUT_EXCLUDE_PATHS", "/stream,/download,/ws/,/api/admin/export"),
+			Mode:                getEnv("APP_MODE", "all"),
+			CORSOrigins:         getEnvList("CORS_ALLOWED_ORIGINS", envProfile.CORSOrigins),
+			GinMode:             getEnv("GIN_MODE", envProfile.GinMode),
+		},
+		Compression: struct {
+			Enabled bool
+			MinSize int
 		}{
-			Host: getEnv("HOST", "0.0.0.0"),
-			Port: getEnv("PORT", "8080"),
+			Enabled: getEnvBool("GZIP_ENABLED", true),
+			MinSize: getEnvInt("GZIP_MIN_SIZE", 1024),
 		},
 		Database: struct {
-			Driver string
-			DSN    string
+			Driver      string
+			DSN         string
+			LogLevel    string
+			AutoMigrate bool
 		}{
-			Driver: getEnv("DATABASE_DRIVER", "sqlite"),
-			DSN:    getEnv("DATABASE_URL", "./data.db"),
+			Driver:      getEnv("DATABASE_DRIVER", "sqlite"),
+			DSN:         getEnv("DATABASE_URL", "./data.db"),
+			LogLevel:    getEnv("DB_LOG_LEVEL", envProfile.DBLogLevel),
+			AutoMigrate: getEnvBool("DB_AUTO_MIGRATE", envProfile.AutoMigrate),
 		},
 		Redis: struct {
 			Host     string
@@ -72,29 +365,552 @@ func InitConfig() {
 			DB:       0,
 		},
 		ChromaDB: struct {
-			Host string
-			Port string
+			Host                 string
+			Port                 string
+			Collection           string
+			DistanceMetric       string
+			URL                  string
+			TLSSkipVerify        bool
+			RouteKey             string
+			Routes               map[string]string
+			CollectionMetadata   map[string]string
+			Timeout              time.Duration
+			MaxIdleConnsPerHost  int
+			MaxConnsPerHost      int
+			IdleConnTimeout      time.Duration
+			KeepAlive            bool
+			ShardMaxVectors      int
+			AutoCreateCollection bool
 		}{
-			Host: getEnv("CHROMA_HOST", "localhost"),
-			Port: getEnv("CHROMA_PORT", "8000"),
+			Host:                 getEnv("CHROMA_HOST", "localhost"),
+			Port:                 getEnv("CHROMA_PORT", "8000"),
+			Collection:           getEnv("CHROMA_COLLECTION", "documents"),
+			DistanceMetric:       getEnv("CHROMA_DISTANCE", "l2"),
+			URL:                  chromaURL,
+			TLSSkipVerify:        getEnvBool("CHROMA_TLS_SKIP_VERIFY", false),
+			RouteKey:             getEnv("CHROMA_ROUTE_KEY", ""),
+			Routes:               getEnvMap("CHROMA_COLLECTION_ROUTES", ""),
+			CollectionMetadata:   getEnvMap("CHROMA_COLLECTION_METADATA", ""),
+			Timeout:              getEnvDuration("CHROMA_TIMEOUT", 30*time.Second),
+			MaxIdleConnsPerHost:  getEnvInt("CHROMA_MAX_IDLE_CONNS_PER_HOST", 32),
+			MaxConnsPerHost:      getEnvInt("CHROMA_MAX_CONNS_PER_HOST", 0),
+			IdleConnTimeout:      getEnvDuration("CHROMA_IDLE_CONN_TIMEOUT", 90*time.Second),
+			KeepAlive:            getEnvBool("CHROMA_KEEP_ALIVE", true),
+			ShardMaxVectors:      getEnvInt("CHROMA_SHARD_MAX_VECTORS", 0),
+			AutoCreateCollection: getEnvBool("CHROMA_AUTO_CREATE_COLLECTION", true),
 		},
 		Upload: struct {
-			Dir      string
-			MaxSize  int64
-			AllowExt []string
+			Dir                string
+			CreateDir          bool
+			TempDir            string
+			MaxSize            int64
+			AllowExt           []string
+			MaxConcurrent      int
+			QueueTimeout       time.Duration
+			MaxMultipartMemory int64
+			FilenameMaxBytes   int
+
+			ArchiveMaxEntries      int
+			ArchiveMaxUncompressed int64
+			ArchiveMaxRatio        int
+		}{
+			Dir:                getEnv("UPLOAD_DIR", "./uploads"),
+			CreateDir:          getEnvBool("UPLOAD_CREATE_DIR", true),
+			TempDir:            getEnv("TEMP_DIR", filepath.Join(os.TempDir(), "doc-analysis")),
+			MaxSize:            100 * 1024 * 1024, // 100MB
+			AllowExt:           []string{".pdf", ".zip"},
+			MaxConcurrent:      getEnvInt("UPLOAD_MAX_CONCURRENT", 4),
+			QueueTimeout:       getEnvDuration("UPLOAD_QUEUE_TIMEOUT", 5*time.Second),
+			MaxMultipartMemory: int64(getEnvInt("UPLOAD_MAX_MEMORY_MB", 32)) * 1024 * 1024,
+			FilenameMaxBytes:   getEnvInt("UPLOAD_FILENAME_MAX_BYTES", 255),
+
+			ArchiveMaxEntries:      getEnvInt("ARCHIVE_MAX_ENTRIES", 500),
+			ArchiveMaxUncompressed: int64(getEnvInt("ARCHIVE_MAX_UNCOMPRESSED_MB", 1024)) * 1024 * 1024,
+			ArchiveMaxRatio:        getEnvInt("ARCHIVE_MAX_RATIO", 100),
+		},
+		Processing: struct {
+			ChunkSize            int
+			ChunkOverlap         int
+			ChunkStrategy        string
+			ExtractTables        bool
+			ExtractMetadata      bool
+			LayoutMode           string
+			DedupEnabled         bool
+			DedupThreshold       float64
+			StripHeaderFooter    bool
+			HeaderFooterMinRatio float64
+			EmbeddingBatchSize   int
+			MaxPages             int
+			MaxChunks            int
+			MaxChunksAction      string
+			EmptyTextAction      string
+			SyncMaxSize          int64
+			SyncTimeout          time.Duration
+			SyncAuto             bool
+			StoreMode            string
+			TempCollection       string
+			VerifyVectors        bool
+			ChunkInsertBatchSize int
+		}{
+			ChunkSize:            getEnvInt("CHUNK_SIZE", 1000),
+			ChunkOverlap:         getEnvInt("CHUNK_OVERLAP", 100),
+			ChunkStrategy:        getEnv("CHUNK_STRATEGY", "fixed"),
+			ExtractTables:        getEnvBool("PDF_EXTRACT_TABLES", false),
+			ExtractMetadata:      getEnvBool("PDF_EXTRACT_METADATA", true),
+			LayoutMode:           getEnv("PDF_LAYOUT_MODE", "simple"),
+			DedupEnabled:         getEnvBool("DEDUP_ENABLED", false),
+			DedupThreshold:       getEnvFloat("DEDUP_THRESHOLD", 0.95),
+			StripHeaderFooter:    getEnvBool("STRIP_HEADER_FOOTER", false),
+			HeaderFooterMinRatio: getEnvFloat("HEADER_FOOTER_MIN_RATIO", 0.5),
+			EmbeddingBatchSize:   getEnvInt("EMBEDDING_BATCH_SIZE", 100),
+			MaxPages:             getEnvInt("MAX_PAGES", 0),
+			MaxChunks:            getEnvInt("MAX_CHUNKS", 0),
+			MaxChunksAction:      getEnv("MAX_CHUNKS_ACTION", "reject"),
+			EmptyTextAction:      getEnv("EMPTY_TEXT_ACTION", "error"),
+			SyncMaxSize:          int64(getEnvInt("SYNC_PROCESS_MAX_KB", 512)) * 1024,
+			SyncTimeout:          getEnvDuration("SYNC_PROCESS_TIMEOUT", 20*time.Second),
+			SyncAuto:             getEnvBool("SYNC_PROCESS_AUTO", false),
+			StoreMode:            getEnv("PROCESSING_STORE_MODE", "chroma"),
+			TempCollection:       getEnv("PROCESSING_TEMP_COLLECTION", "loadtest"),
+			VerifyVectors:        getEnvBool("VERIFY_STORED_VECTORS", true),
+			ChunkInsertBatchSize: getEnvInt("CHUNK_INSERT_BATCH_SIZE", 500),
+		},
+		Embedding: struct {
+			BaseURL          string
+			APIKey           string
+			Model            string
+			MaxRequestTokens int
+			MaxChunkTokens   int
+			PricePer1KTokens float64
+			Dimension        int
+			ProbeInterval    time.Duration
+			Timeout          time.Duration
+			FallbackBaseURL  string
+			FallbackAPIKey   string
+			FallbackModel    string
+			FailoverAttempts int
+			BreakerThreshold int
+			BreakerWindow    time.Duration
+			BreakerCooldown  time.Duration
+
+			NormalizeUnicode    bool
+			StripControlChars   bool
+			NormalizeWhitespace bool
+			Lowercase           bool
+		}{
+			BaseURL:          getEnv("EMBEDDING_BASE_URL", "http://localhost:11434/v1"),
+			APIKey:           getEnv("EMBEDDING_API_KEY", ""),
+			Model:            getEnv("EMBEDDING_MODEL", "nomic-embed-text"),
+			MaxRequestTokens: getEnvInt("EMBEDDING_MAX_REQUEST_TOKENS", 8000),
+			MaxChunkTokens:   getEnvInt("EMBEDDING_MAX_CHUNK_TOKENS", 2000),
+			PricePer1KTokens: getEnvFloat("EMBEDDING_PRICE_PER_1K_TOKENS", 0),
+			Dimension:        getEnvInt("EMBEDDING_DIMENSION", 0),
+			ProbeInterval:    getEnvDuration("EMBEDDING_PROBE_INTERVAL", 30*time.Second),
+			Timeout:          getEnvDuration("EMBEDDING_TIMEOUT", 60*time.Second),
+			FallbackBaseURL:  getEnv("EMBEDDING_FALLBACK_BASE_URL", ""),
+			FallbackAPIKey:   getEnv("EMBEDDING_FALLBACK_API_KEY", ""),
+			FallbackModel:    getEnv("EMBEDDING_FALLBACK_MODEL", getEnv("EMBEDDING_MODEL", "nomic-embed-text")),
+			FailoverAttempts: getEnvInt("EMBEDDING_FAILOVER_ATTEMPTS", 2),
+			BreakerThreshold: getEnvInt("EMBEDDING_BREAKER_THRESHOLD", 0),
+			BreakerWindow:    getEnvDuration("EMBEDDING_BREAKER_WINDOW", time.Minute),
+			BreakerCooldown:  getEnvDuration("EMBEDDING_BREAKER_COOLDOWN", 30*time.Second),
+
+			NormalizeUnicode:    getEnvBool("EMBEDDING_NORMALIZE_UNICODE", false),
+			StripControlChars:   getEnvBool("EMBEDDING_STRIP_CONTROL_CHARS", false),
+			NormalizeWhitespace: getEnvBool("EMBEDDING_NORMALIZE_WHITESPACE", false),
+			Lowercase:           getEnvBool("EMBEDDING_LOWERCASE", false),
+		},
+		Stream: struct {
+			PollInterval      time.Duration
+			HeartbeatInterval time.Duration
+			MaxDuration       time.Duration
+		}{
+			PollInterval:      getEnvDuration("STREAM_POLL_INTERVAL", 2*time.Second),
+			HeartbeatInterval: getEnvDuration("STREAM_HEARTBEAT_INTERVAL", 30*time.Second),
+			MaxDuration:       getEnvDuration("STREAM_MAX_DURATION", 10*time.Minute),
+		},
+		Search: struct {
+			DefaultTopK    int
+			MaxTopK        int
+			MaxQueryTokens int
+			TruncateQuery  bool
+			SnippetLength  int
+			HighlightPre   string
+			HighlightPost  string
+			CacheSize      int
+			CacheTTL       time.Duration
+			FacetKeys      []string
+			MaxFileIDs     int
+			MaxFilterBytes int
+		}{
+			DefaultTopK:    getEnvInt("SEARCH_DEFAULT_TOP_K", 10),
+			MaxTopK:        getEnvInt("SEARCH_MAX_TOP_K", 50),
+			MaxQueryTokens: getEnvInt("SEARCH_MAX_QUERY_TOKENS", 512),
+			TruncateQuery:  getEnvBool("SEARCH_TRUNCATE_QUERY", false),
+			SnippetLength:  getEnvInt("SEARCH_SNIPPET_LENGTH", 200),
+			HighlightPre:   getEnv("SEARCH_HIGHLIGHT_PRE", "<em>"),
+			HighlightPost:  getEnv("SEARCH_HIGHLIGHT_POST", "</em>"),
+			CacheSize:      getEnvInt("SEARCH_CACHE_SIZE", 500),
+			CacheTTL:       getEnvDuration("SEARCH_CACHE_TTL", 30*time.Second),
+			FacetKeys:      getEnvList("SEARCH_FACET_KEYS", "tags,language,category"),
+			MaxFileIDs:     getEnvInt("SEARCH_MAX_FILE_IDS", 1000),
+			MaxFilterBytes: getEnvInt("SEARCH_MAX_FILTER_BYTES", 256),
+		},
+		EmbedAPI: struct {
+			MaxTokens  int
+			RateLimit  int
+			RateWindow time.Duration
+		}{
+			MaxTokens:  getEnvInt("EMBED_API_MAX_TOKENS", 512),
+			RateLimit:  getEnvInt("EMBED_API_RATE_LIMIT", 30),
+			RateWindow: getEnvDuration("EMBED_API_RATE_WINDOW", time.Minute),
+		},
+		Stats: struct {
+			RateDecimals         int
+			ThroughputWindow     time.Duration
+			ThroughputBucket     time.Duration
+			ThroughputMaxBuckets int
+		}{
+			RateDecimals:         getEnvInt("STATS_RATE_DECIMALS", 2),
+			ThroughputWindow:     getEnvDuration("STATS_THROUGHPUT_WINDOW", 24*time.Hour),
+			ThroughputBucket:     getEnvDuration("STATS_THROUGHPUT_BUCKET", time.Hour),
+			ThroughputMaxBuckets: getEnvInt("STATS_THROUGHPUT_MAX_BUCKETS", 168),
+		},
+		Chunks: struct {
+			DefaultPageSize int
+			MaxPageSize     int
+		}{
+			DefaultPageSize: getEnvInt("CHUNKS_DEFAULT_PAGE_SIZE", 50),
+			MaxPageSize:     getEnvInt("CHUNKS_MAX_PAGE_SIZE", 200),
+		},
+		Log: struct {
+			Output       string
+			File         string
+			MaxSizeMB    int
+			MaxBackups   int
+			MaxAgeDays   int
+			Compress     bool
+			Bodies       bool
+			MaxBodyBytes int
+			RedactFields []string
+		}{
+			Output:       getEnv("LOG_OUTPUT", "stdout"),
+			File:         getEnv("LOG_FILE", "./logs/app.log"),
+			MaxSizeMB:    getEnvInt("LOG_MAX_SIZE_MB", 100),
+			MaxBackups:   getEnvInt("LOG_MAX_BACKUPS", 7),
+			MaxAgeDays:   getEnvInt("LOG_MAX_AGE_DAYS", 30),
+			Compress:     getEnvBool("LOG_COMPRESS", true),
+			Bodies:       getEnvBool("LOG_BODIES", false),
+			MaxBodyBytes: getEnvInt("LOG_MAX_BODY_BYTES", 4096),
+			RedactFields: getEnvList("LOG_REDACT_FIELDS", "password,api_key,apikey,token,access_token,secret,authorization,smtp_password"),
+		},
+		Auth: struct {
+			AdminAPIKey   string
+			TenantAPIKeys map[string]string
+		}{
+			AdminAPIKey:   getEnv("ADMIN_API_KEY", ""),
+			TenantAPIKeys: getEnvMap("TENANT_API_KEYS", ""),
+		},
+		Catalog: struct {
+			ImportOnConflict string
+		}{
+			ImportOnConflict: getEnv("CATALOG_IMPORT_ON_CONFLICT", "skip"),
+		},
+		Lock: struct {
+			TTL  time.Duration
+			Wait time.Duration
+		}{
+			TTL:  getEnvDuration("FILE_LOCK_TTL", 2*time.Minute),
+			Wait: getEnvDuration("FILE_LOCK_WAIT", 10*time.Second),
+		},
+		Queue: struct {
+			SizeTiers             []SizeTier
+			BackpressureThreshold int
+		}{
+			SizeTiers:             getEnvSizeTiers("QUEUE_SIZE_TIERS", ""),
+			BackpressureThreshold: getEnvInt("QUEUE_BACKPRESSURE_THRESHOLD", 0),
+		},
+		PageImage: struct {
+			DPI      int
+			Format   string
+			CacheDir string
+			Command  string
+			Timeout  time.Duration
+		}{
+			DPI:      getEnvInt("PAGE_IMAGE_DPI", 100),
+			Format:   getEnv("PAGE_IMAGE_FORMAT", "png"),
+			CacheDir: getEnv("PAGE_IMAGE_CACHE_DIR", "./page_images"),
+			Command:  getEnv("PDFTOPPM_PATH", "pdftoppm"),
+			Timeout:  getEnvDuration("PAGE_IMAGE_TIMEOUT", 30*time.Second),
+		},
+		Retention: struct {
+			Days          int
+			CheckInterval time.Duration
+			DryRun        bool
+			DeleteVectors bool
 		}{
-			Dir:      "./uploads",
-			MaxSize:  100 * 1024 * 1024, // 100MB
-			AllowExt: []string{".pdf"},
+			Days:          getEnvInt("RETENTION_DAYS", 0),
+			CheckInterval: getEnvDuration("RETENTION_CHECK_INTERVAL", time.Hour),
+			DryRun:        getEnvBool("RETENTION_DRY_RUN", false),
+			DeleteVectors: getEnvBool("RETENTION_DELETE_VECTORS", false),
 		},
+		Alert: struct {
+			SMTPHost             string
+			SMTPPort             string
+			SMTPUsername         string
+			SMTPPassword         string
+			From                 string
+			To                   []string
+			ErrorCountThreshold  int
+			FailedTasksThreshold int
+			BatchInterval        time.Duration
+		}{
+			SMTPHost:             getEnv("SMTP_HOST", ""),
+			SMTPPort:             getEnv("SMTP_PORT", "587"),
+			SMTPUsername:         getEnv("SMTP_USERNAME", ""),
+			SMTPPassword:         getEnv("SMTP_PASSWORD", ""),
+			From:                 getEnv("SMTP_FROM", ""),
+			To:                   getEnvList("ALERT_EMAIL_TO", ""),
+			ErrorCountThreshold:  getEnvInt("ALERT_ERROR_COUNT_THRESHOLD", 3),
+			FailedTasksThreshold: getEnvInt("ALERT_FAILED_TASKS_THRESHOLD", 50),
+			BatchInterval:        getEnvDuration("ALERT_BATCH_INTERVAL", 5*time.Minute),
+		},
+	}
+
+	if chroma := AppConfig.ChromaDB; len(chroma.Routes) > 0 && chroma.RouteKey == "" {
+		log.Fatalf("配置了 CHROMA_COLLECTION_ROUTES 时必须设置 CHROMA_ROUTE_KEY")
+	}
+	if AppConfig.Embedding.FallbackBaseURL != "" && AppConfig.Embedding.FailoverAttempts < 1 {
+		log.Fatalf("EMBEDDING_FAILOVER_ATTEMPTS 必须大于 0: %d", AppConfig.Embedding.FailoverAttempts)
+	}
+	if cfg := AppConfig.Embedding; cfg.BreakerThreshold < 0 || (cfg.BreakerThreshold > 0 && (cfg.BreakerWindow <= 0 || cfg.BreakerCooldown <= 0)) {
+		log.Fatalf("EMBEDDING_BREAKER_THRESHOLD 不能小于 0，开启熔断时 EMBEDDING_BREAKER_WINDOW 和 EMBEDDING_BREAKER_COOLDOWN 必须大于 0")
+	}
+	if AppConfig.ChromaDB.Timeout <= 0 || AppConfig.Embedding.Timeout <= 0 {
+		log.Fatalf("CHROMA_TIMEOUT 和 EMBEDDING_TIMEOUT 必须大于 0")
+	}
+	if cfg := AppConfig.Processing; cfg.MaxChunks < 0 || (cfg.MaxChunksAction != "reject" && cfg.MaxChunksAction != "truncate") {
+		log.Fatalf("MAX_CHUNKS 不能小于 0，MAX_CHUNKS_ACTION 只能是 reject 或 truncate")
+	}
+	if ratio := AppConfig.Processing.HeaderFooterMinRatio; ratio <= 0 || ratio > 1 {
+		log.Fatalf("HEADER_FOOTER_MIN_RATIO 必须在 0 到 1 之间")
+	}
+	if AppConfig.ChromaDB.ShardMaxVectors < 0 {
+		log.Fatalf("CHROMA_SHARD_MAX_VECTORS 不能小于 0")
+	}
+	if AppConfig.Queue.BackpressureThreshold < 0 {
+		log.Fatalf("QUEUE_BACKPRESSURE_THRESHOLD 不能小于 0")
+	}
+	if AppConfig.ChromaDB.MaxIdleConnsPerHost <= 0 || AppConfig.ChromaDB.MaxConnsPerHost < 0 {
+		log.Fatalf("CHROMA_MAX_IDLE_CONNS_PER_HOST 必须大于 0，CHROMA_MAX_CONNS_PER_HOST 不能小于 0")
+	}
+	if AppConfig.Processing.ChunkInsertBatchSize <= 0 {
+		log.Fatalf("CHUNK_INSERT_BATCH_SIZE 必须大于 0")
+	}
+	if mode := AppConfig.Processing.StoreMode; !IsValidStoreMode(mode) {
+		log.Fatalf("不支持的 PROCESSING_STORE_MODE: %s，可选 chroma / noop / temp", mode)
+	}
+	if mode := AppConfig.Processing.LayoutMode; mode != "simple" && mode != "columns" {
+		log.Fatalf("不支持的 PDF_LAYOUT_MODE: %s，可选 simple / columns", mode)
+	}
+	if mode := AppConfig.Server.GinMode; mode != "debug" && mode != "release" && mode != "test" {
+		log.Fatalf("不支持的 GIN_MODE: %s，可选 debug / release / test", mode)
+	}
+	if _, ok := AppConfig.Auth.TenantAPIKeys[""]; ok {
+		log.Fatalf("TENANT_API_KEYS 中的密钥不能为空")
+	}
+	if _, ok := AppConfig.Auth.TenantAPIKeys[AppConfig.Auth.AdminAPIKey]; ok {
+		log.Fatalf("TENANT_API_KEYS 中的密钥不能与 ADMIN_API_KEY 相同")
+	}
+	if AppConfig.Upload.MaxMultipartMemory < 0 {
+		log.Fatalf("UPLOAD_MAX_MEMORY_MB 不能小于 0")
+	}
+	if AppConfig.Upload.FilenameMaxBytes < 16 {
+		log.Fatalf("UPLOAD_FILENAME_MAX_BYTES 不能小于 16: %d", AppConfig.Upload.FilenameMaxBytes)
+	}
+	if AppConfig.Compression.MinSize < 0 {
+		log.Fatalf("GZIP_MIN_SIZE 不能小于 0: %d", AppConfig.Compression.MinSize)
+	}
+	if action := AppConfig.Catalog.ImportOnConflict; action != "skip" && action != "regenerate" {
+		log.Fatalf("不支持的 CATALOG_IMPORT_ON_CONFLICT: %s，可选 skip / regenerate", action)
+	}
+	if format := AppConfig.PageImage.Format; format != "png" && format != "jpeg" {
+		log.Fatalf("不支持的 PAGE_IMAGE_FORMAT: %s，可选 png / jpeg", format)
+	}
+	if dpi := AppConfig.PageImage.DPI; dpi < 36 || dpi > 600 {
+		log.Fatalf("PAGE_IMAGE_DPI 必须在 36 到 600 之间: %d", dpi)
+	}
+	if search := AppConfig.Search; search.MaxTopK < 1 || search.DefaultTopK < 1 || search.DefaultTopK > search.MaxTopK {
+		log.Fatalf("SEARCH_DEFAULT_TOP_K 必须在 1 到 SEARCH_MAX_TOP_K (%d) 之间: %d", search.MaxTopK, search.DefaultTopK)
+	}
+	if search := AppConfig.Search; search.MaxFileIDs < 0 || search.MaxFilterBytes < 0 {
+		log.Fatalf("SEARCH_MAX_FILE_IDS 和 SEARCH_MAX_FILTER_BYTES 不能小于 0: %d, %d", search.MaxFileIDs, search.MaxFilterBytes)
+	}
+	if embed := AppConfig.EmbedAPI; embed.MaxTokens < 1 || embed.RateLimit < 0 || embed.RateWindow <= 0 {
+		log.Fatalf("EMBED_API_MAX_TOKENS 必须大于 0、EMBED_API_RATE_LIMIT 不能小于 0、EMBED_API_RATE_WINDOW 必须大于 0: %d, %d, %s", embed.MaxTokens, embed.RateLimit, embed.RateWindow)
+	}
+	if decimals := AppConfig.Stats.RateDecimals; decimals < 0 || decimals > 6 {
+		log.Fatalf("STATS_RATE_DECIMALS 必须在 0 到 6 之间: %d", decimals)
+	}
+	if stats := AppConfig.Stats; stats.ThroughputMaxBuckets < 1 {
+		log.Fatalf("STATS_THROUGHPUT_MAX_BUCKETS 必须大于 0: %d", stats.ThroughputMaxBuckets)
+	}
+	if stats := AppConfig.Stats; stats.ThroughputBucket <= 0 || stats.ThroughputWindow < stats.ThroughputBucket {
+		log.Fatalf("STATS_THROUGHPUT_BUCKET 必须大于 0 且不超过 STATS_THROUGHPUT_WINDOW (%s): %s", stats.ThroughputWindow, stats.ThroughputBucket)
+	}
+	if stats := AppConfig.Stats; int(stats.ThroughputWindow/stats.ThroughputBucket) > stats.ThroughputMaxBuckets {
+		log.Fatalf("STATS_THROUGHPUT_WINDOW 按 STATS_THROUGHPUT_BUCKET 分桶后超过 STATS_THROUGHPUT_MAX_BUCKETS (%d)", stats.ThroughputMaxBuckets)
+	}
+	if chunks := AppConfig.Chunks; chunks.MaxPageSize < 1 || chunks.DefaultPageSize < 1 || chunks.DefaultPageSize > chunks.MaxPageSize {
+		log.Fatalf("CHUNKS_DEFAULT_PAGE_SIZE 必须在 1 到 CHUNKS_MAX_PAGE_SIZE (%d) 之间: %d", chunks.MaxPageSize, chunks.DefaultPageSize)
 	}
 
 	log.Printf("配置加载成功")
 }
 
+// chromaBaseURL 校验并规范化 CHROMA_URL，未设置时由 host 和 port 拼接。
+// 保留路径部分以支持通过反向代理的子路径访问，去掉末尾的斜杠
+func chromaBaseURL(rawURL, host, port string) (string, error) {
+	if rawURL == "" {
+		return fmt.Sprintf("http://%s:%s", host, port), nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("CHROMA_URL 格式错误: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("CHROMA_URL 只支持 http 或 https: %s", rawURL)
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("CHROMA_URL 缺少主机名: %s", rawURL)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("CHROMA_URL 不能包含查询参数或片段: %s", rawURL)
+	}
+
+	return strings.TrimRight(u.String(), "/"), nil
+}
+
+// IsValidStoreMode 检查向量存储方式，配置和处理接口的 store_mode 参数共用
+func IsValidStoreMode(mode string) bool {
+	return mode == "chroma" || mode == "noop" || mode == "temp"
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if v, err := strconv.Atoi(value); err == nil {
+			return v
+		}
+		log.Printf("环境变量 %s 不是合法的整数，使用默认值 %d", key, defaultValue)
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if v, err := strconv.ParseBool(value); err == nil {
+			return v
+		}
+		log.Printf("环境变量 %s 不是合法的布尔值，使用默认值 %t", key, defaultValue)
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			return v
+		}
+		log.Printf("环境变量 %s 不是合法的数值，使用默认值 %v", key, defaultValue)
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if v, err := time.ParseDuration(value); err == nil {
+			return v
+		}
+		log.Printf("环境变量 %s 不是合法的时长，使用默认值 %s", key, defaultValue)
+	}
+	return defaultValue
+}
+
+// getEnvList 解析逗号分隔的列表
+func getEnvList(key, defaultValue string) []string {
+	var result []string
+	for _, item := range strings.Split(getEnv(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// getEnvSizeTiers 解析 "名称:阈值MB:并发数,..." 格式的文件大小分级，按阈值从大到小排序
+func getEnvSizeTiers(key, defaultValue string) []SizeTier {
+	var tiers []SizeTier
+	for _, item := range getEnvList(key, defaultValue) {
+		parts := strings.Split(item, ":")
+		if len(parts) != 3 {
+			log.Printf("环境变量 %s 中的配置项 %q 格式错误，已忽略", key, item)
+			continue
+		}
+		sizeMB, err1 := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64)
+		concurrency, err2 := strconv.Atoi(strings.TrimSpace(parts[2]))
+		if err1 != nil || err2 != nil || sizeMB <= 0 || concurrency <= 0 {
+			log.Printf("环境变量 %s 中的配置项 %q 阈值或并发数错误，已忽略", key, item)
+			continue
+		}
+		tiers = append(tiers, SizeTier{
+			Name:        strings.TrimSpace(parts[0]),
+			MinSize:     sizeMB * 1024 * 1024,
+			Concurrency: concurrency,
+		})
+	}
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].MinSize > tiers[j].MinSize
+	})
+	return tiers
+}
+
+// getEnvDurationMap 解析 "key=时长,key=时长" 格式的配置
+// getEnvMap 解析逗号分隔的 key=value 列表
+func getEnvMap(key, defaultValue string) map[string]string {
+	result := make(map[string]string)
+	for _, item := range getEnvList(key, defaultValue) {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+			log.Printf("环境变量 %s 中的配置项 %q 格式错误，已忽略", key, item)
+			continue
+		}
+		result[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return result
+}
+
+func getEnvDurationMap(key, defaultValue string) map[string]time.Duration {
+	result := make(map[string]time.Duration)
+	for _, item := range getEnvList(key, defaultValue) {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			log.Printf("环境变量 %s 中的配置项 %q 格式错误，已忽略", key, item)
+			continue
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil {
+			log.Printf("环境变量 %s 中的配置项 %q 时长格式错误，已忽略", key, item)
+			continue
+		}
+		result[strings.TrimSpace(parts[0])] = d
+	}
+	return result
+}
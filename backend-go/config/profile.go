@@ -0,0 +1,19 @@
+package config
+
+// profile 不同运行环境下的默认值，对应的环境变量设置后仍以环境变量为准
+type profile struct {
+	DBLogLevel  string // silent / error / warn / info
+	CORSOrigins string // 逗号分隔，* 表示允许所有来源
+	GinMode     string // debug / release / test
+	AutoMigrate bool   // 启动时是否自动迁移数据库表结构
+}
+
+var profiles = map[string]profile{
+	// 开发环境: 打印所有 SQL 和 Gin 的路由注册日志，允许任意前端地址跨域访问
+	"development": {DBLogLevel: "info", CORSOrigins: "*", GinMode: "debug", AutoMigrate: true},
+	// 生产环境: 只记录慢查询和错误，必须通过 CORS_ALLOWED_ORIGINS 显式配置允许的前端地址，
+	// 表结构变更通过 /api/admin/migrate 手动执行
+	"production": {DBLogLevel: "warn", CORSOrigins: "", GinMode: "release", AutoMigrate: false},
+	// 测试环境: 不输出 SQL 日志
+	"test": {DBLogLevel: "silent", CORSOrigins: "*", GinMode: "test", AutoMigrate: true},
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"doc-analysis-backend/config"
@@ -21,14 +22,21 @@ func InitDatabase() {
 	var err error
 	cfg := config.AppConfig
 	
+	// 与标准日志使用相同的输出位置
+	dbLogger := logger.New(log.New(log.Writer(), "\r\n", log.LstdFlags), logger.Config{
+		SlowThreshold: 200 * time.Millisecond,
+		LogLevel:      parseLogLevel(cfg.Database.LogLevel),
+		Colorful:      cfg.Log.Output == "stdout",
+	})
+	
 	switch cfg.Database.Driver {
 	case "postgres":
 		DB, err = gorm.Open(postgres.Open(cfg.Database.DSN), &gorm.Config{
-			Logger: logger.Default.LogMode(logger.Info),
+			Logger: dbLogger,
 		})
 	case "sqlite":
 		DB, err = gorm.Open(sqlite.Open(cfg.Database.DSN), &gorm.Config{
-			Logger: logger.Default.LogMode(logger.Info),
+			Logger: dbLogger,
 		})
 	default:
 		log.Fatalf("不支持的数据库驱动: %s", cfg.Database.Driver)
@@ -48,20 +56,57 @@ func InitDatabase() {
 	sqlDB.SetMaxOpenConns(100)
 	sqlDB.SetConnMaxLifetime(time.Hour)
 	
-	// 自动迁移
-	if err := AutoMigrate(); err != nil {
-		log.Fatalf("数据库迁移失败: %v", err)
+	// 自动迁移，关闭时需要通过管理接口手动迁移
+	if cfg.Database.AutoMigrate {
+		if err := AutoMigrate(); err != nil {
+			log.Fatalf("数据库迁移失败: %v", err)
+		}
+	} else {
+		log.Printf("已关闭启动时的数据库迁移 (DB_AUTO_MIGRATE=false)")
 	}
 	
 	log.Printf("数据库初始化成功: %s", cfg.Database.Driver)
 }
 
+// 同一进程内不同时执行多次迁移
+var migrateMu sync.Mutex
+
+// AutoMigrate 按模型创建或更新表结构，结果写入日志
 func AutoMigrate() error {
-	return DB.AutoMigrate(
+	migrateMu.Lock()
+	defer migrateMu.Unlock()
+
+	start := time.Now()
+	err := DB.AutoMigrate(
 		&models.FileRecord{},
 		&models.ProcessingLog{},
+		&models.ProcessingRun{},
 		&models.Task{},
+		&models.DocumentChunk{},
+		&models.ProcessingSettings{},
 	)
+	if err != nil {
+		log.Printf("数据库迁移失败，耗时 %s: %v", time.Since(start).Round(time.Millisecond), err)
+		return err
+	}
+	log.Printf("数据库迁移完成，耗时 %s", time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// parseLogLevel 将 DB_LOG_LEVEL 转换为 GORM 日志级别，无法识别时使用 info
+func parseLogLevel(level string) logger.LogLevel {
+	switch level {
+	case "silent":
+		return logger.Silent
+	case "error":
+		return logger.Error
+	case "warn":
+		return logger.Warn
+	case "info":
+		return logger.Info
+	}
+	log.Printf("不支持的 DB_LOG_LEVEL: %s，使用 info", level)
+	return logger.Info
 }
 
 func GetDB() *gorm.DB {
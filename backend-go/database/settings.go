@@ -0,0 +1,39 @@
+package database
+
+import (
+	"errors"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/models"
+
+	"gorm.io/gorm"
+)
+
+const processingSettingsID = 1
+
+// GetProcessingSettings 读取当前生效的处理配置，数据库中没有记录时使用环境变量中的默认值
+func GetProcessingSettings() (*models.ProcessingSettings, error) {
+	var settings models.ProcessingSettings
+	err := DB.First(&settings, processingSettingsID).Error
+	if err == nil {
+		return &settings, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	cfg := config.AppConfig.Processing
+	return &models.ProcessingSettings{
+		ID:                 processingSettingsID,
+		ChunkSize:          cfg.ChunkSize,
+		ChunkOverlap:       cfg.ChunkOverlap,
+		EmbeddingBatchSize: cfg.EmbeddingBatchSize,
+		MaxPages:           cfg.MaxPages,
+	}, nil
+}
+
+// SaveProcessingSettings 保存处理配置，后续的处理任务会读取新的配置
+func SaveProcessingSettings(settings *models.ProcessingSettings) error {
+	settings.ID = processingSettingsID
+	return DB.Save(settings).Error
+}
@@ -0,0 +1,15 @@
+package database
+
+import (
+	"doc-analysis-backend/models"
+)
+
+// MaxShard 返回集合已使用的最大分片序号，没有分片时为 0；collection 为文件记录中保存的值，默认集合为空
+func MaxShard(collection string) (int, error) {
+	var shard int
+	err := DB.Model(&models.FileRecord{}).
+		Select("COALESCE(MAX(shard), 0)").
+		Where("COALESCE(collection, '') = ?", collection).
+		Scan(&shard).Error
+	return shard, err
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/models"
+	"doc-analysis-backend/queue"
+	"doc-analysis-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminHandler struct{}
+
+func NewAdminHandler() *AdminHandler {
+	return &AdminHandler{}
+}
+
+// 可在运行时修改的配置项，未提供的字段保持不变
+type UpdateConfigRequest struct {
+	ChunkSize          *int `json:"chunk_size"`
+	ChunkOverlap       *int `json:"chunk_overlap"`
+	EmbeddingBatchSize *int `json:"embedding_batch_size"`
+	MaxPages           *int `json:"max_pages"`
+}
+
+func (h *AdminHandler) GetConfig(c *gin.Context) {
+	settings, err := database.GetProcessingSettings()
+	if err != nil {
+		utils.InternalError(c, fmt.Sprintf("获取处理配置失败: %v", err))
+		return
+	}
+
+	utils.Success(c, settings)
+}
+
+// RunRetention 立即按保留策略清理过期文件，dry_run 未指定时使用 RETENTION_DRY_RUN 的配置
+func (h *AdminHandler) RunRetention(c *gin.Context) {
+	if config.AppConfig.Retention.Days <= 0 {
+		utils.BadRequest(c, "未配置 RETENTION_DAYS，文件保留策略未启用")
+		return
+	}
+
+	dryRun := config.AppConfig.Retention.DryRun
+	if value := c.Query("dry_run"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			utils.BadRequest(c, "dry_run 必须是 true 或 false")
+			return
+		}
+		dryRun = parsed
+	}
+
+	result, err := queue.PurgeExpiredFiles(c.Request.Context(), dryRun)
+	if err != nil {
+		utils.InternalError(c, fmt.Sprintf("清理过期文件失败: %v", err))
+		return
+	}
+
+	utils.Success(c, result)
+}
+
+// Migrate 立即按模型迁移数据库表结构，用于关闭了 DB_AUTO_MIGRATE 的环境
+func (h *AdminHandler) Migrate(c *gin.Context) {
+	start := time.Now()
+	if err := database.AutoMigrate(); err != nil {
+		utils.InternalError(c, fmt.Sprintf("数据库迁移失败: %v", err))
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"migrated": true,
+		"duration": time.Since(start).Seconds(),
+	})
+}
+
+func (h *AdminHandler) UpdateConfig(c *gin.Context) {
+	var req UpdateConfigRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	settings, err := database.GetProcessingSettings()
+	if err != nil {
+		utils.InternalError(c, fmt.Sprintf("获取处理配置失败: %v", err))
+		return
+	}
+
+	if req.ChunkSize != nil {
+		settings.ChunkSize = *req.ChunkSize
+	}
+	if req.ChunkOverlap != nil {
+		settings.ChunkOverlap = *req.ChunkOverlap
+	}
+	if req.EmbeddingBatchSize != nil {
+		settings.EmbeddingBatchSize = *req.EmbeddingBatchSize
+	}
+	if req.MaxPages != nil {
+		settings.MaxPages = *req.MaxPages
+	}
+
+	if errs := validateProcessingSettings(settings); len(errs) > 0 {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+
+	if err := database.SaveProcessingSettings(settings); err != nil {
+		utils.InternalError(c, fmt.Sprintf("保存处理配置失败: %v", err))
+		return
+	}
+
+	utils.SuccessWithMessage(c, "处理配置已更新，将在后续处理任务中生效", settings)
+}
+
+func validateProcessingSettings(s *models.ProcessingSettings) utils.ValidationErrors {
+	var errs utils.ValidationErrors
+	checkChunking(&errs, s.ChunkSize, s.ChunkOverlap)
+	errs.Check(s.EmbeddingBatchSize >= 1 && s.EmbeddingBatchSize <= 2048,
+		"embedding_batch_size", "embedding_batch_size 必须在 1 到 2048 之间")
+	errs.Check(s.MaxPages >= 0 && s.MaxPages <= 10000,
+		"max_pages", "max_pages 必须在 0 到 10000 之间 (0 表示不限制)")
+	return errs
+}
+
+func checkChunking(errs *utils.ValidationErrors, chunkSize, chunkOverlap int) {
+	if !errs.Check(chunkSize >= 100 && chunkSize <= 10000, "chunk_size", "chunk_size 必须在 100 到 10000 之间") {
+		return
+	}
+	errs.Check(chunkOverlap >= 0 && chunkOverlap <= chunkSize/2, "chunk_overlap", "chunk_overlap 必须在 0 到 chunk_size 的一半之间")
+}
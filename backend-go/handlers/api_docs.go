@@ -0,0 +1,530 @@
+package handlers
+
+import (
+	"doc-analysis-backend/models"
+	"doc-analysis-backend/openapi"
+	"doc-analysis-backend/queue"
+)
+
+func object(properties map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+func arrayOf(items map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"type": "array", "items": items}
+}
+
+func typed(t string) map[string]interface{} {
+	return map[string]interface{}{"type": t}
+}
+
+// RegisterAPIDocs 登记各接口的 OpenAPI 文档，新增接口时在这里补充说明
+func RegisterAPIDocs() {
+	fileSchema := openapi.SchemaOf(models.FileRecord{})
+	idParam := openapi.Param{Name: "id", In: "path", Description: "文件ID"}
+
+	openapi.Register("GET", "/health", openapi.Operation{
+		Summary: "健康检查",
+		Tag:     "系统",
+		Raw:     true,
+		ResponseSchema: object(map[string]interface{}{
+			"status": typed("string"),
+			"time":   typed("string"),
+		}),
+	})
+	openapi.Register("GET", "/ready", openapi.Operation{
+		Summary:     "就绪检查",
+		Description: "数据库不可用、向量化服务探测失败或熔断冷却期间返回 503",
+		Tag:         "系统",
+		Raw:         true,
+		ResponseSchema: object(map[string]interface{}{
+			"status": typed("string"),
+			"checks": typed("object"),
+			"time":   typed("string"),
+		}),
+	})
+	openapi.Register("GET", "/metrics", openapi.Operation{
+		Summary: "Prometheus 指标",
+		Tag:     "系统",
+		Raw:     true,
+	})
+
+	openapi.Register("POST", "/api/upload-files", openapi.Operation{
+		Summary:     "批量上传文件",
+		Description: "支持 PDF 和 ZIP，ZIP 会在处理时解压为多个文件",
+		Tag:         "文件",
+		FormFields: map[string]interface{}{
+			"files":          arrayOf(map[string]interface{}{"type": "string", "format": "binary"}),
+			"chunk_size":     typed("integer"),
+			"chunk_overlap":  typed("integer"),
+			"chunk_strategy": map[string]interface{}{"type": "string", "enum": []string{"fixed", "sentence", "recursive"}},
+			"metadata":       map[string]interface{}{"type": "string", "description": "JSON 对象，值只能是字符串或数字"},
+		},
+		Raw: true,
+		ResponseSchema: object(map[string]interface{}{
+			"files": arrayOf(object(map[string]interface{}{
+				"id":       typed("string"),
+				"filename": typed("string"),
+				"status":   typed("string"),
+			})),
+			"message": typed("string"),
+			"warning": map[string]interface{}{"type": "string", "description": "任务队列积压超过 QUEUE_BACKPRESSURE_THRESHOLD 时的提示"},
+		}),
+	})
+	openapi.Register("GET", "/api/upload-config", openapi.Operation{
+		Summary:     "上传限制与任务队列积压",
+		Description: "queue.depth 为所有队列中等待、定时和等待重试的任务数，backpressure 为 true 时客户端应放慢上传和提交处理的速度；读取队列状态失败时 queue 为空，queue_error 说明原因",
+		Tag:         "文件",
+		ResponseSchema: object(map[string]interface{}{
+			"max_size":           typed("integer"),
+			"allowed_extensions": arrayOf(typed("string")),
+			"max_concurrent":     typed("integer"),
+			"queue":              openapi.SchemaOf(queue.QueueDepth{}),
+			"queue_error":        typed("string"),
+		}),
+	})
+	openapi.Register("POST", "/api/validate", openapi.Operation{
+		Summary:     "上传前校验文件",
+		Description: "依次检查扩展名、大小、文件头以及能否解析（PDF 是否损坏或需要密码，ZIP 是否超过限制），遇到未通过的检查即停止；不保存文件也不创建记录",
+		Tag:         "文件",
+		FormFields: map[string]interface{}{
+			"file": map[string]interface{}{"type": "string", "format": "binary"},
+		},
+		ResponseSchema: openapi.SchemaOf(ValidationReport{}),
+	})
+	openapi.Register("GET", "/api/files/status", openapi.Operation{
+		Summary:        "所有文件状态",
+		Tag:            "文件",
+		Raw:            true,
+		ResponseSchema: object(map[string]interface{}{"files": arrayOf(fileSchema)}),
+	})
+	openapi.Register("GET", "/api/files/status/summary", openapi.Operation{
+		Summary: "各状态文件数量",
+		Tag:     "文件",
+		ResponseSchema: object(map[string]interface{}{
+			"pending":          typed("integer"),
+			"processing":       typed("integer"),
+			"completed":        typed("integer"),
+			"completed_empty":  typed("integer"),
+			"partial":          typed("integer"),
+			"error":            typed("integer"),
+			"failed_permanent": typed("integer"),
+			"total":            typed("integer"),
+		}),
+	})
+	fileStatusSchema := openapi.SchemaOf(models.FileRecord{})
+	fileStatusSchema["properties"].(map[string]interface{})["processing_settings"] = openapi.SchemaOf(UsedProcessingSettings{})
+	openapi.Register("GET", "/api/files/:id/status", openapi.Operation{
+		Summary:        "单个文件状态",
+		Description:    "processing_settings 为文件现有的块和向量实际使用的分块配置和模型，取自处理记录，文件尚未处理过时不返回",
+		Tag:            "文件",
+		Params:         []openapi.Param{idParam},
+		ResponseSchema: fileStatusSchema,
+	})
+	openapi.Register("GET", "/api/files/:id/status/stream", openapi.Operation{
+		Summary:     "单个文件状态 SSE 推送",
+		Description: "text/event-stream，事件类型: status、done、deleted、timeout",
+		Tag:         "实时状态",
+		Params:      []openapi.Param{idParam},
+		Raw:         true,
+	})
+	openapi.Register("GET", "/api/files/:id/logs/stream", openapi.Operation{
+		Summary:     "处理日志 SSE 推送",
+		Description: "text/event-stream，先推送已有日志，之后推送新写入的日志；事件类型: log、done、deleted、timeout",
+		Tag:         "实时状态",
+		Params:      []openapi.Param{idParam},
+		Raw:         true,
+	})
+	openapi.Register("POST", "/api/files/:id/process", openapi.Operation{
+		Summary: "处理文件",
+		Tag:     "文件",
+		Params: []openapi.Param{
+			idParam,
+			{Name: "force", In: "query", Type: "boolean", Description: "已完成的文件清除旧向量后重新处理"},
+			{Name: "sync", In: "query", Type: "boolean", Description: "小文件在请求中直接处理，超时后改为异步；未指定时由 SYNC_PROCESS_AUTO 决定"},
+			{Name: "store_mode", In: "query", Type: "string", Description: "向量存储方式: chroma / noop 跳过存储 / temp 写入临时集合，用于压测；未指定时由 PROCESSING_STORE_MODE 决定"},
+		},
+		ResponseSchema: object(map[string]interface{}{
+			"file_id": typed("string"),
+			"task_id": typed("string"),
+			"mode":    map[string]interface{}{"type": "string", "enum": []string{"sync", "async"}},
+			"status":  typed("string"),
+			"file":    fileSchema,
+			"error":   typed("string"),
+			"warning": typed("string"),
+		}),
+	})
+	openapi.Register("POST", "/api/files/:id/reembed", openapi.Operation{
+		Summary:     "重新向量化",
+		Description: "使用当前向量化模型重新生成向量并覆盖向量库中的记录，只读取已保存的块文本，不重新解析文件",
+		Tag:         "文件",
+		Params:      []openapi.Param{idParam},
+		ResponseSchema: object(map[string]interface{}{
+			"file_id":      typed("string"),
+			"task_id":      typed("string"),
+			"chunks_count": typed("integer"),
+		}),
+	})
+	openapi.Register("POST", "/api/files/:id/retry-failed", openapi.Operation{
+		Summary:     "重试向量化失败的块",
+		Description: "只重新向量化部分完成（partial）的文件中失败的块，失败的块记录在文件的 failed_chunks 中",
+		Tag:         "文件",
+		Params:      []openapi.Param{idParam},
+		ResponseSchema: object(map[string]interface{}{
+			"file_id":       typed("string"),
+			"task_id":       typed("string"),
+			"failed_chunks": typed("integer"),
+		}),
+	})
+	openapi.Register("POST", "/api/files/:id/hold", openapi.Operation{
+		Summary:        "暂停处理文件",
+		Description:    "暂停处理的文件不会被批量处理和一致性修复选中，失败后也不再自动重试；手动处理不受影响",
+		Tag:            "文件",
+		Params:         []openapi.Param{idParam},
+		ResponseSchema: openapi.SchemaOf(models.FileRecord{}),
+	})
+	openapi.Register("POST", "/api/files/:id/unhold", openapi.Operation{
+		Summary:        "取消暂停处理文件",
+		Tag:            "文件",
+		Params:         []openapi.Param{idParam},
+		ResponseSchema: openapi.SchemaOf(models.FileRecord{}),
+	})
+	openapi.Register("GET", "/api/files/:id/logs", openapi.Operation{
+		Summary: "文件处理日志",
+		Tag:     "文件",
+		Params:  []openapi.Param{idParam},
+		Raw:     true,
+		ResponseSchema: object(map[string]interface{}{
+			"file_id": typed("string"),
+			"logs":    arrayOf(openapi.SchemaOf(models.ProcessingLog{})),
+		}),
+	})
+	openapi.Register("GET", "/api/files/:id/runs", openapi.Operation{
+		Summary:     "文件的历次处理记录",
+		Description: "每个处理任务（包括重新向量化、重试失败的块以及每次自动重试）结束时写入一条，按 run_number 升序返回",
+		Tag:         "文件",
+		Params:      []openapi.Param{idParam},
+		ResponseSchema: object(map[string]interface{}{
+			"file_id": typed("string"),
+			"runs":    arrayOf(openapi.SchemaOf(models.ProcessingRun{})),
+		}),
+	})
+	openapi.Register("GET", "/api/files/:id/chunks", openapi.Operation{
+		Summary:     "分页获取文件的文本块",
+		Description: "page_size 超过 CHUNKS_MAX_PAGE_SIZE 时按上限返回而不报错，pagination.page_size 为实际生效的值，clamped 表示是否被限制",
+		Tag:         "文件",
+		Params: []openapi.Param{
+			idParam,
+			{Name: "page", In: "query", Type: "integer", Description: "从 1 开始，默认 1"},
+			{Name: "page_size", In: "query", Type: "integer", Description: "默认 CHUNKS_DEFAULT_PAGE_SIZE（50），上限 CHUNKS_MAX_PAGE_SIZE（200）"},
+		},
+		ResponseSchema: object(map[string]interface{}{
+			"file_id": typed("string"),
+			"chunks":  arrayOf(openapi.SchemaOf(models.DocumentChunk{})),
+			"pagination": object(map[string]interface{}{
+				"page":        typed("integer"),
+				"page_size":   typed("integer"),
+				"total":       typed("integer"),
+				"total_pages": typed("integer"),
+				"clamped":     typed("boolean"),
+			}),
+		}),
+	})
+	openapi.Register("GET", "/api/files/:id/vectors", openapi.Operation{
+		Summary: "查看文件的向量数据",
+		Tag:     "文件",
+		Params: []openapi.Param{
+			idParam,
+			{Name: "full", In: "query", Type: "boolean", Description: "返回完整向量"},
+			{Name: "limit", In: "query", Type: "integer", Description: "1-100，默认 20"},
+			{Name: "offset", In: "query", Type: "integer"},
+		},
+	})
+	openapi.Register("POST", "/api/files/:id/move-collection", openapi.Operation{
+		Summary:       "将文件的向量移动到其他集合",
+		Description:   "目标集合不存在时创建；全部写入目标集合后才删除源集合中的向量，并更新文件记录的 collection",
+		Tag:           "文件",
+		Params:        []openapi.Param{idParam},
+		RequestSchema: openapi.SchemaOf(MoveCollectionRequest{}),
+		ResponseSchema: object(map[string]interface{}{
+			"file_id": typed("string"),
+			"source":  typed("string"),
+			"target":  typed("string"),
+			"moved":   typed("integer"),
+			"warning": typed("string"),
+		}),
+	})
+	openapi.Register("GET", "/api/files/:id/report", openapi.Operation{
+		Summary:     "文件处理报告",
+		Description: "汇总文件元数据、页数、块数、处理耗时、各阶段耗时和示例块；format=html 时返回 HTML 页面",
+		Tag:         "文件",
+		Params: []openapi.Param{
+			idParam,
+			{Name: "format", In: "query", Description: "json（默认）或 html"},
+			{Name: "sample", In: "query", Type: "integer", Description: "示例块数量，0-20，默认 5"},
+		},
+		ResponseSchema: openapi.SchemaOf(FileReport{}),
+	})
+	openapi.Register("GET", "/api/files/:id/page/:n/image", openapi.Operation{
+		Summary:     "PDF 页面图片",
+		Description: "将第 n 页（从 1 开始）渲染为 PAGE_IMAGE_FORMAT 格式的图片并缓存，页码超出范围返回 404",
+		Tag:         "文件",
+		Params: []openapi.Param{
+			idParam,
+			{Name: "n", In: "path", Type: "integer", Description: "页码"},
+		},
+		Raw: true,
+	})
+	openapi.Register("GET", "/api/files/:id/download", openapi.Operation{
+		Summary:     "下载原始文件",
+		Description: "原始文件已按保留策略清理时返回 410",
+		Tag:         "文件",
+		Params:      []openapi.Param{idParam},
+		Raw:         true,
+	})
+	openapi.Register("DELETE", "/api/files/:id", openapi.Operation{
+		Summary: "删除文件及其向量",
+		Tag:     "文件",
+		Params:  []openapi.Param{idParam},
+	})
+	openapi.Register("POST", "/api/files/cleanup", openapi.Operation{
+		Summary:       "按条件批量删除文件",
+		Description:   "删除同时满足 status、created_before、error_count_gte 条件的文件及其向量、日志、任务，至少指定一个条件；实际删除需要 confirm=true，dry_run=true 只返回将被删除的文件。每次最多删除 500 个，remaining 为剩余的匹配数量",
+		Tag:           "文件",
+		RequestSchema: openapi.SchemaOf(CleanupRequest{}),
+		ResponseSchema: object(map[string]interface{}{
+			"dry_run":   typed("boolean"),
+			"matched":   typed("integer"),
+			"deleted":   typed("integer"),
+			"failed":    typed("integer"),
+			"remaining": typed("integer"),
+			"files":     arrayOf(openapi.SchemaOf(CleanupFile{})),
+		}),
+	})
+	openapi.Register("POST", "/api/files/tags", openapi.Operation{
+		Summary:       "批量修改标签",
+		Description:   "为多个文件添加、移除标签，同时更新文件记录和向量库中各块的元数据；同一标签同时出现在 add 和 remove 中时以 remove 为准",
+		Tag:           "文件",
+		RequestSchema: openapi.SchemaOf(BulkTagRequest{}),
+		ResponseSchema: object(map[string]interface{}{
+			"results": arrayOf(openapi.SchemaOf(TagResult{})),
+			"updated": typed("integer"),
+			"failed":  typed("integer"),
+		}),
+	})
+	openapi.Register("POST", "/api/process-all", openapi.Operation{
+		Summary:     "处理所有待处理文件",
+		Description: "任务队列积压超过 QUEUE_BACKPRESSURE_THRESHOLD 时返回 503 并带有 Retry-After",
+		Tag:         "文件",
+		ResponseSchema: object(map[string]interface{}{
+			"task_ids": arrayOf(typed("string")),
+			"results":  arrayOf(openapi.SchemaOf(enqueueResult{})),
+			"queued":   typed("integer"),
+			"failed":   typed("integer"),
+		}),
+	})
+	openapi.Register("POST", "/api/estimate", openapi.Operation{
+		Summary:       "估算向量化成本",
+		Tag:           "文件",
+		RequestSchema: openapi.SchemaOf(EstimateRequest{}),
+		ResponseSchema: object(map[string]interface{}{
+			"files":               arrayOf(openapi.SchemaOf(fileEstimate{})),
+			"total_files":         typed("integer"),
+			"total_chunks":        typed("integer"),
+			"total_tokens":        typed("integer"),
+			"price_per_1k_tokens": typed("number"),
+			"estimated_cost":      typed("number"),
+			"model":               typed("string"),
+		}),
+	})
+	openapi.Register("POST", "/api/search", openapi.Operation{
+		Summary:     "检索文档块",
+		Description: "mode: vector（默认）、keyword、hybrid；highlight 为 true 时返回高亮摘要；context_window 为每个结果附带前后各 N 个块（最多 5），放在 context 中与命中块区分；结果会短时间缓存，no_cache=true 跳过缓存；向量库不可用时 vector 模式返回 503 并带有 Retry-After，hybrid 模式降级为关键词检索，degraded 为 true",
+		Tag:         "检索",
+		Params: []openapi.Param{
+			{Name: "no_cache", In: "query", Type: "boolean", Description: "跳过缓存直接检索"},
+		},
+		RequestSchema: openapi.SchemaOf(SearchRequest{}),
+		ResponseSchema: object(map[string]interface{}{
+			"query":           typed("string"),
+			"mode":            typed("string"),
+			"results":         arrayOf(openapi.SchemaOf(SearchResult{})),
+			"total":           typed("integer"),
+			"top_k":           typed("integer"),
+			"cached":          typed("boolean"),
+			"query_truncated": typed("boolean"),
+			"degraded":        typed("boolean"),
+		}),
+	})
+	openapi.Register("POST", "/api/embed", openapi.Operation{
+		Summary:        "文本向量化",
+		Description:    "使用当前配置的向量化服务为文本生成向量；需要密钥（租户密钥或管理员密钥），估算 token 数超过 EMBED_API_MAX_TOKENS 时返回 400，超过 EMBED_API_RATE_LIMIT 时返回 429",
+		Tag:            "检索",
+		RequestSchema:  openapi.SchemaOf(EmbedRequest{}),
+		ResponseSchema: openapi.SchemaOf(EmbedResponse{}),
+	})
+	openapi.Register("POST", "/api/files/:id/search", openapi.Operation{
+		Summary:     "在单个文件中检索",
+		Description: "参数与 /api/search 相同，只检索该文件的块；file_ids、collection、filter 由文件决定，请求中的值被忽略；文件状态不是 completed 或 partial 时返回 409",
+		Tag:         "检索",
+		Params: []openapi.Param{
+			idParam,
+			{Name: "no_cache", In: "query", Type: "boolean", Description: "跳过缓存直接检索"},
+		},
+		RequestSchema: openapi.SchemaOf(SearchRequest{}),
+		ResponseSchema: object(map[string]interface{}{
+			"query":           typed("string"),
+			"mode":            typed("string"),
+			"results":         arrayOf(openapi.SchemaOf(SearchResult{})),
+			"total":           typed("integer"),
+			"top_k":           typed("integer"),
+			"cached":          typed("boolean"),
+			"query_truncated": typed("boolean"),
+			"degraded":        typed("boolean"),
+		}),
+	})
+	openapi.Register("GET", "/api/metadata/values", openapi.Operation{
+		Summary:     "元数据字段的取值",
+		Description: "返回该字段在所有文件中出现过的取值及使用该值的文件数量，按数量降序；key 只能是 SEARCH_FACET_KEYS 中的字段",
+		Tag:         "检索",
+		Params: []openapi.Param{
+			{Name: "key", In: "query", Type: "string", Description: "元数据字段名"},
+		},
+		ResponseSchema: object(map[string]interface{}{
+			"key":    typed("string"),
+			"values": arrayOf(openapi.SchemaOf(MetadataValue{})),
+			"total":  typed("integer"),
+		}),
+	})
+	openapi.Register("GET", "/api/ws/files", openapi.Operation{
+		Summary:     "文件状态 WebSocket",
+		Description: "连接后推送 snapshot 全量快照，之后推送 update 增量",
+		Tag:         "实时状态",
+		Raw:         true,
+	})
+	openapi.Register("GET", "/api/database/stats", openapi.Operation{
+		Summary: "数据库统计",
+		Tag:     "统计",
+		Raw:     true,
+	})
+	openapi.Register("GET", "/api/stats/throughput", openapi.Operation{
+		Summary:     "处理吞吐量",
+		Description: "根据任务记录统计时间窗口内处理完成的文件数、处理耗时、按结束时间分桶的任务数，以及当前队列深度和工作器利用率",
+		Tag:         "统计",
+		Params: []openapi.Param{
+			{Name: "window", In: "query", Description: "时间窗口，如 6h，默认 STATS_THROUGHPUT_WINDOW（24h）"},
+			{Name: "bucket", In: "query", Description: "分桶大小，如 30m，默认 STATS_THROUGHPUT_BUCKET（1h），分桶数量不能超过 STATS_THROUGHPUT_MAX_BUCKETS"},
+		},
+		ResponseSchema: openapi.SchemaOf(ThroughputStats{}),
+	})
+	openapi.Register("GET", "/api/tasks", openapi.Operation{
+		Summary: "任务列表",
+		Tag:     "任务",
+		Params: []openapi.Param{
+			{Name: "file_id", In: "query"},
+			{Name: "status", In: "query"},
+			{Name: "limit", In: "query", Type: "integer", Description: "1-200，默认 50"},
+		},
+		ResponseSchema: object(map[string]interface{}{
+			"tasks":                  arrayOf(openapi.SchemaOf(models.Task{})),
+			"avg_queue_wait_seconds": typed("number"),
+		}),
+	})
+	openapi.Register("GET", "/api/tasks/:id", openapi.Operation{
+		Summary:     "任务详情",
+		Description: "返回任务记录、所属文件名以及 asynq 中的实时状态；任务已不在队列中（如已完成被清除）时 live 为空，live_error 说明原因",
+		Tag:         "任务",
+		Params: []openapi.Param{
+			{Name: "id", In: "path", Description: "任务ID"},
+		},
+		ResponseSchema: object(map[string]interface{}{
+			"task":       openapi.SchemaOf(models.Task{}),
+			"filename":   typed("string"),
+			"live":       openapi.SchemaOf(queue.LiveTaskState{}),
+			"live_error": typed("string"),
+		}),
+	})
+
+	openapi.Register("GET", "/api/admin/config", openapi.Operation{
+		Summary:        "查看运行时处理配置",
+		Tag:            "管理",
+		Admin:          true,
+		ResponseSchema: openapi.SchemaOf(models.ProcessingSettings{}),
+	})
+	openapi.Register("PUT", "/api/admin/config", openapi.Operation{
+		Summary:        "修改运行时处理配置",
+		Tag:            "管理",
+		Admin:          true,
+		RequestSchema:  openapi.SchemaOf(UpdateConfigRequest{}),
+		ResponseSchema: openapi.SchemaOf(models.ProcessingSettings{}),
+	})
+	openapi.Register("POST", "/api/admin/retention/run", openapi.Operation{
+		Summary:     "立即清理过期文件",
+		Description: "按 RETENTION_DAYS 删除处理已结束的过期文件的原始文件，dry_run=true 时只返回将被清理的文件",
+		Tag:         "管理",
+		Admin:       true,
+		Params: []openapi.Param{
+			{Name: "dry_run", In: "query", Type: "boolean", Description: "未指定时使用 RETENTION_DRY_RUN"},
+		},
+		ResponseSchema: openapi.SchemaOf(queue.RetentionResult{}),
+	})
+	openapi.Register("POST", "/api/admin/migrate", openapi.Operation{
+		Summary:     "迁移数据库表结构",
+		Description: "按模型创建或更新表结构，用于关闭了 DB_AUTO_MIGRATE 的环境（生产环境默认关闭）",
+		Tag:         "管理",
+		Admin:       true,
+		ResponseSchema: object(map[string]interface{}{
+			"migrated": typed("boolean"),
+			"duration": typed("number"),
+		}),
+	})
+	openapi.Register("GET", "/api/admin/export", openapi.Operation{
+		Summary:     "导出文件目录",
+		Description: "以流的方式导出全部文件记录，格式为 {version, exported_at, include_chunks, files: [...]}，可直接作为导入接口的请求体",
+		Tag:         "管理",
+		Admin:       true,
+		Params: []openapi.Param{
+			{Name: "include_chunks", In: "query", Type: "boolean", Description: "附带块文本，导入时不需要原始文件即可重新向量化"},
+		},
+		Raw: true,
+	})
+	openapi.Register("POST", "/api/admin/import", openapi.Operation{
+		Summary:     "导入文件目录",
+		Description: "导入导出接口的结果并提交任务重建向量: 带块文本的文件重新向量化，其余文件重新处理原始文件；压缩包只恢复记录",
+		Tag:         "管理",
+		Admin:       true,
+		Params: []openapi.Param{
+			{Name: "on_conflict", In: "query", Type: "string", Description: "ID 已存在时: skip 跳过 / regenerate 使用新的 ID，默认为 CATALOG_IMPORT_ON_CONFLICT"},
+		},
+		RequestSchema:  object(map[string]interface{}{"files": arrayOf(openapi.SchemaOf(CatalogEntry{}))}),
+		ResponseSchema: openapi.SchemaOf(CatalogImportSummary{}),
+	})
+	openapi.Register("GET", "/api/admin/consistency", openapi.Operation{
+		Summary:        "数据库与向量库一致性检查",
+		Description:    "对比已完成文件的块数量与向量库中的实际向量数量，列出数量不一致、没有向量的文件以及没有文件记录的孤立向量",
+		Tag:            "管理",
+		Admin:          true,
+		ResponseSchema: openapi.SchemaOf(ConsistencyReport{}),
+	})
+	openapi.Register("POST", "/api/admin/repair", openapi.Operation{
+		Summary:     "修复一致性问题",
+		Description: "删除孤立向量；向量缺失或数量不一致的文件清除向量后重新向量化，没有保存块文本的文件重新处理",
+		Tag:         "管理",
+		Admin:       true,
+		ResponseSchema: object(map[string]interface{}{
+			"report":  openapi.SchemaOf(ConsistencyReport{}),
+			"actions": arrayOf(openapi.SchemaOf(repairAction{})),
+		}),
+	})
+
+	openapi.Register("GET", "/api/openapi.json", openapi.Operation{
+		Summary: "OpenAPI 文档",
+		Tag:     "系统",
+		Raw:     true,
+	})
+	openapi.Register("GET", "/docs", openapi.Operation{
+		Summary: "Swagger UI",
+		Tag:     "系统",
+		Raw:     true,
+	})
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/models"
+	"doc-analysis-backend/queue"
+	"doc-analysis-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// 导出文件的格式版本，格式不兼容地变化时递增
+	catalogVersion = 1
+	// 导出时每批读取的文件记录数量
+	catalogExportBatchSize = 200
+)
+
+type CatalogHandler struct{}
+
+func NewCatalogHandler() *CatalogHandler {
+	return &CatalogHandler{}
+}
+
+// CatalogEntry 导出文件中的一个文件记录，chunks 只在导出时指定 include_chunks=true 才包含
+type CatalogEntry struct {
+	models.FileRecord
+	Chunks []models.DocumentChunk `json:"chunks,omitempty"`
+}
+
+// catalogImportResult 单个文件的导入结果
+type catalogImportResult struct {
+	// 导出文件中的 ID，重新生成 ID 时 file_id 为新的 ID
+	SourceID string `json:"source_id"`
+	FileID   string `json:"file_id,omitempty"`
+	Filename string `json:"filename"`
+	// skipped: ID 已存在被跳过；reembed: 用导入的块文本重新向量化；process: 重新解析原始文件；
+	// restored: 压缩包只恢复记录；missing_file: 没有块文本且原始文件不存在，无法重建向量
+	Action string `json:"action"`
+	TaskID string `json:"task_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// CatalogImportSummary 导入结果汇总
+type CatalogImportSummary struct {
+	OnConflict string                `json:"on_conflict"`
+	Imported   int                   `json:"imported"`
+	Skipped    int                   `json:"skipped"`
+	Enqueued   int                   `json:"enqueued"`
+	Failed     int                   `json:"failed"`
+	Files      []catalogImportResult `json:"files"`
+}
+
+// Export 以流的方式导出全部文件记录，include_chunks=true 时附带块文本，导入时可直接重新向量化而不需要原始文件。
+// 每批记录写入后 Flush，路径默认在 TIMEOUT_EXCLUDE_PATHS 中，不经过缓存响应的超时中间件。
+// 响应开始写入后无法再返回错误状态码，中途出错时只记录日志，输出的 JSON 不完整
+func (h *CatalogHandler) Export(c *gin.Context) {
+	includeChunks := c.Query("include_chunks") == "true"
+	db := database.GetDB()
+
+	exportedAt := time.Now()
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="catalog-%s.json"`, exportedAt.Format("20060102-150405")))
+	c.Status(http.StatusOK)
+
+	w := c.Writer
+	fmt.Fprintf(w, `{"version":%d,"exported_at":%q,"include_chunks":%t,"files":[`,
+		catalogVersion, exportedAt.Format(time.RFC3339), includeChunks)
+
+	encoder := json.NewEncoder(w)
+	count := 0
+	// 按创建时间排序，压缩包总是在其中的文件之前，导入时可以映射 parent_id
+	for offset := 0; ; offset += catalogExportBatchSize {
+		var files []models.FileRecord
+		if err := db.Order("created_at, id").Limit(catalogExportBatchSize).Offset(offset).Find(&files).Error; err != nil {
+			log.Printf("导出文件记录失败: %v", err)
+			return
+		}
+
+		chunksByFile, err := loadCatalogChunks(db, files, includeChunks)
+		if err != nil {
+			log.Printf("导出块文本失败: %v", err)
+			return
+		}
+
+		for _, file := range files {
+			if count > 0 {
+				w.WriteString(",")
+			}
+			if err := encoder.Encode(CatalogEntry{FileRecord: file, Chunks: chunksByFile[file.ID]}); err != nil {
+				log.Printf("导出文件记录失败: %v", err)
+				return
+			}
+			count++
+		}
+		w.Flush()
+
+		if len(files) < catalogExportBatchSize {
+			break
+		}
+	}
+
+	w.WriteString("]}\n")
+}
+
+func loadCatalogChunks(db *gorm.DB, files []models.FileRecord, includeChunks bool) (map[uuid.UUID][]models.DocumentChunk, error) {
+	chunksByFile := make(map[uuid.UUID][]models.DocumentChunk)
+	if !includeChunks || len(files) == 0 {
+		return chunksByFile, nil
+	}
+
+	ids := make([]uuid.UUID, len(files))
+	for i, file := range files {
+		ids[i] = file.ID
+	}
+	var chunks []models.DocumentChunk
+	if err := db.Where("file_id IN ?", ids).Order("file_id, chunk_index").Find(&chunks).Error; err != nil {
+		return nil, err
+	}
+	for _, chunk := range chunks {
+		chunksByFile[chunk.FileID] = append(chunksByFile[chunk.FileID], chunk)
+	}
+	return chunksByFile, nil
+}
+
+// Import 导入 Export 导出的文件记录并重新提交任务重建向量，请求体逐条解析，不需要一次读入内存。
+// ID 已存在时按 on_conflict 处理: skip 跳过，regenerate 使用新的 ID，未指定时使用 CATALOG_IMPORT_ON_CONFLICT
+func (h *CatalogHandler) Import(c *gin.Context) {
+	onConflict := c.DefaultQuery("on_conflict", config.AppConfig.Catalog.ImportOnConflict)
+	if onConflict != "skip" && onConflict != "regenerate" {
+		utils.BadRequest(c, "on_conflict 只能是 skip 或 regenerate")
+		return
+	}
+
+	summary := &CatalogImportSummary{OnConflict: onConflict, Files: []catalogImportResult{}}
+	// 导出文件中的 ID 到导入后 ID 的映射，用于修正压缩包中文件的 parent_id
+	idMap := make(map[uuid.UUID]uuid.UUID)
+
+	err := decodeCatalog(c.Request.Body, func(entry *CatalogEntry) error {
+		result := importCatalogEntry(entry, onConflict, idMap)
+		switch {
+		case result.Action == "skipped":
+			summary.Skipped++
+		case result.Error != "" && result.FileID == "":
+			summary.Failed++
+		default:
+			summary.Imported++
+			if result.TaskID != "" {
+				summary.Enqueued++
+			}
+		}
+		summary.Files = append(summary.Files, result)
+		return nil
+	})
+	if err != nil {
+		utils.BadRequest(c, fmt.Sprintf("解析导入数据失败（已导入 %d 个文件）: %v", summary.Imported, err))
+		return
+	}
+
+	utils.SuccessWithMessage(c, fmt.Sprintf("已导入 %d 个文件，跳过 %d 个", summary.Imported, summary.Skipped), summary)
+}
+
+// decodeCatalog 逐条解析导出文件中 files 数组的元素，其余字段忽略
+func decodeCatalog(r io.Reader, handle func(entry *CatalogEntry) error) error {
+	decoder := json.NewDecoder(r)
+	if err := expectDelim(decoder, '{'); err != nil {
+		return err
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if key, _ := token.(string); key != "files" {
+			var skip json.RawMessage
+			if err := decoder.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := expectDelim(decoder, '['); err != nil {
+			return err
+		}
+		for decoder.More() {
+			var entry CatalogEntry
+			if err := decoder.Decode(&entry); err != nil {
+				return err
+			}
+			if err := handle(&entry); err != nil {
+				return err
+			}
+		}
+		if err := expectDelim(decoder, ']'); err != nil {
+			return err
+		}
+	}
+	return expectDelim(decoder, '}')
+}
+
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("格式错误，应为 %q", delim)
+	}
+	return nil
+}
+
+// importCatalogEntry 创建文件记录和块文本，重置处理状态后提交任务
+func importCatalogEntry(entry *CatalogEntry, onConflict string, idMap map[uuid.UUID]uuid.UUID) catalogImportResult {
+	db := database.GetDB()
+	file := entry.FileRecord
+	result := catalogImportResult{SourceID: file.ID.String(), Filename: file.Filename}
+
+	sourceID := file.ID
+	var existing int64
+	if err := db.Model(&models.FileRecord{}).Where("id = ?", file.ID).Count(&existing).Error; err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	switch {
+	case file.ID == uuid.Nil:
+		file.ID = uuid.New()
+	case existing > 0 && onConflict == "skip":
+		result.Action = "skipped"
+		idMap[sourceID] = sourceID
+		return result
+	case existing > 0:
+		file.ID = uuid.New()
+	}
+	idMap[sourceID] = file.ID
+	if file.ParentID != nil {
+		if parentID, ok := idMap[*file.ParentID]; ok {
+			file.ParentID = &parentID
+		}
+	}
+
+	// 向量需要在当前环境中重建，只保留文件本身的信息和处理结果
+	file.EmbeddedChunks = 0
+	file.StoredVectors = 0
+	file.FailedChunks = nil
+	file.ErrorCount = 0
+	file.LastError = ""
+
+	chunks := make([]models.DocumentChunk, len(entry.Chunks))
+	for i, chunk := range entry.Chunks {
+		chunks[i] = models.DocumentChunk{
+			ID:         fmt.Sprintf("%s_%d", file.ID, chunk.ChunkIndex),
+			FileID:     file.ID,
+			ChunkIndex: chunk.ChunkIndex,
+			PageNumber: chunk.PageNumber,
+			Content:    chunk.Content,
+		}
+	}
+
+	enqueue := queue.EnqueueProcessDocument
+	switch {
+	case queue.IsArchiveFile(file.Filename):
+		// 压缩包中的文件作为单独的记录导入，重新处理压缩包会重复解压
+		result.Action = "restored"
+		enqueue = nil
+	case len(chunks) > 0:
+		result.Action = "reembed"
+		enqueue = queue.EnqueueReembedDocument
+	case file.FilePurged || !fileExists(file.Filepath):
+		result.Action = "missing_file"
+		enqueue = nil
+		file.Status, file.Progress, file.Message = "error", 0, "原始文件不存在且没有导入块文本，无法重建向量，请重新上传"
+	default:
+		result.Action = "process"
+	}
+	if enqueue != nil {
+		file.Status, file.Progress, file.Message = "pending", 0, "已导入，等待重建向量..."
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&file).Error; err != nil {
+			return err
+		}
+		if len(chunks) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(chunks, catalogExportBatchSize).Error
+	})
+	if err != nil {
+		result.Error = fmt.Sprintf("保存文件记录失败: %v", err)
+		return result
+	}
+	result.FileID = file.ID.String()
+
+	if enqueue == nil {
+		return result
+	}
+	taskInfo, err := enqueue(file.ID.String())
+	if err != nil {
+		// 保持 pending 状态，可通过处理全部待处理文件接口重新提交
+		result.Error = err.Error()
+		db.Model(&file).Updates(map[string]interface{}{
+			"message":    fmt.Sprintf("加入处理队列失败: %v", err),
+			"last_error": err.Error(),
+		})
+		return result
+	}
+	result.TaskID = taskInfo.ID
+	return result
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
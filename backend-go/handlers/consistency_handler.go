@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/models"
+	"doc-analysis-backend/queue"
+	"doc-analysis-backend/services"
+	"doc-analysis-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 统计向量数量时每次从 ChromaDB 读取的记录数
+const consistencyPageSize = 1000
+
+type ConsistencyHandler struct{}
+
+func NewConsistencyHandler() *ConsistencyHandler {
+	return &ConsistencyHandler{}
+}
+
+type consistencyIssue struct {
+	FileID   string `json:"file_id"`
+	Filename string `json:"filename,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Expected int    `json:"expected_vectors"`
+	Actual   int    `json:"actual_vectors"`
+}
+
+// ConsistencyReport 数据库记录与向量库的对比结果
+type ConsistencyReport struct {
+	// 默认集合创建时写入的元数据，outdated 表示集合由旧版本创建
+	Collection   services.CollectionInfo `json:"collection"`
+	CheckedFiles int                     `json:"checked_files"`
+	TotalVectors int                     `json:"total_vectors"`
+	// 向量数量与记录不一致的文件
+	Mismatched []consistencyIssue `json:"mismatched"`
+	// 标记为已完成但向量库中没有任何向量的文件
+	MissingVectors []consistencyIssue `json:"missing_vectors"`
+	// file_id 在数据库中没有对应记录的向量
+	Orphaned []consistencyIssue `json:"orphaned"`
+	// 由与当前 EMBEDDING_MODEL 不同的模型生成的向量，需要重新向量化
+	StaleModelChunks int              `json:"stale_model_chunks"`
+	StaleModelFiles  []staleModelFile `json:"stale_model_files"`
+	// 未记录模型的向量（记录模型之前生成），无法判断是否过期，不计入不一致
+	UnknownModelChunks int  `json:"unknown_model_chunks"`
+	Consistent         bool `json:"consistent"`
+}
+
+type staleModelFile struct {
+	FileID   string   `json:"file_id"`
+	Filename string   `json:"filename,omitempty"`
+	Chunks   int      `json:"chunks"`
+	Models   []string `json:"models"`
+}
+
+// vectorStats 集合中向量的统计结果，按 file_id 分组
+type vectorStats struct {
+	total        int
+	counts       map[string]int
+	staleCounts  map[string]int
+	staleModels  map[string]map[string]bool
+	unknownModel int
+}
+
+type repairAction struct {
+	FileID string `json:"file_id"`
+	Action string `json:"action"` // delete_orphaned / reembed / reprocess / skipped
+	Error  string `json:"error,omitempty"`
+}
+
+// Check 对比每个已完成文件记录的块数量与向量库中的实际向量数量
+func (h *ConsistencyHandler) Check(c *gin.Context) {
+	report, err := checkConsistency(c.Request.Context())
+	if err != nil {
+		utils.InternalError(c, fmt.Sprintf("一致性检查失败: %v", err))
+		return
+	}
+
+	utils.Success(c, report)
+}
+
+// Repair 修复一致性检查发现的问题: 删除孤立向量；向量缺失或数量不一致的文件清除向量后
+// 重新向量化（有保存的块文本时）或重新处理
+func (h *ConsistencyHandler) Repair(c *gin.Context) {
+	ctx := c.Request.Context()
+	report, err := checkConsistency(ctx)
+	if err != nil {
+		utils.InternalError(c, fmt.Sprintf("一致性检查失败: %v", err))
+		return
+	}
+
+	chromaClient := services.NewChromaClient()
+	actions := []repairAction{}
+	for _, issue := range report.Orphaned {
+		action := repairAction{FileID: issue.FileID, Action: "delete_orphaned"}
+		if err := chromaClient.DeleteDocumentsByFileID(ctx, services.CollectionName(), issue.FileID); err != nil {
+			action.Error = err.Error()
+		}
+		services.InvalidateSearchCache(issue.FileID)
+		actions = append(actions, action)
+	}
+
+	// 由旧模型生成向量的文件同样清除后重新向量化，同一文件只处理一次
+	var fileIDs []string
+	repaired := map[string]bool{}
+	for _, issue := range append(append([]consistencyIssue{}, report.MissingVectors...), report.Mismatched...) {
+		fileIDs = append(fileIDs, issue.FileID)
+	}
+	for _, stale := range report.StaleModelFiles {
+		fileIDs = append(fileIDs, stale.FileID)
+	}
+	for _, fileID := range fileIDs {
+		if repaired[fileID] {
+			continue
+		}
+		repaired[fileID] = true
+		actions = append(actions, repairFileVectors(ctx, fileID))
+	}
+
+	utils.Success(c, gin.H{
+		"report":  report,
+		"actions": actions,
+	})
+}
+
+func checkConsistency(ctx context.Context) (*ConsistencyReport, error) {
+	var files []models.FileRecord
+	err := database.GetDB().
+		Select("id", "filename", "status", "chunks_count", "embedded_chunks", "file_purged", "collection", "shard", "store_mode").
+		Find(&files).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询文件记录失败: %w", err)
+	}
+
+	collection, err := services.NewChromaClient().GetCollection(ctx, services.CollectionName())
+	if err != nil {
+		return nil, fmt.Errorf("读取集合信息失败: %w", err)
+	}
+	stats, err := countVectorsByFile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vectorCounts := stats.counts
+
+	report := &ConsistencyReport{
+		Collection:         services.DescribeCollection(collection),
+		TotalVectors:       stats.total,
+		Mismatched:         []consistencyIssue{},
+		MissingVectors:     []consistencyIssue{},
+		Orphaned:           []consistencyIssue{},
+		StaleModelFiles:    []staleModelFile{},
+		UnknownModelChunks: stats.unknownModel,
+	}
+
+	known := make(map[string]bool, len(files))
+	for _, file := range files {
+		id := file.ID.String()
+		known[id] = true
+		if count := stats.staleCounts[id]; count > 0 {
+			stale := staleModelFile{FileID: id, Filename: file.Filename, Chunks: count}
+			for model := range stats.staleModels[id] {
+				stale.Models = append(stale.Models, model)
+			}
+			sort.Strings(stale.Models)
+			report.StaleModelFiles = append(report.StaleModelFiles, stale)
+			report.StaleModelChunks += count
+		}
+		// 只检查默认集合，已移动到其他集合或位于分片中的文件不在统计范围内；跳过存储的压测文件本来就没有向量
+		if services.ShardCollection(file.Collection, file.Shard) != services.CollectionName() || file.StoreMode == "noop" {
+			continue
+		}
+
+		// 只检查已结束且应当有向量的文件，处理中的文件向量数量本来就在变化
+		var expected int
+		switch file.Status {
+		case "completed":
+			expected = file.ChunksCount
+		case "partial":
+			expected = file.EmbeddedChunks
+		default:
+			continue
+		}
+		// 保留策略清理时可能连同向量一起删除，这类文件没有向量是正常的
+		if file.FilePurged && vectorCounts[id] == 0 {
+			continue
+		}
+		report.CheckedFiles++
+
+		issue := consistencyIssue{
+			FileID:   id,
+			Filename: file.Filename,
+			Status:   file.Status,
+			Expected: expected,
+			Actual:   vectorCounts[id],
+		}
+		switch {
+		case issue.Actual == 0:
+			report.MissingVectors = append(report.MissingVectors, issue)
+		case issue.Actual != expected:
+			report.Mismatched = append(report.Mismatched, issue)
+		}
+	}
+
+	for id, count := range vectorCounts {
+		if !known[id] {
+			report.Orphaned = append(report.Orphaned, consistencyIssue{FileID: id, Actual: count})
+		}
+	}
+
+	report.Consistent = len(report.Mismatched) == 0 && len(report.MissingVectors) == 0 &&
+		len(report.Orphaned) == 0 && report.StaleModelChunks == 0
+	return report, nil
+}
+
+// countVectorsByFile 分页读取集合中所有记录的元数据，按 file_id 统计向量数量以及由旧模型生成的向量数量
+func countVectorsByFile(ctx context.Context) (*vectorStats, error) {
+	chromaClient := services.NewChromaClient()
+	stats := &vectorStats{
+		counts:      map[string]int{},
+		staleCounts: map[string]int{},
+		staleModels: map[string]map[string]bool{},
+	}
+
+	for offset := 0; ; offset += consistencyPageSize {
+		result, err := chromaClient.GetDocuments(ctx, services.CollectionName(), &services.ChromaGetRequest{
+			Include: []string{"metadatas"},
+			Limit:   consistencyPageSize,
+			Offset:  offset,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("读取向量库失败: %w", err)
+		}
+
+		for _, metadata := range result.Metadatas {
+			fileID, _ := metadata["file_id"].(string)
+			stats.counts[fileID]++
+
+			model, _ := metadata[services.EmbeddingModelKey].(string)
+			switch {
+			case model == "":
+				stats.unknownModel++
+			case services.IsStaleEmbeddingModel(model):
+				stats.staleCounts[fileID]++
+				if stats.staleModels[fileID] == nil {
+					stats.staleModels[fileID] = map[string]bool{}
+				}
+				stats.staleModels[fileID][model] = true
+			}
+		}
+		stats.total += len(result.IDs)
+
+		if len(result.IDs) < consistencyPageSize {
+			break
+		}
+	}
+
+	return stats, nil
+}
+
+// repairFileVectors 清除文件的向量并重新提交任务，文件正在被其他操作占用或已暂停处理时跳过
+func repairFileVectors(ctx context.Context, fileID string) repairAction {
+	action := repairAction{FileID: fileID}
+
+	var held int64
+	database.GetDB().Model(&models.FileRecord{}).Where("id = ? AND hold = ?", fileID, true).Count(&held)
+	if held > 0 {
+		action.Action = "skipped"
+		action.Error = "文件已暂停处理"
+		return action
+	}
+
+	lock, err := queue.AcquireFileLock(ctx, fileID, 0)
+	if err != nil {
+		action.Action = "skipped"
+		action.Error = err.Error()
+		return action
+	}
+
+	db := database.GetDB()
+	var chunkCount int64
+	db.Model(&models.DocumentChunk{}).Where("file_id = ?", fileID).Count(&chunkCount)
+
+	// 原始文件已清理且没有块文本时无法重新处理
+	var purged int64
+	db.Model(&models.FileRecord{}).Where("id = ? AND file_purged = ?", fileID, true).Count(&purged)
+	if chunkCount == 0 && purged > 0 {
+		lock.Release()
+		action.Action = "skipped"
+		action.Error = "原始文件已按保留策略清理"
+		return action
+	}
+
+	err = services.NewChromaClient().DeleteDocumentsByFileID(ctx, services.CollectionName(), fileID)
+	services.InvalidateSearchCache(fileID)
+	if err == nil {
+		err = db.Model(&models.FileRecord{}).Where("id = ?", fileID).Updates(map[string]interface{}{
+			"status":  "pending",
+			"message": "一致性修复，已加入处理队列...",
+		}).Error
+	}
+	// 先释放锁，避免处理任务因锁被占用而推迟
+	lock.Release()
+	if err != nil {
+		action.Action = "skipped"
+		action.Error = err.Error()
+		return action
+	}
+
+	// 有保存的块文本时只需重新向量化，否则重新解析整个文件
+	if chunkCount > 0 {
+		action.Action = "reembed"
+		_, err = queue.EnqueueReembedDocument(fileID)
+	} else {
+		action.Action = "reprocess"
+		_, err = queue.EnqueueProcessDocument(fileID)
+	}
+	if err != nil {
+		action.Error = err.Error()
+	}
+	return action
+}
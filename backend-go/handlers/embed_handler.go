@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/services"
+	"doc-analysis-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EmbedHandler struct{}
+
+func NewEmbedHandler() *EmbedHandler {
+	return &EmbedHandler{}
+}
+
+type EmbedRequest struct {
+	Text string `json:"text"`
+}
+
+// EmbedResponse 文本的向量及实际使用的模型，主服务不可用改用备用服务时模型为 EMBEDDING_FALLBACK_MODEL
+type EmbedResponse struct {
+	Embedding []float32 `json:"embedding"`
+	Dimension int       `json:"dimension"`
+	Model     string    `json:"model"`
+	Tokens    int       `json:"tokens"` // 估算的 token 数
+}
+
+// Embed 使用当前配置的向量化服务为任意文本生成向量，用于验证向量化服务是否正常，
+// 或在客户端自行计算相似度。文本经过与文档块相同的预处理
+func (h *EmbedHandler) Embed(c *gin.Context) {
+	var req EmbedRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	var errs utils.ValidationErrors
+	text := strings.TrimSpace(req.Text)
+	tokens := services.EstimateTokens(text)
+	if errs.Check(text != "", "text", "text 不能为空") {
+		maxTokens := config.AppConfig.EmbedAPI.MaxTokens
+		errs.Check(tokens <= maxTokens, "text", fmt.Sprintf("text 过长，估算 token 数不能超过 %d", maxTokens))
+	}
+	if len(errs) > 0 {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+
+	embeddings, models, failures := services.NewEmbeddingClient().EmbedPartial(c.Request.Context(), []string{text}, 1)
+	if err := failures[0]; err != nil {
+		if errors.Is(err, services.ErrEmbeddingCircuitOpen) {
+			retryAfter := int(services.EmbeddingBreakerRetryAfter().Seconds()) + 1
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			utils.Error(c, http.StatusServiceUnavailable, "向量化服务熔断中，请稍后重试")
+			return
+		}
+		utils.Error(c, http.StatusBadGateway, fmt.Sprintf("向量化失败: %v", err))
+		return
+	}
+
+	utils.Success(c, EmbedResponse{
+		Embedding: embeddings[0],
+		Dimension: len(embeddings[0]),
+		Model:     models[0],
+		Tokens:    tokens,
+	})
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"fmt"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/models"
+	"doc-analysis-backend/queue"
+	"doc-analysis-backend/services"
+	"doc-analysis-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EstimateHandler struct{}
+
+func NewEstimateHandler() *EstimateHandler {
+	return &EstimateHandler{}
+}
+
+// 指定 file_ids 或 all_pending=true 之一
+type EstimateRequest struct {
+	FileIDs    []string `json:"file_ids"`
+	AllPending bool     `json:"all_pending"`
+}
+
+type fileEstimate struct {
+	FileID         string  `json:"file_id"`
+	Filename       string  `json:"filename"`
+	TotalPages     int     `json:"total_pages"`
+	ChunksCount    int     `json:"chunks_count"`
+	OriginalChunks int     `json:"original_chunks,omitempty"` // 超过 MAX_CHUNKS 被截断时为截断前的块数量，chunks_count 和 tokens 只计算保留的块
+	Tokens         int     `json:"tokens"`
+	EstimatedCost  float64 `json:"estimated_cost"`
+	Error          string  `json:"error,omitempty"`
+}
+
+// Estimate 解析并分块文档（不调用向量化接口），按 token 数估算向量化成本
+func (h *EstimateHandler) Estimate(c *gin.Context) {
+	var req EstimateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "请求参数格式错误")
+		return
+	}
+	if len(req.FileIDs) == 0 && !req.AllPending {
+		utils.BadRequest(c, "请指定 file_ids 或设置 all_pending=true")
+		return
+	}
+
+	db := database.GetDB()
+	var files []models.FileRecord
+	query := db.Scopes(tenantFiles(c)).Where("status = ?", "pending")
+	if len(req.FileIDs) > 0 {
+		query = db.Scopes(tenantFiles(c)).Where("id IN ?", req.FileIDs)
+	}
+	if err := query.Find(&files).Error; err != nil {
+		utils.InternalError(c, "获取文件列表失败")
+		return
+	}
+	if len(files) == 0 {
+		utils.NotFound(c, "没有需要估算的文件")
+		return
+	}
+
+	settings, err := database.GetProcessingSettings()
+	if err != nil {
+		utils.InternalError(c, fmt.Sprintf("获取处理配置失败: %v", err))
+		return
+	}
+
+	pricePer1K := config.AppConfig.Embedding.PricePer1KTokens
+	estimates := make([]fileEstimate, 0, len(files))
+	totalTokens, totalChunks := 0, 0
+	for i := range files {
+		file := &files[i]
+		estimate := fileEstimate{
+			FileID:   file.ID.String(),
+			Filename: file.Filename,
+		}
+
+		analysis, err := queue.AnalyzeDocument(file, settings)
+		if err != nil {
+			estimate.Error = err.Error()
+			estimates = append(estimates, estimate)
+			continue
+		}
+
+		for _, chunk := range analysis.Chunks {
+			estimate.Tokens += services.EstimateTokens(chunk.Content)
+		}
+		estimate.TotalPages = analysis.TotalPages
+		estimate.ChunksCount = len(analysis.Chunks)
+		if analysis.OriginalChunks > estimate.ChunksCount {
+			estimate.OriginalChunks = analysis.OriginalChunks
+		}
+		estimate.EstimatedCost = float64(estimate.Tokens) / 1000 * pricePer1K
+		estimates = append(estimates, estimate)
+
+		totalTokens += estimate.Tokens
+		totalChunks += estimate.ChunksCount
+	}
+
+	utils.Success(c, map[string]interface{}{
+		"files":               estimates,
+		"total_files":         len(files),
+		"total_chunks":        totalChunks,
+		"total_tokens":        totalTokens,
+		"price_per_1k_tokens": pricePer1K,
+		"estimated_cost":      float64(totalTokens) / 1000 * pricePer1K,
+		"model":               config.AppConfig.Embedding.Model,
+	})
+}
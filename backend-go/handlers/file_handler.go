@@ -1,21 +1,28 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"doc-analysis-backend/config"
 	"doc-analysis-backend/database"
 	"doc-analysis-backend/models"
 	"doc-analysis-backend/queue"
+	"doc-analysis-backend/services"
 	"doc-analysis-backend/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type FileHandler struct{}
@@ -36,30 +43,57 @@ func (h *FileHandler) UploadFiles(c *gin.Context) {
 
 	files := form.File["files"]
 	fmt.Printf("Found %d files in form\n", len(files))
-	if len(files) == 0 {
-		utils.BadRequest(c, "未选择文件")
+
+	// 先校验全部字段和文件再保存，所有错误一次返回
+	var errs utils.ValidationErrors
+	cfg := config.AppConfig
+	errs.Check(len(files) > 0, "files", "未选择文件")
+	for i, fileHeader := range files {
+		field := fmt.Sprintf("files[%d]", i)
+		// 客户端提供的文件名可能带有路径（如 ../evil.pdf）或首尾空白，清理后再校验和保存
+		fileHeader.Filename = services.SanitizeFilename(fileHeader.Filename)
+		if !services.IsAllowedFileType(fileHeader.Filename, cfg.Upload.AllowExt) {
+			errs.Add(field, fmt.Sprintf("不支持的文件类型: %s", fileHeader.Filename))
+		} else if fileHeader.Size > cfg.Upload.MaxSize {
+			errs.Add(field, fmt.Sprintf("文件过大: %s", fileHeader.Filename))
+		}
+	}
+
+	chunkSize, chunkOverlap, err := parseChunkingOverrides(form, &errs)
+	if err != nil {
+		utils.InternalError(c, err.Error())
 		return
 	}
 
-	cfg := config.AppConfig
-	os.MkdirAll(cfg.Upload.Dir, 0755)
+	metadata, err := parseUploadMetadata(form)
+	if err != nil {
+		errs.Add("metadata", err.Error())
+	}
+
+	var chunkStrategy *string
+	if values := form.Value["chunk_strategy"]; len(values) > 0 && strings.TrimSpace(values[0]) != "" {
+		strategy := strings.TrimSpace(values[0])
+		if errs.Check(services.IsValidChunkStrategy(strategy), "chunk_strategy",
+			fmt.Sprintf("不支持的分块策略: %s (可选 fixed/sentence/recursive)", strategy)) {
+			chunkStrategy = &strategy
+		}
+	}
+
+	if len(errs) > 0 {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+
+	// 目录在运行期间可能被删除或失去写权限，启动时的检查不能完全代替这里的检查
+	if err := services.EnsureUploadDir(); err != nil {
+		utils.InternalError(c, fmt.Sprintf("上传目录不可用，请联系管理员: %v", err))
+		return
+	}
 
 	var uploadedFiles []map[string]interface{}
 	db := database.GetDB()
 
 	for _, fileHeader := range files {
-		// 验证文件类型
-		if !isValidFileType(fileHeader.Filename, cfg.Upload.AllowExt) {
-			utils.BadRequest(c, fmt.Sprintf("不支持的文件类型: %s", fileHeader.Filename))
-			return
-		}
-
-		// 验证文件大小
-		if fileHeader.Size > cfg.Upload.MaxSize {
-			utils.BadRequest(c, fmt.Sprintf("文件过大: %s", fileHeader.Filename))
-			return
-		}
-
 		// 生成文件ID和路径
 		fileID := uuid.New()
 		fileExt := filepath.Ext(fileHeader.Filename)
@@ -74,6 +108,7 @@ func (h *FileHandler) UploadFiles(c *gin.Context) {
 		// 创建数据库记录
 		fileRecord := &models.FileRecord{
 			ID:       fileID,
+			TenantID: tenantID(c),
 			Filename: fileHeader.Filename,
 			Filepath: filePath,
 			FileSize: fileHeader.Size,
@@ -81,6 +116,12 @@ func (h *FileHandler) UploadFiles(c *gin.Context) {
 			Status:   "pending",
 			Progress: 0,
 			Message:  "等待处理中...",
+
+			ChunkSize:     chunkSize,
+			ChunkOverlap:  chunkOverlap,
+			ChunkStrategy: chunkStrategy,
+			Metadata:      metadata,
+			Collection:    services.RouteCollection(metadata),
 		}
 
 		if err := db.Create(fileRecord).Error; err != nil {
@@ -98,17 +139,43 @@ func (h *FileHandler) UploadFiles(c *gin.Context) {
 	}
 
 	// 直接返回与 Python 版本兼容的格式
-	c.JSON(200, map[string]interface{}{
+	response := map[string]interface{}{
 		"files":   uploadedFiles,
 		"message": fmt.Sprintf("成功上传 %d 个文件", len(uploadedFiles)),
-	})
+	}
+	if depth := queue.CheckBackpressure(); depth != nil {
+		response["warning"] = backpressureWarning(depth)
+	}
+	c.JSON(200, response)
+}
+
+// GetUploadConfig 返回上传限制和任务队列的积压情况，客户端可据此控制上传和提交处理的速度
+func (h *FileHandler) GetUploadConfig(c *gin.Context) {
+	cfg := config.AppConfig.Upload
+	data := map[string]interface{}{
+		"max_size":           cfg.MaxSize,
+		"allowed_extensions": cfg.AllowExt,
+		"max_concurrent":     cfg.MaxConcurrent,
+		"queue":              nil,
+	}
+	depth, err := queue.InspectQueueDepth()
+	if err != nil {
+		data["queue_error"] = err.Error()
+	} else {
+		data["queue"] = depth
+	}
+	utils.Success(c, data)
+}
+
+func backpressureWarning(depth *queue.QueueDepth) string {
+	return fmt.Sprintf("任务队列中有 %d 个任务等待执行，超过阈值 %d，请放慢提交速度", depth.Depth, depth.Threshold)
 }
 
 func (h *FileHandler) GetAllFilesStatus(c *gin.Context) {
 	db := database.GetDB()
 	var files []models.FileRecord
 
-	if err := db.Order("created_at DESC").Find(&files).Error; err != nil {
+	if err := db.Scopes(tenantFiles(c)).Order("created_at DESC").Find(&files).Error; err != nil {
 		utils.InternalError(c, "获取文件列表失败")
 		return
 	}
@@ -129,12 +196,204 @@ func (h *FileHandler) GetFileStatus(c *gin.Context) {
 	db := database.GetDB()
 	var file models.FileRecord
 
-	if err := db.Where("id = ?", fileID).First(&file).Error; err != nil {
+	if err := db.Scopes(tenantFiles(c)).Where("id = ?", fileID).First(&file).Error; err != nil {
 		utils.NotFound(c, "文件不存在")
 		return
 	}
 
-	utils.Success(c, file)
+	utils.Success(c, fileStatusResponse{
+		FileRecord:         file,
+		ProcessingSettings: usedProcessingSettings(fileID),
+	})
+}
+
+// fileStatusResponse 文件状态，文件记录的字段保持在顶层，附带最近一次处理实际使用的配置
+type fileStatusResponse struct {
+	models.FileRecord
+	ProcessingSettings *UsedProcessingSettings `json:"processing_settings,omitempty"`
+}
+
+// UsedProcessingSettings 文件现有的块和向量实际使用的配置，来自处理记录，之后修改全局配置不影响这里的值
+type UsedProcessingSettings struct {
+	ChunkSize     int    `json:"chunk_size"`
+	ChunkOverlap  int    `json:"chunk_overlap"`
+	ChunkStrategy string `json:"chunk_strategy"`
+	// 重新向量化或重试失败的块之后为最近一次使用的模型
+	EmbeddingModel string `json:"embedding_model"`
+	// 分块配置所在的处理记录序号，可在 /api/files/:id/runs 中查看详情
+	RunNumber int `json:"run_number"`
+}
+
+// usedProcessingSettings 分块配置取最近一次完整处理的记录，重新向量化和重试失败的块不重新分块，只更新模型；
+// 文件尚未处理过时返回 nil
+func usedProcessingSettings(fileID string) *UsedProcessingSettings {
+	db := database.GetDB()
+	var process models.ProcessingRun
+	if err := db.Where("file_id = ? AND type = ?", fileID, "process").Order("run_number DESC").First(&process).Error; err != nil {
+		return nil
+	}
+	used := &UsedProcessingSettings{
+		ChunkSize:      process.ChunkSize,
+		ChunkOverlap:   process.ChunkOverlap,
+		ChunkStrategy:  process.ChunkStrategy,
+		EmbeddingModel: process.EmbeddingModel,
+		RunNumber:      process.RunNumber,
+	}
+
+	var embed models.ProcessingRun
+	err := db.Where("file_id = ? AND type IN ? AND run_number > ?", fileID, []string{"reembed", "retry_failed"}, process.RunNumber).
+		Order("run_number DESC").First(&embed).Error
+	if err == nil {
+		used.EmbeddingModel = embed.EmbeddingModel
+	}
+	return used
+}
+
+func (h *FileHandler) GetFileLogs(c *gin.Context) {
+	fileID := c.Param("id")
+	if fileID == "" {
+		utils.BadRequest(c, "文件ID不能为空")
+		return
+	}
+
+	db := database.GetDB()
+	var file models.FileRecord
+
+	if err := db.Scopes(tenantFiles(c)).Where("id = ?", fileID).First(&file).Error; err != nil {
+		utils.NotFound(c, "文件不存在")
+		return
+	}
+
+	var logs []models.ProcessingLog
+	if err := db.Where("file_id = ?", fileID).Order("created_at ASC").Find(&logs).Error; err != nil {
+		utils.InternalError(c, "获取处理日志失败")
+		return
+	}
+
+	// 直接返回与 Python 版本兼容的格式
+	c.JSON(200, map[string]interface{}{
+		"file_id": fileID,
+		"logs":    logs,
+	})
+}
+
+// GetFileRuns 按处理顺序返回文件的历次处理记录，可对比调整配置或更换模型前后的结果
+func (h *FileHandler) GetFileRuns(c *gin.Context) {
+	fileID := c.Param("id")
+
+	db := database.GetDB()
+	var file models.FileRecord
+	if err := db.Scopes(tenantFiles(c)).Select("id").Where("id = ?", fileID).First(&file).Error; err != nil {
+		utils.NotFound(c, "文件不存在")
+		return
+	}
+
+	var runs []models.ProcessingRun
+	if err := db.Where("file_id = ?", fileID).Order("run_number ASC").Find(&runs).Error; err != nil {
+		utils.InternalError(c, "获取处理记录失败")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"file_id": fileID,
+		"runs":    runs,
+	})
+}
+
+// GetFileChunks 按块序号分页返回文件的文本块。page_size 超过 CHUNKS_MAX_PAGE_SIZE 时按上限返回，
+// 分页信息中的 page_size 为实际生效的值
+func (h *FileHandler) GetFileChunks(c *gin.Context) {
+	fileID := c.Param("id")
+	if fileID == "" {
+		utils.BadRequest(c, "文件ID不能为空")
+		return
+	}
+
+	db := database.GetDB()
+	var file models.FileRecord
+
+	if err := db.Scopes(tenantFiles(c)).Where("id = ?", fileID).First(&file).Error; err != nil {
+		utils.NotFound(c, "文件不存在")
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		utils.BadRequest(c, "page 必须是大于 0 的整数")
+		return
+	}
+	requested, err := strconv.Atoi(c.DefaultQuery("page_size", "0"))
+	if err != nil {
+		utils.BadRequest(c, "page_size 必须是整数")
+		return
+	}
+	pageSize := clampPageSize(requested)
+
+	var total int64
+	if err := db.Model(&models.DocumentChunk{}).Where("file_id = ?", fileID).Count(&total).Error; err != nil {
+		utils.InternalError(c, "获取文本块失败")
+		return
+	}
+
+	chunks := make([]models.DocumentChunk, 0, pageSize)
+	if err := db.Where("file_id = ?", fileID).Order("chunk_index ASC").
+		Offset((page - 1) * pageSize).Limit(pageSize).Find(&chunks).Error; err != nil {
+		utils.InternalError(c, "获取文本块失败")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"file_id": fileID,
+		"chunks":  chunks,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
+			"clamped":     requested > pageSize,
+		},
+	})
+}
+
+// clampPageSize 未指定时使用 CHUNKS_DEFAULT_PAGE_SIZE，超过 CHUNKS_MAX_PAGE_SIZE 时按上限处理
+func clampPageSize(pageSize int) int {
+	cfg := config.AppConfig.Chunks
+	if pageSize <= 0 {
+		return cfg.DefaultPageSize
+	}
+	if pageSize > cfg.MaxPageSize {
+		return cfg.MaxPageSize
+	}
+	return pageSize
+}
+
+// DownloadFile 下载上传的原始文件，已按保留策略清理的文件返回 410
+func (h *FileHandler) DownloadFile(c *gin.Context) {
+	fileID := c.Param("id")
+	if fileID == "" {
+		utils.BadRequest(c, "文件ID不能为空")
+		return
+	}
+
+	db := database.GetDB()
+	var file models.FileRecord
+
+	if err := db.Scopes(tenantFiles(c)).Where("id = ?", fileID).First(&file).Error; err != nil {
+		utils.NotFound(c, "文件不存在")
+		return
+	}
+
+	if file.FilePurged {
+		utils.Error(c, http.StatusGone, "原始文件已按保留策略清理")
+		return
+	}
+	if _, err := os.Stat(file.Filepath); err != nil {
+		utils.NotFound(c, "原始文件不存在")
+		return
+	}
+
+	// 清理文件名之前上传的记录可能带有路径或控制字符
+	c.FileAttachment(file.Filepath, services.SanitizeFilename(file.Filename))
 }
 
 func (h *FileHandler) ProcessFile(c *gin.Context) {
@@ -147,22 +406,100 @@ func (h *FileHandler) ProcessFile(c *gin.Context) {
 	db := database.GetDB()
 	var file models.FileRecord
 
-	if err := db.Where("id = ?", fileID).First(&file).Error; err != nil {
+	if err := db.Scopes(tenantFiles(c)).Where("id = ?", fileID).First(&file).Error; err != nil {
 		utils.NotFound(c, "文件不存在")
 		return
 	}
 
+	force, _ := strconv.ParseBool(c.DefaultQuery("force", "false"))
+	// 为空时处理时使用 PROCESSING_STORE_MODE
+	storeMode := c.Query("store_mode")
+	if storeMode != "" && !config.IsValidStoreMode(storeMode) {
+		utils.BadRequest(c, "store_mode 只能是 chroma、noop 或 temp")
+		return
+	}
+
 	// 检查文件状态
-	if file.Status == "processing" || file.Status == "completed" {
-		utils.BadRequest(c, "文件正在处理或已完成")
+	if file.FilePurged {
+		utils.Error(c, http.StatusGone, "原始文件已按保留策略清理，无法重新处理")
+		return
+	}
+	if isProcessingStatus(file.Status) {
+		utils.BadRequest(c, "文件正在处理中，请等待当前任务完成")
+		return
+	}
+	if file.Status == "completed" && queue.IsArchiveFile(file.Filename) {
+		utils.BadRequest(c, "压缩包已解压，请直接处理其中的文件")
+		return
+	}
+	// 自动重试已用完的失败通常需要先排除原因（如文件损坏、配置错误），直接重新处理大概率仍会失败
+	if file.Status == "failed_permanent" && !force {
+		utils.BadRequest(c, "文件已达到最大重试次数仍处理失败，请确认失败原因已排除后添加 force=true 参数重新处理")
+		return
+	}
+	// 部分完成的文件同样已有向量，重新处理前需要清除
+	hasVectors := file.Status == "completed" || file.Status == "partial"
+	if hasVectors && !force {
+		utils.BadRequest(c, "文件已处理完成，如需重新处理请添加 force=true 参数")
 		return
 	}
 
+	updates := map[string]interface{}{
+		"status":     "pending",
+		"message":    "已加入处理队列...",
+		"store_mode": storeMode,
+	}
+
+	// 强制重新处理已完成的文件时，先清除旧的向量数据
+	if hasVectors {
+		chromaClient := services.NewChromaClient()
+		if err := chromaClient.DeleteDocumentsByFileID(c.Request.Context(), services.ShardCollection(file.Collection, file.Shard), fileID); err != nil {
+			utils.InternalError(c, fmt.Sprintf("删除旧向量数据失败: %v", err))
+			return
+		}
+		services.InvalidateSearchCache(fileID)
+		updates["progress"] = 0
+		updates["chunks_count"] = 0
+	}
+
 	// 更新状态为等待处理
-	db.Model(&file).Updates(map[string]interface{}{
-		"status":  "pending",
-		"message": "已加入处理队列...",
-	})
+	db.Model(&file).Updates(updates)
+
+	if wantSyncProcessing(c, &file) {
+		status, err := queue.ProcessDocumentSync(fileID, config.AppConfig.Processing.SyncTimeout)
+		switch {
+		case err == nil:
+			db.Scopes(tenantFiles(c)).Where("id = ?", fileID).First(&file)
+			utils.SuccessWithMessage(c, "文件处理完成", map[string]interface{}{
+				"file_id": fileID,
+				"mode":    "sync",
+				"status":  status,
+				"file":    file,
+			})
+			return
+		case errors.Is(err, queue.ErrSyncTimeout):
+			// 超出时间预算，改为提交异步任务。进度只增不减，从 0 开始重新计算
+			db.Model(&file).Updates(map[string]interface{}{
+				"status":   "pending",
+				"progress": 0,
+				"message":  "同步处理超时，已加入处理队列...",
+			})
+		case errors.Is(err, queue.ErrFileLocked):
+			utils.Error(c, http.StatusConflict, "文件正在被其他操作占用，请稍后再试")
+			return
+		case status == "error":
+			utils.SuccessWithMessage(c, "文件处理失败", map[string]interface{}{
+				"file_id": fileID,
+				"mode":    "sync",
+				"status":  status,
+				"error":   err.Error(),
+			})
+			return
+		default:
+			utils.InternalError(c, fmt.Sprintf("同步处理失败: %v", err))
+			return
+		}
+	}
 
 	// 提交到任务队列
 	taskInfo, err := queue.EnqueueProcessDocument(fileID)
@@ -171,35 +508,240 @@ func (h *FileHandler) ProcessFile(c *gin.Context) {
 		return
 	}
 
-	utils.SuccessWithMessage(c, "文件已加入处理队列", map[string]interface{}{
+	data := map[string]interface{}{
 		"file_id": fileID,
 		"task_id": taskInfo.ID,
+		"mode":    "async",
+	}
+	// 手动处理单个文件不受积压限制，只提示
+	if depth := queue.CheckBackpressure(); depth != nil {
+		data["warning"] = backpressureWarning(depth)
+	}
+	utils.SuccessWithMessage(c, "文件已加入处理队列", data)
+}
+
+// wantSyncProcessing 判断是否在请求中直接处理: sync=true 或开启了 SYNC_PROCESS_AUTO 时，
+// 不超过 SYNC_PROCESS_MAX_KB 的非压缩包文件同步处理；向量化服务不可用或熔断时始终异步
+func wantSyncProcessing(c *gin.Context, file *models.FileRecord) bool {
+	cfg := config.AppConfig.Processing
+	requested := cfg.SyncAuto
+	if value := c.Query("sync"); value != "" {
+		requested, _ = strconv.ParseBool(value)
+	}
+	if !requested {
+		return false
+	}
+
+	return file.FileSize <= cfg.SyncMaxSize &&
+		!queue.IsArchiveFile(file.Filename) &&
+		services.EmbeddingAvailable() &&
+		!services.EmbeddingCircuitOpen()
+}
+
+// ReembedFile 更换向量化模型后，用数据库中保存的块文本重新生成向量，不重新解析 PDF
+func (h *FileHandler) ReembedFile(c *gin.Context) {
+	fileID := c.Param("id")
+	if fileID == "" {
+		utils.BadRequest(c, "文件ID不能为空")
+		return
+	}
+
+	db := database.GetDB()
+	var file models.FileRecord
+
+	if err := db.Scopes(tenantFiles(c)).Where("id = ?", fileID).First(&file).Error; err != nil {
+		utils.NotFound(c, "文件不存在")
+		return
+	}
+
+	// 处理中或排队中的文件稍后会写入新的向量，不能同时重新向量化
+	if file.Status == "pending" || isProcessingStatus(file.Status) {
+		utils.BadRequest(c, "文件正在处理中，请等待当前任务完成")
+		return
+	}
+
+	var chunkCount int64
+	if err := db.Model(&models.DocumentChunk{}).Where("file_id = ?", fileID).Count(&chunkCount).Error; err != nil {
+		utils.InternalError(c, "查询文档块失败")
+		return
+	}
+	if chunkCount == 0 {
+		utils.BadRequest(c, "文件没有保存的块文本，请使用 force=true 重新处理")
+		return
+	}
+
+	db.Model(&file).Updates(map[string]interface{}{
+		"status":  "pending",
+		"message": "已加入重新向量化队列...",
+	})
+
+	taskInfo, err := queue.EnqueueReembedDocument(fileID)
+	if err != nil {
+		db.Model(&file).Updates(map[string]interface{}{
+			"status":  file.Status,
+			"message": file.Message,
+		})
+		utils.InternalError(c, fmt.Sprintf("提交任务失败: %v", err))
+		return
+	}
+
+	utils.SuccessWithMessage(c, "文件已加入重新向量化队列", map[string]interface{}{
+		"file_id":      fileID,
+		"task_id":      taskInfo.ID,
+		"chunks_count": chunkCount,
+	})
+}
+
+// RetryFailedChunks 只重新向量化部分完成的文件中失败的块
+func (h *FileHandler) RetryFailedChunks(c *gin.Context) {
+	fileID := c.Param("id")
+	if fileID == "" {
+		utils.BadRequest(c, "文件ID不能为空")
+		return
+	}
+
+	db := database.GetDB()
+	var file models.FileRecord
+
+	if err := db.Scopes(tenantFiles(c)).Where("id = ?", fileID).First(&file).Error; err != nil {
+		utils.NotFound(c, "文件不存在")
+		return
+	}
+
+	if file.Status == "pending" || isProcessingStatus(file.Status) {
+		utils.BadRequest(c, "文件正在处理中，请等待当前任务完成")
+		return
+	}
+	// 重试失败后文件会变为 error，但失败的块仍然保留，可以继续重试
+	if len(file.FailedChunks) == 0 {
+		utils.BadRequest(c, "文件没有向量化失败的块")
+		return
+	}
+
+	db.Model(&file).Updates(map[string]interface{}{
+		"status":  "pending",
+		"message": "已加入重试队列...",
+	})
+
+	taskInfo, err := queue.EnqueueRetryFailedChunks(fileID)
+	if err != nil {
+		db.Model(&file).Updates(map[string]interface{}{
+			"status":  file.Status,
+			"message": file.Message,
+		})
+		utils.InternalError(c, fmt.Sprintf("提交任务失败: %v", err))
+		return
+	}
+
+	utils.SuccessWithMessage(c, "失败的块已加入重试队列", map[string]interface{}{
+		"file_id":       fileID,
+		"task_id":       taskInfo.ID,
+		"failed_chunks": len(file.FailedChunks),
 	})
 }
 
+// HoldFile 暂停处理文件，用于隔离有问题的文档: 批量处理、一致性修复和自动重试都会跳过该文件，
+// 已在执行的任务不受影响
+func (h *FileHandler) HoldFile(c *gin.Context) {
+	setFileHold(c, true)
+}
+
+// UnholdFile 取消暂停，pending 状态的文件在下次批量处理时提交
+func (h *FileHandler) UnholdFile(c *gin.Context) {
+	setFileHold(c, false)
+}
+
+func setFileHold(c *gin.Context, hold bool) {
+	fileID := c.Param("id")
+	db := database.GetDB()
+	var file models.FileRecord
+	if err := db.Scopes(tenantFiles(c)).Where("id = ?", fileID).First(&file).Error; err != nil {
+		utils.NotFound(c, "文件不存在")
+		return
+	}
+
+	if file.Hold != hold {
+		if err := db.Model(&file).Update("hold", hold).Error; err != nil {
+			utils.InternalError(c, "更新文件失败")
+			return
+		}
+	}
+
+	message := "文件已暂停处理"
+	if !hold {
+		message = "文件已取消暂停"
+	}
+	utils.SuccessWithMessage(c, message, file)
+}
+
+// ProcessAllFiles 提交所有待处理文件。任务队列积压超过 QUEUE_BACKPRESSURE_THRESHOLD 时返回 503，
+// 避免一次提交大量任务加剧积压
 func (h *FileHandler) ProcessAllFiles(c *gin.Context) {
+	if depth := queue.CheckBackpressure(); depth != nil {
+		c.Header("Retry-After", "60")
+		utils.Error(c, http.StatusServiceUnavailable, backpressureWarning(depth))
+		return
+	}
+
 	db := database.GetDB()
 	var files []models.FileRecord
 
-	if err := db.Where("status = ?", "pending").Find(&files).Error; err != nil {
+	// 暂停处理的文件保持 pending，取消暂停后再次批量处理时提交
+	if err := db.Scopes(tenantFiles(c)).Where("status = ? AND hold = ?", "pending", false).Find(&files).Error; err != nil {
 		utils.InternalError(c, "获取待处理文件失败")
 		return
 	}
 
-	var taskIDs []string
+	// 单个文件入队失败不影响其他文件，逐个返回结果
+	taskIDs := []string{}
+	results := make([]enqueueResult, 0, len(files))
 	for _, file := range files {
+		result := enqueueResult{
+			FileID:   file.ID.String(),
+			Filename: file.Filename,
+		}
+
 		taskInfo, err := queue.EnqueueProcessDocument(file.ID.String())
 		if err != nil {
-			continue
+			// 保持 pending 状态以便稍后重试，并记录失败原因
+			result.Error = err.Error()
+			db.Model(&file).Updates(map[string]interface{}{
+				"status":     "pending",
+				"message":    fmt.Sprintf("加入处理队列失败: %v", err),
+				"last_error": err.Error(),
+			})
+		} else {
+			result.Success = true
+			result.TaskID = taskInfo.ID
+			taskIDs = append(taskIDs, taskInfo.ID)
+			db.Model(&file).Update("message", "已加入处理队列...")
 		}
-		taskIDs = append(taskIDs, taskInfo.ID)
+		results = append(results, result)
 	}
 
-	utils.SuccessWithMessage(c, fmt.Sprintf("已将 %d 个文件加入处理队列", len(files)), map[string]interface{}{
+	failed := len(files) - len(taskIDs)
+	message := fmt.Sprintf("已将 %d 个文件加入处理队列", len(taskIDs))
+	if failed > 0 {
+		message += fmt.Sprintf("，%d 个文件入队失败", failed)
+	}
+
+	utils.SuccessWithMessage(c, message, map[string]interface{}{
 		"task_ids": taskIDs,
+		"results":  results,
+		"queued":   len(taskIDs),
+		"failed":   failed,
 	})
 }
 
+// 批量处理时单个文件的入队结果
+type enqueueResult struct {
+	FileID   string `json:"file_id"`
+	Filename string `json:"filename"`
+	Success  bool   `json:"success"`
+	TaskID   string `json:"task_id,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
 func (h *FileHandler) DeleteFile(c *gin.Context) {
 	fileID := c.Param("id")
 	if fileID == "" {
@@ -210,61 +752,188 @@ func (h *FileHandler) DeleteFile(c *gin.Context) {
 	db := database.GetDB()
 	var file models.FileRecord
 
-	if err := db.Where("id = ?", fileID).First(&file).Error; err != nil {
+	if err := db.Scopes(tenantFiles(c)).Where("id = ?", fileID).First(&file).Error; err != nil {
 		utils.NotFound(c, "文件不存在")
 		return
 	}
 
-	// TODO: 删除向量数据库中的数据
-	
-	// 删除物理文件
-	if _, err := os.Stat(file.Filepath); err == nil {
-		os.Remove(file.Filepath)
+	if err := queue.DeleteFile(c.Request.Context(), &file, config.AppConfig.Lock.Wait); err != nil {
+		if errors.Is(err, queue.ErrFileLocked) {
+			utils.Error(c, http.StatusConflict, "文件正在处理中，请稍后再试")
+			return
+		}
+		utils.InternalError(c, err.Error())
+		return
 	}
 
-	// 删除数据库记录和相关日志
-	tx := db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
+	utils.SuccessWithMessage(c, "文件删除成功", map[string]interface{}{
+		"filename": file.Filename,
+	})
+}
+
+// 每次批量清理最多删除的文件数量，超出的部分通过响应中的 remaining 提示再次调用
+const cleanupBatchLimit = 500
+
+// CleanupRequest 批量清理的过滤条件，多个条件同时满足的文件才会被删除
+type CleanupRequest struct {
+	Status []string `json:"status"`
+	// 创建时间早于该时间的文件，RFC3339 格式或 2006-01-02
+	CreatedBefore string `json:"created_before"`
+	ErrorCountGte *int   `json:"error_count_gte"`
+	// 实际删除时必须为 true，dry_run 时可省略
+	Confirm bool `json:"confirm"`
+	DryRun  bool `json:"dry_run"`
+}
+
+// CleanupFile 一个被删除（或 dry_run 时将被删除）的文件
+type CleanupFile struct {
+	FileID     string    `json:"file_id"`
+	Filename   string    `json:"filename"`
+	Status     string    `json:"status"`
+	ErrorCount int       `json:"error_count"`
+	CreatedAt  time.Time `json:"created_at"`
+	Error      string    `json:"error,omitempty"`
+}
 
-	if err := tx.Where("file_id = ?", fileID).Delete(&models.ProcessingLog{}).Error; err != nil {
-		tx.Rollback()
-		utils.InternalError(c, "删除处理日志失败")
+// CleanupFiles 按状态、创建时间、失败次数批量删除文件，删除内容与 DeleteFile 相同。
+// 正在处理的文件不等待锁，直接记为失败，稍后再次调用即可
+func (h *FileHandler) CleanupFiles(c *gin.Context) {
+	var req CleanupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "请求参数错误: "+err.Error())
 		return
 	}
-
-	if err := tx.Where("file_id = ?", fileID).Delete(&models.Task{}).Error; err != nil {
-		tx.Rollback()
-		utils.InternalError(c, "删除任务记录失败")
+	if len(req.Status) == 0 && req.CreatedBefore == "" && req.ErrorCountGte == nil {
+		utils.BadRequest(c, "至少需要指定 status、created_before、error_count_gte 中的一个条件")
+		return
+	}
+	if !req.DryRun && !req.Confirm {
+		utils.BadRequest(c, "删除文件需要设置 confirm 为 true，或使用 dry_run 预览将被删除的文件")
 		return
 	}
 
-	if err := tx.Delete(&file).Error; err != nil {
-		tx.Rollback()
-		utils.InternalError(c, "删除文件记录失败")
+	query := database.GetDB().Model(&models.FileRecord{}).Scopes(tenantFiles(c))
+	if len(req.Status) > 0 {
+		query = query.Where("status IN ?", req.Status)
+	}
+	if req.CreatedBefore != "" {
+		before, err := parseCleanupTime(req.CreatedBefore)
+		if err != nil {
+			utils.BadRequest(c, "created_before 格式错误，应为 RFC3339 或 2006-01-02")
+			return
+		}
+		query = query.Where("created_at < ?", before)
+	}
+	if req.ErrorCountGte != nil {
+		query = query.Where("error_count >= ?", *req.ErrorCountGte)
+	}
+	// 计数和查询共用过滤条件
+	query = query.Session(&gorm.Session{})
+
+	var matched int64
+	if err := query.Count(&matched).Error; err != nil {
+		utils.InternalError(c, fmt.Sprintf("查询文件失败: %v", err))
+		return
+	}
+	var files []models.FileRecord
+	if err := query.Order("created_at").Limit(cleanupBatchLimit).Find(&files).Error; err != nil {
+		utils.InternalError(c, fmt.Sprintf("查询文件失败: %v", err))
 		return
 	}
 
-	tx.Commit()
+	results := make([]CleanupFile, 0, len(files))
+	deleted, failed := 0, 0
+	for _, file := range files {
+		item := CleanupFile{
+			FileID:     file.ID.String(),
+			Filename:   file.Filename,
+			Status:     file.Status,
+			ErrorCount: file.ErrorCount,
+			CreatedAt:  file.CreatedAt,
+		}
+		if !req.DryRun {
+			if err := queue.DeleteFile(c.Request.Context(), &file, 0); err != nil {
+				if errors.Is(err, queue.ErrFileLocked) {
+					item.Error = "文件正在处理中"
+				} else {
+					item.Error = err.Error()
+				}
+				failed++
+			} else {
+				deleted++
+			}
+		}
+		results = append(results, item)
+	}
 
-	utils.SuccessWithMessage(c, "文件删除成功", map[string]interface{}{
-		"filename": file.Filename,
+	utils.Success(c, map[string]interface{}{
+		"dry_run":   req.DryRun,
+		"matched":   matched,
+		"deleted":   deleted,
+		"failed":    failed,
+		"remaining": matched - int64(len(files)),
+		"files":     results,
 	})
 }
 
-func isValidFileType(filename string, allowedExt []string) bool {
-	ext := strings.ToLower(filepath.Ext(filename))
-	for _, allowed := range allowedExt {
-		if ext == allowed {
+func parseCleanupTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
+
+// 处理流水线中的各个阶段状态
+var processingStatuses = []string{"extracting", "parsing", "chunking", "embedding", "storing", "processing"}
+
+func isProcessingStatus(status string) bool {
+	for _, s := range processingStatuses {
+		if status == s {
 			return true
 		}
 	}
 	return false
 }
 
+// parseChunkingOverrides 解析上传表单中可选的 chunk_size / chunk_overlap，未提供的值返回 nil。
+// 字段错误记录在 errs 中，返回的 error 只表示读取全局配置失败
+func parseChunkingOverrides(form *multipart.Form, errs *utils.ValidationErrors) (*int, *int, error) {
+	parse := func(key string) (*int, bool) {
+		values := form.Value[key]
+		if len(values) == 0 || strings.TrimSpace(values[0]) == "" {
+			return nil, true
+		}
+		v, err := strconv.Atoi(strings.TrimSpace(values[0]))
+		if !errs.Check(err == nil, key, fmt.Sprintf("%s 必须是整数", key)) {
+			return nil, false
+		}
+		return &v, true
+	}
+
+	chunkSize, sizeOK := parse("chunk_size")
+	chunkOverlap, overlapOK := parse("chunk_overlap")
+	if !sizeOK || !overlapOK || (chunkSize == nil && chunkOverlap == nil) {
+		return nil, nil, nil
+	}
+
+	// 只提供其中一项时，另一项按当前全局配置校验
+	settings, err := database.GetProcessingSettings()
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取处理配置失败: %v", err)
+	}
+	effectiveSize, effectiveOverlap := settings.ChunkSize, settings.ChunkOverlap
+	if chunkSize != nil {
+		effectiveSize = *chunkSize
+	}
+	if chunkOverlap != nil {
+		effectiveOverlap = *chunkOverlap
+	}
+	checkChunking(errs, effectiveSize, effectiveOverlap)
+
+	return chunkSize, chunkOverlap, nil
+}
+
+// saveUploadedFile 先写入临时目录，完整写入后再移动到 dst，上传中断时不会在上传目录留下不完整的文件
 func saveUploadedFile(fh *multipart.FileHeader, dst string) error {
 	src, err := fh.Open()
 	if err != nil {
@@ -272,12 +941,85 @@ func saveUploadedFile(fh *multipart.FileHeader, dst string) error {
 	}
 	defer src.Close()
 
-	out, err := os.Create(dst)
+	out, err := services.CreateTempFile("upload-*")
 	if err != nil {
 		return err
 	}
-	defer out.Close()
+	// 移动成功后临时文件已不存在，删除只在出错时生效
+	defer os.Remove(out.Name())
+
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return services.MoveFile(out.Name(), dst)
+}
+
+const (
+	maxMetadataKeys  = 20
+	maxMetadataBytes = 2048
+)
+
+// 系统写入块元数据时使用的键，不允许被自定义元数据覆盖
+var reservedMetadataKeys = map[string]bool{
+	"file_id":     true,
+	"filename":    true,
+	"chunk_index": true,
+	"page_number": true,
+
+	services.EmbeddingModelKey: true,
+}
+
+// parseUploadMetadata 解析表单中的 metadata 字段，只允许值为字符串或数字的扁平 JSON 对象
+func parseUploadMetadata(form *multipart.Form) (map[string]interface{}, error) {
+	values := form.Value["metadata"]
+	if len(values) == 0 || strings.TrimSpace(values[0]) == "" {
+		return nil, nil
+	}
+	raw := strings.TrimSpace(values[0])
+	if len(raw) > maxMetadataBytes {
+		return nil, fmt.Errorf("metadata 不能超过 %d 字节", maxMetadataBytes)
+	}
 
-	_, err = io.Copy(out, src)
-	return err
-}
\ No newline at end of file
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.UseNumber()
+	var parsed map[string]interface{}
+	if err := decoder.Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("metadata 必须是 JSON 对象")
+	}
+	if len(parsed) > maxMetadataKeys {
+		return nil, fmt.Errorf("metadata 最多包含 %d 个字段", maxMetadataKeys)
+	}
+
+	metadata := make(map[string]interface{}, len(parsed))
+	for key, value := range parsed {
+		if strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("metadata 的字段名不能为空")
+		}
+		if reservedMetadataKeys[key] {
+			return nil, fmt.Errorf("metadata 字段 %s 为系统保留字段", key)
+		}
+
+		switch v := value.(type) {
+		case string:
+			metadata[key] = v
+		case json.Number:
+			if i, err := v.Int64(); err == nil {
+				metadata[key] = i
+			} else if f, err := v.Float64(); err == nil {
+				metadata[key] = f
+			} else {
+				return nil, fmt.Errorf("metadata 字段 %s 的数值无效", key)
+			}
+		default:
+			return nil, fmt.Errorf("metadata 字段 %s 的值只能是字符串或数字", key)
+		}
+	}
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	return metadata, nil
+}
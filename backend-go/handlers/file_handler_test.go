@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"doc-analysis-backend/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func withUploadConfig(t *testing.T, dir string) {
+	t.Helper()
+	saved := config.AppConfig
+	cfg := &config.Config{}
+	cfg.Upload.Dir = dir
+	cfg.Upload.TempDir = t.TempDir()
+	cfg.Upload.AllowExt = []string{".pdf"}
+	cfg.Upload.MaxSize = 1 << 20
+	cfg.Upload.FilenameMaxBytes = 255
+	config.AppConfig = cfg
+	t.Cleanup(func() { config.AppConfig = saved })
+}
+
+// uploadPDF 向 UploadFiles 提交一个 PDF 文件，返回响应
+func uploadPDF(t *testing.T) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("files", "report.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte("%PDF-1.4\n%%EOF\n"))
+	form.Close()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/upload-files", &body)
+	c.Request.Header.Set("Content-Type", form.FormDataContentType())
+	NewFileHandler().UploadFiles(c)
+	return w
+}
+
+func assertUploadInternalError(t *testing.T, w *httptest.ResponseRecorder, wantMessage string) {
+	t.Helper()
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500; body %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), wantMessage) {
+		t.Fatalf("body %s does not mention %q", w.Body.String(), wantMessage)
+	}
+}
+
+func TestUploadFilesUploadDirUnavailable(t *testing.T) {
+	t.Run("upload dir is a file", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "uploads")
+		if err := os.WriteFile(file, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		withUploadConfig(t, file)
+		assertUploadInternalError(t, uploadPDF(t), "上传目录不可用")
+	})
+
+	t.Run("upload dir removed", func(t *testing.T) {
+		withUploadConfig(t, filepath.Join(t.TempDir(), "removed"))
+		assertUploadInternalError(t, uploadPDF(t), "上传目录不可用")
+	})
+
+	t.Run("read-only upload dir", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "readonly")
+		if err := os.Mkdir(dir, 0555); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.Chmod(dir, 0755) })
+		if f, err := os.CreateTemp(dir, "probe-*"); err == nil {
+			f.Close()
+			t.Skip("directory permissions are not enforced for this user")
+		}
+		withUploadConfig(t, dir)
+		assertUploadInternalError(t, uploadPDF(t), "保存文件失败")
+	})
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct{}
+
+func NewHealthHandler() *HealthHandler {
+	return &HealthHandler{}
+}
+
+// Ready 就绪检查: 数据库可连接、向量化服务最近一次探测成功且未熔断时返回 200，否则返回 503
+func (h *HealthHandler) Ready(c *gin.Context) {
+	ready := true
+	checks := map[string]interface{}{}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+	if sqlDB, err := database.GetDB().DB(); err != nil {
+		ready = false
+		checks["database"] = err.Error()
+	} else if err := sqlDB.PingContext(ctx); err != nil {
+		ready = false
+		checks["database"] = err.Error()
+	} else {
+		checks["database"] = "ok"
+	}
+
+	// 尚未探测或未开启探测时不影响就绪状态
+	if status := services.EmbeddingHealth(); status != nil {
+		checks["embedding"] = status
+		if !status.Healthy {
+			ready = false
+		}
+	} else {
+		checks["embedding"] = "unknown"
+	}
+
+	// 未开启熔断时不返回该项；冷却结束后即使还没有发出试探请求也视为就绪
+	if breaker := services.EmbeddingBreaker(); breaker != nil {
+		checks["embedding_breaker"] = breaker
+		if services.EmbeddingCircuitOpen() {
+			ready = false
+		}
+	}
+
+	code := http.StatusOK
+	state := "ready"
+	if !ready {
+		code = http.StatusServiceUnavailable
+		state = "not_ready"
+	}
+	c.JSON(code, gin.H{
+		"status": state,
+		"checks": checks,
+		"time":   time.Now().Format(time.RFC3339),
+	})
+}
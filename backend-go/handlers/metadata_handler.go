@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/models"
+	"doc-analysis-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 每批读取的文件记录数量
+const metadataScanBatchSize = 500
+
+type MetadataHandler struct{}
+
+func NewMetadataHandler() *MetadataHandler {
+	return &MetadataHandler{}
+}
+
+// MetadataValue 元数据字段的一个取值及使用该值的文件数量
+type MetadataValue struct {
+	Value interface{} `json:"value"`
+	Count int         `json:"count"`
+}
+
+// GetValues 返回某个元数据字段在所有文件中出现过的取值及数量，用于前端构建筛选项。
+// key 只能是 SEARCH_FACET_KEYS 中配置的字段
+func (h *MetadataHandler) GetValues(c *gin.Context) {
+	key := strings.TrimSpace(c.Query("key"))
+	if key == "" {
+		utils.BadRequest(c, "缺少 key 参数")
+		return
+	}
+	if !isFacetKey(key) {
+		utils.BadRequest(c, fmt.Sprintf("key 只能是 %s 之一", strings.Join(config.AppConfig.Search.FacetKeys, ", ")))
+		return
+	}
+
+	// 元数据以 JSON 文本保存，无法在不同数据库中统一地按字段分组，分批读取后在内存中统计
+	counts := make(map[string]*MetadataValue)
+	var batch []models.FileRecord
+	err := database.GetDB().
+		Scopes(tenantFiles(c)).
+		Select("id", "metadata").
+		Where("metadata IS NOT NULL AND metadata <> ''").
+		FindInBatches(&batch, metadataScanBatchSize, func(tx *gorm.DB, _ int) error {
+			for _, file := range batch {
+				value, ok := file.Metadata[key]
+				if !ok {
+					continue
+				}
+				values := []interface{}{value}
+				// 标签以逗号分隔保存，按单个标签统计
+				if key == tagsMetadataKey {
+					values = values[:0]
+					for _, tag := range applyTagChanges(fileTags(file.Metadata), nil, nil) {
+						values = append(values, tag)
+					}
+				}
+				for _, v := range values {
+					// 数字反序列化后是 float64，按字符串形式去重，2024 与 2024.0 视为同一个值
+					str := fmt.Sprint(v)
+					if entry, ok := counts[str]; ok {
+						entry.Count++
+					} else {
+						counts[str] = &MetadataValue{Value: v, Count: 1}
+					}
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		utils.InternalError(c, fmt.Sprintf("查询元数据失败: %v", err))
+		return
+	}
+
+	values := make([]MetadataValue, 0, len(counts))
+	for _, entry := range counts {
+		values = append(values, *entry)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return fmt.Sprint(values[i].Value) < fmt.Sprint(values[j].Value)
+	})
+
+	utils.Success(c, gin.H{
+		"key":    key,
+		"values": values,
+		"total":  len(values),
+	})
+}
+
+func isFacetKey(key string) bool {
+	for _, k := range config.AppConfig.Search.FacetKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
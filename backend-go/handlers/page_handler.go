@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/models"
+	"doc-analysis-backend/queue"
+	"doc-analysis-backend/services"
+	"doc-analysis-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PageHandler struct{}
+
+func NewPageHandler() *PageHandler {
+	return &PageHandler{}
+}
+
+// GetPageImage 返回 PDF 某一页渲染后的图片，配合检索结果中的 page_number 展示原文所在的页面
+func (h *PageHandler) GetPageImage(c *gin.Context) {
+	fileID := c.Param("id")
+	page, err := strconv.Atoi(c.Param("n"))
+	if err != nil {
+		utils.BadRequest(c, "页码必须是整数")
+		return
+	}
+
+	db := database.GetDB()
+	var file models.FileRecord
+
+	if err := db.Scopes(tenantFiles(c)).Where("id = ?", fileID).First(&file).Error; err != nil {
+		utils.NotFound(c, "文件不存在")
+		return
+	}
+	if queue.IsArchiveFile(file.Filename) {
+		utils.BadRequest(c, "压缩包没有页面图片，请查看其中的文件")
+		return
+	}
+
+	totalPages := file.TotalPages
+	if totalPages == 0 && !file.FilePurged {
+		// 尚未处理的文件记录中没有页数，直接读取 PDF
+		if totalPages, err = services.PDFPageCount(file.Filepath); err != nil {
+			utils.InternalError(c, fmt.Sprintf("读取页数失败: %v", err))
+			return
+		}
+	}
+	if page < 1 || page > totalPages {
+		utils.NotFound(c, fmt.Sprintf("页码超出范围，文档共 %d 页", totalPages))
+		return
+	}
+
+	// 原始文件已清理时只能返回之前缓存的图片
+	if file.FilePurged {
+		path, ok := services.CachedPageImage(fileID, page)
+		if !ok {
+			utils.Error(c, http.StatusGone, "原始文件已按保留策略清理")
+			return
+		}
+		servePageImage(c, path)
+		return
+	}
+
+	path, err := services.RenderPageImage(c.Request.Context(), fileID, file.Filepath, page, totalPages)
+	if err != nil {
+		if errors.Is(err, services.ErrPageOutOfRange) {
+			utils.NotFound(c, "页码超出范围")
+			return
+		}
+		utils.InternalError(c, err.Error())
+		return
+	}
+
+	servePageImage(c, path)
+}
+
+func servePageImage(c *gin.Context, path string) {
+	c.Header("Content-Type", services.PageImageContentType())
+	c.Header("Cache-Control", "max-age=86400")
+	c.File(path)
+}
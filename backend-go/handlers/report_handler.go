@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/models"
+	"doc-analysis-backend/services"
+	"doc-analysis-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 报告中每个示例块最多展示的字符数
+	reportSampleChars = 300
+	reportMaxSample   = 20
+)
+
+type ReportHandler struct{}
+
+func NewReportHandler() *ReportHandler {
+	return &ReportHandler{}
+}
+
+// reportStage 处理阶段的结束记录，开始记录不单独列出
+type reportStage struct {
+	Stage    string    `json:"stage"`
+	Status   string    `json:"status"` // completed / failed
+	Duration *float64  `json:"duration,omitempty"`
+	Message  string    `json:"message,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+type reportChunk struct {
+	ChunkIndex int    `json:"chunk_index"`
+	PageNumber int    `json:"page_number"`
+	Length     int    `json:"length"`
+	Content    string `json:"content"`
+}
+
+// FileReport 单个文件的处理报告
+type FileReport struct {
+	File               models.FileRecord `json:"file"`
+	TotalPages         int               `json:"total_pages"`
+	ChunksCount        int               `json:"chunks_count"`
+	EmbeddedChunks     int               `json:"embedded_chunks"`
+	ProcessingDuration *float64          `json:"processing_duration,omitempty"`
+	Stages             []reportStage     `json:"stages"`
+	SampleChunks       []reportChunk     `json:"sample_chunks"`
+	GeneratedAt        time.Time         `json:"generated_at"`
+}
+
+// GetFileReport 生成文件的处理报告: 元数据、页数、块数、耗时、各阶段耗时和部分块内容。
+// format=json（默认）返回 JSON，format=html 返回可直接打开或分享的页面
+func (h *ReportHandler) GetFileReport(c *gin.Context) {
+	fileID := c.Param("id")
+	if fileID == "" {
+		utils.BadRequest(c, "文件ID不能为空")
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "html" {
+		utils.BadRequest(c, "format 只能是 json 或 html")
+		return
+	}
+	sample, err := strconv.Atoi(c.DefaultQuery("sample", "5"))
+	if err != nil || sample < 0 || sample > reportMaxSample {
+		utils.BadRequest(c, "sample 必须在 0 到 20 之间")
+		return
+	}
+
+	db := database.GetDB()
+	var file models.FileRecord
+	if err := db.Scopes(tenantFiles(c)).Where("id = ?", fileID).First(&file).Error; err != nil {
+		utils.NotFound(c, "文件不存在")
+		return
+	}
+
+	var logs []models.ProcessingLog
+	if err := db.Where("file_id = ? AND status <> ?", fileID, "started").Order("created_at ASC").Find(&logs).Error; err != nil {
+		utils.InternalError(c, "获取处理日志失败")
+		return
+	}
+
+	var chunks []models.DocumentChunk
+	if sample > 0 {
+		if err := db.Where("file_id = ?", fileID).Order("chunk_index").Limit(sample).Find(&chunks).Error; err != nil {
+			utils.InternalError(c, "获取文档块失败")
+			return
+		}
+	}
+
+	report := FileReport{
+		File:               file,
+		TotalPages:         file.TotalPages,
+		ChunksCount:        file.ChunksCount,
+		EmbeddedChunks:     file.EmbeddedChunks,
+		ProcessingDuration: file.ProcessingDuration,
+		Stages:             make([]reportStage, len(logs)),
+		SampleChunks:       make([]reportChunk, len(chunks)),
+		GeneratedAt:        time.Now(),
+	}
+	for i, l := range logs {
+		report.Stages[i] = reportStage{
+			Stage:    l.Stage,
+			Status:   l.Status,
+			Duration: l.Duration,
+			Message:  l.Message,
+			Time:     l.CreatedAt,
+		}
+	}
+	for i, chunk := range chunks {
+		report.SampleChunks[i] = reportChunk{
+			ChunkIndex: chunk.ChunkIndex,
+			PageNumber: chunk.PageNumber,
+			Length:     len([]rune(chunk.Content)),
+			Content:    services.ChunkStart(chunk.Content, reportSampleChars),
+		}
+	}
+
+	if format == "json" {
+		utils.Success(c, report)
+		return
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := reportTemplate.Execute(c.Writer, report); err != nil {
+		c.Error(err)
+	}
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"seconds": func(d *float64) string {
+		if d == nil {
+			return "-"
+		}
+		return strconv.FormatFloat(*d, 'f', 2, 64) + "s"
+	},
+	"datetime": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04:05")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>处理报告 - {{.File.Filename}}</title>
+  <style>
+    body { font-family: sans-serif; max-width: 960px; margin: 24px auto; color: #333; }
+    table { border-collapse: collapse; width: 100%; margin-bottom: 24px; }
+    th, td { border: 1px solid #ddd; padding: 6px 10px; text-align: left; vertical-align: top; }
+    th { background: #f5f5f5; }
+    .meta th { width: 160px; }
+    pre { white-space: pre-wrap; margin: 0; }
+  </style>
+</head>
+<body>
+  <h1>{{.File.Filename}}</h1>
+  <table class="meta">
+    <tr><th>文件ID</th><td>{{.File.ID}}</td></tr>
+    <tr><th>状态</th><td>{{.File.Status}}（{{.File.Message}}）</td></tr>
+    <tr><th>文件大小</th><td>{{.File.FileSize}} 字节</td></tr>
+    <tr><th>页数</th><td>{{.TotalPages}}</td></tr>
+    <tr><th>块数</th><td>{{.ChunksCount}}（已向量化 {{.EmbeddedChunks}}）</td></tr>
+    <tr><th>提取字符数</th><td>{{.File.ExtractedChars}}</td></tr>
+    <tr><th>处理耗时</th><td>{{seconds .ProcessingDuration}}</td></tr>
+    <tr><th>上传时间</th><td>{{datetime .File.CreatedAt}}</td></tr>
+    {{if .File.LastError}}<tr><th>最近错误</th><td>{{.File.LastError}}</td></tr>{{end}}
+  </table>
+
+  <h2>处理阶段</h2>
+  <table>
+    <tr><th>阶段</th><th>结果</th><th>耗时</th><th>时间</th><th>说明</th></tr>
+    {{range .Stages}}<tr><td>{{.Stage}}</td><td>{{.Status}}</td><td>{{seconds .Duration}}</td><td>{{datetime .Time}}</td><td>{{.Message}}</td></tr>
+    {{else}}<tr><td colspan="5">暂无处理记录</td></tr>{{end}}
+  </table>
+
+  <h2>示例块</h2>
+  <table>
+    <tr><th>序号</th><th>页码</th><th>内容</th></tr>
+    {{range .SampleChunks}}<tr><td>{{.ChunkIndex}}</td><td>{{.PageNumber}}</td><td><pre>{{.Content}}</pre></td></tr>
+    {{else}}<tr><td colspan="3">暂无块</td></tr>{{end}}
+  </table>
+
+  <p>生成时间: {{datetime .GeneratedAt}}</p>
+</body>
+</html>
+`))
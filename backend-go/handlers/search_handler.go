@@ -0,0 +1,628 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/models"
+	"doc-analysis-backend/services"
+	"doc-analysis-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// 关键词检索最多取出的候选块数量
+	keywordCandidateLimit = 500
+	// 混合检索 RRF 融合的平滑常数
+	rrfK = 60
+	// context_window 的上限，超过时按上限返回
+	maxContextWindow = 5
+	// 向量库不可用时建议客户端等待的秒数
+	chromaRetryAfter = "30"
+)
+
+type SearchHandler struct{}
+
+func NewSearchHandler() *SearchHandler {
+	return &SearchHandler{}
+}
+
+type SearchRequest struct {
+	Query string `json:"query"`
+	Mode  string `json:"mode"` // vector / keyword / hybrid，默认 vector
+	TopK  int    `json:"top_k"`
+	// 与 Python 版本兼容的 top_k 别名，只在 top_k 未设置时使用
+	NResults  int      `json:"n_results"`
+	FileIDs   []string `json:"file_ids"`
+	Highlight bool     `json:"highlight"`
+	// 检索的集合，为空时使用默认集合；只返回向量位于该集合中的文件
+	Collection string `json:"collection"`
+	// 按元数据选择集合，目前只支持 CHROMA_ROUTE_KEY 字段，如 {"language": "zh"}；与 collection 不能同时指定
+	Filter map[string]string `json:"filter"`
+	// 为每个结果附带同一文件中前后各 N 个块作为上下文，0 表示不附带
+	ContextWindow int `json:"context_window"`
+	// 只检索 PDF 文档信息中作者与之完全相同的文件
+	Author string `json:"author"`
+
+	// 请求所属的租户，只检索该租户的文件，为空表示不限制；由密钥决定，不从请求体读取
+	tenantID string
+}
+
+type SearchResult struct {
+	ID         string   `json:"id"`
+	FileID     string   `json:"file_id"`
+	Filename   string   `json:"filename"`
+	ChunkIndex int      `json:"chunk_index"`
+	PageNumber int      `json:"page_number"`
+	Score      float64  `json:"score"`              // [0, 1] 的相关度，1 表示最相关，换算方式见 README
+	Distance   *float64 `json:"distance,omitempty"` // Chroma 返回的原始距离，含义取决于 CHROMA_DISTANCE，关键词检索的结果没有距离
+	Content    string   `json:"content,omitempty"`
+	// highlight 为 true 时返回摘要，命中的关键词用 SEARCH_HIGHLIGHT_PRE / SEARCH_HIGHLIGHT_POST 包裹；
+	// 向量检索的结果不包含关键词时返回块的开头部分
+	Snippet string `json:"snippet,omitempty"`
+	// 向量由与当前 EMBEDDING_MODEL 不同的模型生成，相似度不可靠，需要重新向量化
+	StaleModel bool `json:"stale_model,omitempty"`
+	// context_window 大于 0 时命中块前后的块，按块序号排列，不包含命中块本身
+	Context []ContextChunk `json:"context,omitempty"`
+}
+
+// ContextChunk 命中块前后的上下文块，position 为 before / after，表示位于命中块之前还是之后
+type ContextChunk struct {
+	ChunkIndex int    `json:"chunk_index"`
+	PageNumber int    `json:"page_number"`
+	Position   string `json:"position"`
+	Content    string `json:"content"`
+}
+
+// Search 检索文档块，支持向量、关键词和混合检索
+func (h *SearchHandler) Search(c *gin.Context) {
+	var req SearchRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	req.tenantID = tenantID(c)
+	runSearch(c, &req)
+}
+
+// SearchFile 只在单个文件的块中检索，参数与 Search 相同；file_ids、collection 和 filter 由文件决定，请求中的值被忽略。
+// 文件尚未处理完成时返回 409
+func (h *SearchHandler) SearchFile(c *gin.Context) {
+	fileID := c.Param("id")
+	var req SearchRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	var file models.FileRecord
+	if err := database.GetDB().Scopes(tenantFiles(c)).Where("id = ?", fileID).First(&file).Error; err != nil {
+		utils.NotFound(c, "文件不存在")
+		return
+	}
+	// 部分完成的文件已有可检索的向量
+	if file.Status != "completed" && file.Status != "partial" {
+		utils.Error(c, http.StatusConflict, fmt.Sprintf("文件尚未处理完成（当前状态: %s），无法检索", file.Status))
+		return
+	}
+
+	req.tenantID = tenantID(c)
+	req.FileIDs = []string{fileID}
+	req.Collection = file.Collection
+	req.Filter = nil
+	runSearch(c, &req)
+}
+
+// runSearch 校验检索参数并执行检索，结果写入响应
+func runSearch(c *gin.Context, req *SearchRequest) {
+	var errs utils.ValidationErrors
+	req.Query = strings.TrimSpace(req.Query)
+	errs.Check(req.Query != "", "query", "query 不能为空")
+	// 在向量化查询之前检查长度，过长的查询浪费 token 且可能超出模型的输入上限
+	truncated := false
+	if maxTokens := config.AppConfig.Search.MaxQueryTokens; maxTokens > 0 && services.EstimateTokens(req.Query) > maxTokens {
+		if config.AppConfig.Search.TruncateQuery {
+			req.Query = strings.TrimSpace(services.TruncateToTokens(req.Query, maxTokens))
+			truncated = true
+		} else {
+			errs.Add("query", fmt.Sprintf("query 过长，估算 token 数不能超过 %d", maxTokens))
+		}
+	}
+	if req.Mode == "" {
+		req.Mode = "vector"
+	}
+	req.TopK = clampTopK(req.TopK, req.NResults)
+
+	errs.Check(req.Mode == "vector" || req.Mode == "keyword" || req.Mode == "hybrid", "mode", "mode 只能是 vector、keyword 或 hybrid")
+	validateWhereInputs(req, &errs)
+	if len(req.Filter) > 0 {
+		if err := routeSearchCollection(req); err != nil {
+			errs.Add("filter", err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+
+	// no_cache=true 时跳过缓存直接检索，结果仍会写入缓存
+	noCache, _ := strconv.ParseBool(c.DefaultQuery("no_cache", "false"))
+	cache := services.SearchResultCache()
+	cacheKey := searchCacheKey(req)
+
+	var results []SearchResult
+	cached := false
+	if cache != nil && !noCache {
+		if value, ok := cache.Get(cacheKey); ok {
+			// 复制一份，高亮处理会修改结果
+			results = append([]SearchResult(nil), value.([]SearchResult)...)
+			cached = true
+		}
+	}
+
+	// 向量库不可用时混合检索降级为关键词检索
+	degraded := false
+	if !cached {
+		var err error
+		scope := searchScope{fileIDs: req.FileIDs, tenantID: req.tenantID, author: strings.TrimSpace(req.Author)}
+		switch req.Mode {
+		case "vector":
+			results, err = vectorSearch(c.Request.Context(), req.Collection, req.Query, scope, req.TopK)
+		case "keyword":
+			results, err = keywordSearch(req.Collection, req.Query, scope, req.TopK)
+		case "hybrid":
+			results, err = hybridSearch(c.Request.Context(), req.Collection, req.Query, scope, req.TopK)
+		}
+		if errors.Is(err, services.ErrChromaUnavailable) {
+			if req.Mode == "vector" {
+				c.Header("Retry-After", chromaRetryAfter)
+				utils.Error(c, http.StatusServiceUnavailable, "向量库暂不可用，请稍后重试，或使用 mode=keyword 进行关键词检索")
+				return
+			}
+			log.Printf("向量库不可用，混合检索降级为关键词检索: %v", err)
+			results, err = keywordSearch(req.Collection, req.Query, scope, req.TopK)
+			degraded = true
+		}
+		if err != nil {
+			utils.InternalError(c, fmt.Sprintf("检索失败: %v", err))
+			return
+		}
+		// 降级的结果不写入缓存，向量库恢复后立即返回完整结果
+		if cache != nil && !degraded {
+			cache.Set(cacheKey, append([]SearchResult(nil), results...), req.FileIDs)
+		}
+	}
+
+	// 上下文不计入缓存，每次按需查询
+	if req.ContextWindow > 0 {
+		if req.ContextWindow > maxContextWindow {
+			req.ContextWindow = maxContextWindow
+		}
+		if err := attachContextChunks(results, req.ContextWindow); err != nil {
+			utils.InternalError(c, fmt.Sprintf("获取上下文失败: %v", err))
+			return
+		}
+	}
+
+	if req.Highlight {
+		terms := services.QueryTerms(req.Query)
+		opts := services.HighlightOptions{
+			SnippetLength: config.AppConfig.Search.SnippetLength,
+			PreTag:        config.AppConfig.Search.HighlightPre,
+			PostTag:       config.AppConfig.Search.HighlightPost,
+		}
+		for i := range results {
+			results[i].Snippet = services.HighlightSnippet(results[i].Content, terms, opts)
+			results[i].Content = ""
+		}
+	}
+
+	utils.Success(c, gin.H{
+		"query":           req.Query,
+		"mode":            req.Mode,
+		"results":         results,
+		"total":           len(results),
+		"top_k":           req.TopK,
+		"cached":          cached,
+		"query_truncated": truncated,
+		"degraded":        degraded,
+	})
+}
+
+// routeSearchCollection 按 filter 中路由字段的取值选择检索的集合，与写入时的选择规则相同
+func routeSearchCollection(req *SearchRequest) error {
+	routeKey := config.AppConfig.ChromaDB.RouteKey
+	if routeKey == "" {
+		return errors.New("未配置 CHROMA_ROUTE_KEY，不支持 filter")
+	}
+	if req.Collection != "" {
+		return errors.New("collection 与 filter 不能同时指定")
+	}
+	for key := range req.Filter {
+		if key != routeKey {
+			return fmt.Errorf("filter 目前只支持 %s 字段", routeKey)
+		}
+	}
+	req.Collection = services.RouteCollectionByValue(req.Filter[routeKey])
+	return nil
+}
+
+// validateWhereInputs 限制请求中会被转换为 Chroma where 条件的部分，避免超大的条件拖慢向量库。
+// where 由服务端构造，最多一层 $and，请求无法传入 $and/$or 等操作符或嵌套条件；
+// filter 只接受字符串取值，嵌套的 JSON 在绑定请求体时即被拒绝
+func validateWhereInputs(req *SearchRequest, errs *utils.ValidationErrors) {
+	cfg := config.AppConfig.Search
+	if cfg.MaxFileIDs > 0 && len(req.FileIDs) > cfg.MaxFileIDs {
+		errs.Add("file_ids", fmt.Sprintf("file_ids 最多 %d 个", cfg.MaxFileIDs))
+	}
+	if cfg.MaxFilterBytes <= 0 {
+		return
+	}
+	if len(req.Author) > cfg.MaxFilterBytes {
+		errs.Add("author", fmt.Sprintf("author 不能超过 %d 字节", cfg.MaxFilterBytes))
+	}
+	for key, value := range req.Filter {
+		if len(key)+len(value) > cfg.MaxFilterBytes {
+			errs.Add("filter", fmt.Sprintf("filter 的字段 %s 过长，字段名和取值合计不能超过 %d 字节", key, cfg.MaxFilterBytes))
+		}
+	}
+}
+
+// clampTopK 未设置或不大于 0 时使用 SEARCH_DEFAULT_TOP_K，超过 SEARCH_MAX_TOP_K 时取最大值，不返回错误
+func clampTopK(topK, nResults int) int {
+	cfg := config.AppConfig.Search
+	if topK <= 0 {
+		topK = nResults
+	}
+	if topK <= 0 {
+		return cfg.DefaultTopK
+	}
+	if topK > cfg.MaxTopK {
+		return cfg.MaxTopK
+	}
+	return topK
+}
+
+// searchCacheKey 由影响检索结果的参数生成缓存键，高亮在取出结果后处理，不计入缓存键
+func searchCacheKey(req *SearchRequest) string {
+	fileIDs := append([]string(nil), req.FileIDs...)
+	sort.Strings(fileIDs)
+	key, _ := json.Marshal([]interface{}{
+		services.FileCollection(req.Collection), req.Mode, req.Query, req.TopK, fileIDs, req.tenantID, strings.TrimSpace(req.Author),
+	})
+	return string(key)
+}
+
+// searchScope 检索范围，各条件同时满足的块才会返回，为空的条件不限制
+type searchScope struct {
+	fileIDs  []string
+	tenantID string
+	author   string
+}
+
+// chromaWhere 转换为向量检索的 where 条件，没有条件时返回 nil
+func (s searchScope) chromaWhere() map[string]interface{} {
+	var filters []map[string]interface{}
+	if len(s.fileIDs) > 0 {
+		filters = append(filters, map[string]interface{}{"file_id": map[string]interface{}{"$in": s.fileIDs}})
+	}
+	if s.tenantID != "" {
+		filters = append(filters, map[string]interface{}{services.TenantIDKey: s.tenantID})
+	}
+	if s.author != "" {
+		filters = append(filters, map[string]interface{}{services.DocumentAuthorKey: s.author})
+	}
+	// Chroma 的 where 只能有一个顶层字段，多个条件需要用 $and 组合
+	switch len(filters) {
+	case 0:
+		return nil
+	case 1:
+		return filters[0]
+	default:
+		return map[string]interface{}{"$and": filters}
+	}
+}
+
+// restrictFiles 关键词检索时按租户和作者限制文件记录
+func (s searchScope) restrictFiles(files *gorm.DB) *gorm.DB {
+	if s.tenantID != "" {
+		files = files.Where("tenant_id = ?", s.tenantID)
+	}
+	if s.author != "" {
+		files = files.Where("document_author = ?", s.author)
+	}
+	return files
+}
+
+// vectorSearch 在集合及其所有分片中检索。各分片并发地分别取 topK 个结果，再按距离升序合并取前 topK 个；
+// 所有分片使用相同的模型和距离度量，距离可以直接比较，合并结果与在单个集合中检索的结果相同
+func vectorSearch(ctx context.Context, collection, query string, scope searchScope, topK int) ([]SearchResult, error) {
+	embeddings, err := services.NewEmbeddingClient().Embed(ctx, []string{query}, 1)
+	if err != nil {
+		return nil, fmt.Errorf("查询向量化失败: %w", err)
+	}
+
+	// 默认集合在文件记录中保存为空
+	if services.FileCollection(collection) == services.CollectionName() {
+		collection = ""
+	}
+	maxShard, err := database.MaxShard(collection)
+	if err != nil {
+		return nil, fmt.Errorf("查询集合分片失败: %w", err)
+	}
+	if maxShard == 0 {
+		return queryCollection(ctx, services.FileCollection(collection), embeddings, scope, topK)
+	}
+
+	lists := make([][]SearchResult, maxShard+1)
+	errs := make([]error, maxShard+1)
+	var wg sync.WaitGroup
+	for shard := 0; shard <= maxShard; shard++ {
+		wg.Add(1)
+		go func(shard int) {
+			defer wg.Done()
+			lists[shard], errs[shard] = queryCollection(ctx, services.ShardCollection(collection, shard), embeddings, scope, topK)
+		}(shard)
+	}
+	wg.Wait()
+
+	var results []SearchResult
+	for shard, list := range lists {
+		if errs[shard] != nil {
+			return nil, fmt.Errorf("检索分片 %s 失败: %w", services.ShardCollection(collection, shard), errs[shard])
+		}
+		results = append(results, list...)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return resultDistance(results[i]) < resultDistance(results[j])
+	})
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return results, nil
+}
+
+// resultDistance 返回向量检索结果的距离，没有距离的结果排在最后
+func resultDistance(result SearchResult) float64 {
+	if result.Distance == nil {
+		return math.Inf(1)
+	}
+	return *result.Distance
+}
+
+// queryCollection 在单个集合中检索与查询向量最接近的 topK 个块
+func queryCollection(ctx context.Context, collection string, embeddings [][]float32, scope searchScope, topK int) ([]SearchResult, error) {
+	req := &services.ChromaQueryRequest{
+		QueryEmbeddings: embeddings,
+		NResults:        topK,
+		Where:           scope.chromaWhere(),
+	}
+
+	resp, err := services.NewChromaClient().QueryDocuments(ctx, collection, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.IDs) == 0 {
+		return []SearchResult{}, nil
+	}
+
+	results := make([]SearchResult, 0, len(resp.IDs[0]))
+	for i, id := range resp.IDs[0] {
+		result := SearchResult{ID: id}
+		if len(resp.Documents) > 0 && i < len(resp.Documents[0]) {
+			result.Content = resp.Documents[0][i]
+		}
+		if len(resp.Distances) > 0 && i < len(resp.Distances[0]) {
+			distance := float64(resp.Distances[0][i])
+			result.Distance = &distance
+			result.Score = services.DistanceToScore(distance)
+		}
+		if len(resp.Metadatas) > 0 && i < len(resp.Metadatas[0]) {
+			metadata := resp.Metadatas[0][i]
+			result.FileID, _ = metadata["file_id"].(string)
+			result.Filename, _ = metadata["filename"].(string)
+			if v, ok := metadata["chunk_index"].(float64); ok {
+				result.ChunkIndex = int(v)
+			}
+			if v, ok := metadata["page_number"].(float64); ok {
+				result.PageNumber = int(v)
+			}
+			model, _ := metadata[services.EmbeddingModelKey].(string)
+			result.StaleModel = services.IsStaleEmbeddingModel(model)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// keywordSearch 在数据库保存的块文本中查找包含任一关键词的块，按命中的关键词种类和次数排序
+func keywordSearch(collection, query string, scope searchScope, topK int) ([]SearchResult, error) {
+	terms := services.QueryTerms(query)
+	if len(terms) == 0 {
+		return []SearchResult{}, nil
+	}
+
+	db := database.GetDB()
+	conditions := make([]string, len(terms))
+	args := make([]interface{}, len(terms))
+	for i, term := range terms {
+		conditions[i] = `LOWER(content) LIKE ? ESCAPE '\'`
+		args[i] = "%" + escapeLike(term) + "%"
+	}
+	tx := db.Where(strings.Join(conditions, " OR "), args...)
+	if len(scope.fileIDs) > 0 {
+		tx = tx.Where("file_id IN ?", scope.fileIDs)
+	}
+	// 与向量检索保持一致，只检索向量位于该集合中的文件；默认集合的文件记录中集合为空
+	if services.FileCollection(collection) == services.CollectionName() {
+		collection = ""
+	}
+	files := db.Model(&models.FileRecord{}).Select("id").Where("COALESCE(collection, '') = ?", collection)
+	tx = tx.Where("file_id IN (?)", scope.restrictFiles(files))
+
+	var chunks []models.DocumentChunk
+	if err := tx.Limit(keywordCandidateLimit).Find(&chunks).Error; err != nil {
+		return nil, fmt.Errorf("查询文档块失败: %w", err)
+	}
+
+	// 得分的整数部分为命中的关键词种类数，小数部分随命中次数增加，上限为关键词数 + 1
+	maxScore := float64(len(terms) + 1)
+	results := make([]SearchResult, 0, len(chunks))
+	for _, chunk := range chunks {
+		results = append(results, SearchResult{
+			ID:         chunk.ID,
+			FileID:     chunk.FileID.String(),
+			ChunkIndex: chunk.ChunkIndex,
+			PageNumber: chunk.PageNumber,
+			Content:    chunk.Content,
+			Score:      services.KeywordScore(chunk.Content, terms) / maxScore,
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > topK {
+		results = results[:topK]
+	}
+
+	if err := fillFilenames(results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// hybridSearch 分别进行向量和关键词检索，用 RRF（倒数排名融合）合并结果
+func hybridSearch(ctx context.Context, collection, query string, scope searchScope, topK int) ([]SearchResult, error) {
+	vectorResults, err := vectorSearch(ctx, collection, query, scope, topK)
+	if err != nil {
+		return nil, err
+	}
+	keywordResults, err := keywordSearch(collection, query, scope, topK)
+	if err != nil {
+		return nil, err
+	}
+
+	// 两种检索中都排在第一位时得分最高，以此归一化
+	maxScore := 2 / float64(rrfK+1)
+	merged := map[string]*SearchResult{}
+	var order []string
+	for _, list := range [][]SearchResult{vectorResults, keywordResults} {
+		for rank, result := range list {
+			score := 1 / float64(rrfK+rank+1) / maxScore
+			if existing, ok := merged[result.ID]; ok {
+				existing.Score += score
+				if existing.Distance == nil {
+					existing.Distance = result.Distance
+				}
+				continue
+			}
+			result.Score = score
+			r := result
+			merged[result.ID] = &r
+			order = append(order, result.ID)
+		}
+	}
+
+	results := make([]SearchResult, 0, len(order))
+	for _, id := range order {
+		results = append(results, *merged[id])
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return results, nil
+}
+
+// attachContextChunks 从数据库保存的块文本中查询每个结果前后各 window 个块，一次查询取回所有结果的上下文
+func attachContextChunks(results []SearchResult, window int) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	conditions := make([]string, 0, len(results))
+	args := make([]interface{}, 0, len(results)*3)
+	for _, result := range results {
+		conditions = append(conditions, "(file_id = ? AND chunk_index BETWEEN ? AND ?)")
+		args = append(args, result.FileID, result.ChunkIndex-window, result.ChunkIndex+window)
+	}
+
+	var chunks []models.DocumentChunk
+	err := database.GetDB().Select("file_id", "chunk_index", "page_number", "content").
+		Where(strings.Join(conditions, " OR "), args...).
+		Order("chunk_index").Find(&chunks).Error
+	if err != nil {
+		return fmt.Errorf("查询文档块失败: %w", err)
+	}
+
+	byFile := map[string][]models.DocumentChunk{}
+	for _, chunk := range chunks {
+		id := chunk.FileID.String()
+		byFile[id] = append(byFile[id], chunk)
+	}
+
+	for i := range results {
+		result := &results[i]
+		for _, chunk := range byFile[result.FileID] {
+			offset := chunk.ChunkIndex - result.ChunkIndex
+			if offset == 0 || offset < -window || offset > window {
+				continue
+			}
+			position := "after"
+			if offset < 0 {
+				position = "before"
+			}
+			result.Context = append(result.Context, ContextChunk{
+				ChunkIndex: chunk.ChunkIndex,
+				PageNumber: chunk.PageNumber,
+				Position:   position,
+				Content:    chunk.Content,
+			})
+		}
+	}
+	return nil
+}
+
+// fillFilenames 补充关键词检索结果的文件名
+func fillFilenames(results []SearchResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(results))
+	for _, result := range results {
+		ids = append(ids, result.FileID)
+	}
+
+	var files []models.FileRecord
+	if err := database.GetDB().Select("id", "filename").Where("id IN ?", ids).Find(&files).Error; err != nil {
+		return fmt.Errorf("查询文件失败: %w", err)
+	}
+	names := make(map[string]string, len(files))
+	for _, file := range files {
+		names[file.ID.String()] = file.Filename
+	}
+	for i := range results {
+		results[i].Filename = names[results[i].FileID]
+	}
+	return nil
+}
+
+// escapeLike 转义 LIKE 模式中的通配符
+func escapeLike(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(s)
+}
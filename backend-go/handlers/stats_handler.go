@@ -1,8 +1,18 @@
 package handlers
 
 import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"doc-analysis-backend/config"
 	"doc-analysis-backend/database"
 	"doc-analysis-backend/models"
+	"doc-analysis-backend/queue"
+	"doc-analysis-backend/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,37 +29,33 @@ type DatabaseStats struct {
 }
 
 func (h *StatsHandler) GetDatabaseStats(c *gin.Context) {
-	db := database.GetDB()
+	// 获取基本统计数据 (匹配 Python 版本的 get_processing_statistics)，
+	// 各状态数量和块数量由一次分组查询得到
+	aggregates, err := aggregateFilesByStatus(c)
+	if err != nil {
+		utils.InternalError(c, "获取统计数据失败")
+		return
+	}
 
-	// 获取基本统计数据 (匹配 Python 版本的 get_processing_statistics)
-	var totalFiles int64
-	var completedFiles int64 
-	var errorFiles int64
-	var processingFiles int64
-	var pendingFiles int64
+	var totalFiles, totalChunks int64
+	for _, agg := range aggregates {
+		totalFiles += agg.Count
+		totalChunks += agg.Chunks
+	}
+	completedFiles := aggregates["completed"].Count
+	errorFiles := aggregates["failed"].Count
+	pendingFiles := aggregates["pending"].Count
 
-	db.Model(&models.FileRecord{}).Count(&totalFiles)
-	db.Model(&models.FileRecord{}).Where("status = ?", "completed").Count(&completedFiles)
-	db.Model(&models.FileRecord{}).Where("status = ?", "failed").Count(&errorFiles)
-	
 	// 处理中的文件 (包含多个状态，匹配 Python 版本)
-	db.Model(&models.FileRecord{}).Where("status IN ?", []string{"parsing", "chunking", "embedding", "storing", "processing"}).Count(&processingFiles)
-	db.Model(&models.FileRecord{}).Where("status = ?", "pending").Count(&pendingFiles)
-
-	// 获取总文档块数量
-	var totalChunksResult *int64
-	db.Model(&models.FileRecord{}).Select("SUM(chunks_count)").Row().Scan(&totalChunksResult)
-	totalChunks := int64(0)
-	if totalChunksResult != nil {
-		totalChunks = *totalChunksResult
+	var processingFiles int64
+	for _, status := range processingStatuses {
+		processingFiles += aggregates[status].Count
 	}
 
 	// 计算成功率
 	successRate := float64(0)
 	if totalFiles > 0 {
-		successRate = float64(completedFiles) / float64(totalFiles) * 100
-		// 保留两位小数
-		successRate = float64(int(successRate*100)) / 100
+		successRate = roundTo(float64(completedFiles)/float64(totalFiles)*100, config.AppConfig.Stats.RateDecimals)
 	}
 
 	// TODO: 获取向量数据库统计 (需要 ChromaDB 客户端实现)
@@ -71,3 +77,231 @@ func (h *StatsHandler) GetDatabaseStats(c *gin.Context) {
 		},
 	})
 }
+
+// roundTo 四舍五入保留 decimals 位小数，如 66.666 保留两位为 66.67。
+// 放大后的值先按 12 位有效数字取整，消除二进制浮点误差（1.005*100 = 100.49999999999999），
+// 使 x.xx5 按十进制的写法进位
+func roundTo(value float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	scaled, _ := strconv.ParseFloat(strconv.FormatFloat(value*scale, 'g', 12, 64), 64)
+	return math.Round(scaled) / scale
+}
+
+// GetStatusSummary 只返回各状态的文件数量，供页面角标等轻量场景使用
+func (h *StatsHandler) GetStatusSummary(c *gin.Context) {
+	aggregates, err := aggregateFilesByStatus(c)
+	if err != nil {
+		utils.InternalError(c, "获取文件状态统计失败")
+		return
+	}
+
+	var processing, total int64
+	for _, status := range processingStatuses {
+		processing += aggregates[status].Count
+	}
+	for _, agg := range aggregates {
+		total += agg.Count
+	}
+
+	utils.Success(c, map[string]int64{
+		"pending":          aggregates["pending"].Count,
+		"processing":       processing,
+		"completed":        aggregates["completed"].Count,
+		"completed_empty":  aggregates["completed_empty"].Count,
+		"partial":          aggregates["partial"].Count,
+		"error":            aggregates["error"].Count,
+		"failed_permanent": aggregates["failed_permanent"].Count,
+		"total":            total,
+	})
+}
+
+// 单个状态下的文件数量和块数量
+type statusAggregate struct {
+	Status string
+	Count  int64
+	Chunks int64
+}
+
+// aggregateFilesByStatus 用一次 GROUP BY 查询统计每种状态的文件数量和块数量
+func aggregateFilesByStatus(c *gin.Context) (map[string]statusAggregate, error) {
+	var rows []statusAggregate
+	err := database.GetDB().Model(&models.FileRecord{}).Scopes(tenantFiles(c)).
+		Select("status, COUNT(*) AS count, COALESCE(SUM(chunks_count), 0) AS chunks").
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	aggregates := make(map[string]statusAggregate, len(rows))
+	for _, row := range rows {
+		aggregates[row.Status] = row
+	}
+	return aggregates, nil
+}
+
+// ThroughputBucket 一个时间分桶内结束的任务数量，按任务结束时间归入分桶
+type ThroughputBucket struct {
+	Start     time.Time `json:"start"`
+	Completed int       `json:"completed"`
+	Failed    int       `json:"failed"`
+}
+
+// WorkerUtilization 时间窗口内工作器的繁忙程度: 任务执行时长之和 / (窗口时长 × 总并发数)
+type WorkerUtilization struct {
+	Concurrency int     `json:"concurrency"`
+	BusySeconds float64 `json:"busy_seconds"`
+	Utilization float64 `json:"utilization"`
+}
+
+// ThroughputStats 时间窗口内的处理吞吐量
+type ThroughputStats struct {
+	Window         string    `json:"window"`
+	Bucket         string    `json:"bucket"`
+	Since          time.Time `json:"since"`
+	Until          time.Time `json:"until"`
+	FilesProcessed int       `json:"files_processed"`
+	FilesPerHour   float64   `json:"files_per_hour"`
+	TasksCompleted int       `json:"tasks_completed"`
+	TasksFailed    int       `json:"tasks_failed"`
+	// 窗口内没有完成的任务时为空
+	AvgProcessingSeconds    *float64 `json:"avg_processing_seconds"`
+	MedianProcessingSeconds *float64 `json:"median_processing_seconds"`
+	// 读取任务队列失败时为空
+	QueueDepth        *int               `json:"queue_depth"`
+	WorkerUtilization WorkerUtilization  `json:"worker_utilization"`
+	Buckets           []ThroughputBucket `json:"buckets"`
+}
+
+// GetThroughput 统计时间窗口内每小时处理的文件数、处理耗时、当前队列深度和工作器利用率，
+// 窗口和分桶大小默认取 STATS_THROUGHPUT_WINDOW、STATS_THROUGHPUT_BUCKET
+func (h *StatsHandler) GetThroughput(c *gin.Context) {
+	cfg := config.AppConfig.Stats
+	window, err := durationQuery(c, "window", cfg.ThroughputWindow)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	bucket, err := durationQuery(c, "bucket", cfg.ThroughputBucket)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if bucket > window {
+		utils.BadRequest(c, "bucket 不能大于 window")
+		return
+	}
+	bucketCount := int((window + bucket - 1) / bucket)
+	if bucketCount > cfg.ThroughputMaxBuckets {
+		utils.BadRequest(c, fmt.Sprintf("分桶数量 %d 超过上限 %d，请增大 bucket 或缩小 window", bucketCount, cfg.ThroughputMaxBuckets))
+		return
+	}
+
+	until := time.Now()
+	since := until.Add(-window)
+
+	// 窗口内结束的任务，以及窗口内仍在执行的任务（计入工作器繁忙时间）
+	var tasks []models.Task
+	err = database.GetDB().Model(&models.Task{}).Scopes(tenantFileRows(c)).
+		Select("file_id", "status", "started_at", "ended_at").
+		Where("started_at IS NOT NULL AND started_at < ?", until).
+		Where("((status IN ? AND ended_at >= ?) OR status = ?)",
+			[]models.TaskStatus{models.TaskCompleted, models.TaskFailed}, since, models.TaskRunning).
+		Find(&tasks).Error
+	if err != nil {
+		utils.InternalError(c, "获取任务统计失败")
+		return
+	}
+
+	stats := ThroughputStats{
+		Window:  window.String(),
+		Bucket:  bucket.String(),
+		Since:   since,
+		Until:   until,
+		Buckets: make([]ThroughputBucket, bucketCount),
+	}
+	for i := range stats.Buckets {
+		stats.Buckets[i].Start = since.Add(time.Duration(i) * bucket)
+	}
+
+	files := make(map[string]struct{})
+	var durations []float64
+	var busy time.Duration
+	for _, task := range tasks {
+		end := until
+		if task.EndedAt != nil && task.Status != models.TaskRunning {
+			end = *task.EndedAt
+		}
+		start := *task.StartedAt
+		if start.Before(since) {
+			start = since
+		}
+		if end.After(start) {
+			busy += end.Sub(start)
+		}
+		if task.Status == models.TaskRunning || task.EndedAt == nil {
+			continue
+		}
+
+		index := min(int(task.EndedAt.Sub(since)/bucket), bucketCount-1)
+		if task.Status == models.TaskFailed {
+			stats.TasksFailed++
+			stats.Buckets[index].Failed++
+			continue
+		}
+		stats.TasksCompleted++
+		stats.Buckets[index].Completed++
+		files[task.FileID.String()] = struct{}{}
+		durations = append(durations, task.EndedAt.Sub(*task.StartedAt).Seconds())
+	}
+
+	stats.FilesProcessed = len(files)
+	stats.FilesPerHour = roundTo(float64(stats.FilesProcessed)/window.Hours(), cfg.RateDecimals)
+	if len(durations) > 0 {
+		var total float64
+		for _, d := range durations {
+			total += d
+		}
+		avg := roundTo(total/float64(len(durations)), cfg.RateDecimals)
+		median := roundTo(medianOf(durations), cfg.RateDecimals)
+		stats.AvgProcessingSeconds, stats.MedianProcessingSeconds = &avg, &median
+	}
+
+	if depth, err := queue.InspectQueueDepth(); err == nil {
+		stats.QueueDepth = &depth.Depth
+	} else {
+		log.Printf("读取任务队列深度失败: %v", err)
+	}
+
+	concurrency := queue.WorkerConcurrency()
+	stats.WorkerUtilization = WorkerUtilization{
+		Concurrency: concurrency,
+		BusySeconds: roundTo(busy.Seconds(), cfg.RateDecimals),
+		Utilization: roundTo(min(busy.Seconds()/(window.Seconds()*float64(concurrency)), 1), cfg.RateDecimals),
+	}
+
+	utils.Success(c, stats)
+}
+
+// durationQuery 解析 Go duration 格式的查询参数，如 30m、24h，未指定时返回 fallback
+func durationQuery(c *gin.Context, name string, fallback time.Duration) (time.Duration, error) {
+	value := c.Query(name)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s 必须是大于 0 的时长，如 30m、24h: %s", name, value)
+	}
+	return d, nil
+}
+
+// medianOf 返回中位数，会对 values 排序
+func medianOf(values []float64) float64 {
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/middleware"
+	"doc-analysis-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB 打开一个内存 SQLite 数据库替换 database.DB，测试结束后恢复。
+// FileRecord 的主键默认值 gen_random_uuid() 只在 PostgreSQL 中可用，因此 files 表手动创建，只包含统计用到的列
+func openTestDB(t testing.TB) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	err = db.Exec(`CREATE TABLE file_records (
+		id TEXT PRIMARY KEY,
+		status TEXT NOT NULL DEFAULT 'pending',
+		chunks_count INTEGER DEFAULT 0,
+		tenant_id TEXT
+	)`).Error
+	if err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+
+	saved := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = saved
+		sqlDB.Close()
+	})
+	return db
+}
+
+func testContext(tenant string) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if tenant != "" {
+		c.Set(middleware.TenantContextKey, tenant)
+	}
+	return c
+}
+
+func TestAggregateFilesByStatusMatchesPerStatusQueries(t *testing.T) {
+	db := openTestDB(t)
+
+	statuses := []string{"pending", "queued", "parsing", "embedding", "completed", "error", "failed_permanent", "failed"}
+	tenants := []string{"", "a", "b"}
+	for i := 0; i < 200; i++ {
+		// 部分文件的 chunks_count 为 NULL，SUM 时按 0 计算
+		var chunks interface{}
+		if i%7 != 0 {
+			chunks = i % 13
+		}
+		err := db.Exec("INSERT INTO file_records (id, status, chunks_count, tenant_id) VALUES (?, ?, ?, ?)",
+			fmt.Sprintf("f%03d", i), statuses[(i*5)%len(statuses)], chunks, tenants[i%len(tenants)]).Error
+		if err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	for _, tenant := range []string{"", "a", "b", "missing"} {
+		t.Run("tenant="+tenant, func(t *testing.T) {
+			c := testContext(tenant)
+			aggregates, err := aggregateFilesByStatus(c)
+			if err != nil {
+				t.Fatalf("aggregateFilesByStatus: %v", err)
+			}
+
+			// 原先的实现: 每种状态一次 COUNT 查询，再单独 SUM 块数量
+			query := func() *gorm.DB { return db.Model(&models.FileRecord{}).Scopes(tenantFiles(c)) }
+			var total, totalChunks, sumCount int64
+			query().Count(&total)
+			query().Select("COALESCE(SUM(chunks_count), 0)").Row().Scan(&totalChunks)
+			for _, status := range statuses {
+				var count, chunks int64
+				query().Where("status = ?", status).Count(&count)
+				query().Where("status = ?", status).Select("COALESCE(SUM(chunks_count), 0)").Row().Scan(&chunks)
+				agg := aggregates[status]
+				if agg.Count != count || agg.Chunks != chunks {
+					t.Errorf("status %s: grouped count=%d chunks=%d, per-status count=%d chunks=%d",
+						status, agg.Count, agg.Chunks, count, chunks)
+				}
+				sumCount += agg.Count
+			}
+
+			var processing int64
+			query().Where("status IN ?", processingStatuses).Count(&processing)
+			var grouped int64
+			for _, status := range processingStatuses {
+				grouped += aggregates[status].Count
+			}
+			if grouped != processing {
+				t.Errorf("processing: grouped %d, per-status %d", grouped, processing)
+			}
+
+			var aggChunks int64
+			for _, agg := range aggregates {
+				aggChunks += agg.Chunks
+			}
+			if sumCount != total || aggChunks != totalChunks {
+				t.Errorf("totals: grouped files=%d chunks=%d, queried files=%d chunks=%d", sumCount, aggChunks, total, totalChunks)
+			}
+		})
+	}
+}
+
+func TestRoundTo(t *testing.T) {
+	cases := []struct {
+		value    float64
+		decimals int
+		want     float64
+	}{
+		{66.666, 2, 66.67},
+		{2.0 / 3 * 100, 2, 66.67},
+		{66.664, 2, 66.66},
+		{100, 2, 100},
+		{0, 2, 0},
+		// 保留 0 位小数
+		{66.5, 0, 67},
+		{66.49, 0, 66},
+		{12.5, 0, 13},
+		// x.xx5: 放大后在二进制下略小于 .5，仍应进位
+		{1.005, 2, 1.01},
+		{1.015, 2, 1.02},
+		{2.675, 2, 2.68},
+		{0.285, 2, 0.29},
+		{1.0049999, 2, 1},
+		// 负数远离零进位
+		{-1.005, 2, -1.01},
+		{-66.666, 1, -66.7},
+		{3.14159, 4, 3.1416},
+	}
+	for _, tc := range cases {
+		if got := roundTo(tc.value, tc.decimals); got != tc.want {
+			t.Errorf("roundTo(%v, %d) = %v, want %v", tc.value, tc.decimals, got, tc.want)
+		}
+	}
+}
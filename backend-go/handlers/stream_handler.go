@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/models"
+	"doc-analysis-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type StreamHandler struct{}
+
+func NewStreamHandler() *StreamHandler {
+	return &StreamHandler{}
+}
+
+// FileStatus 通过 SSE 推送单个文件的处理状态。连接后立即发送当前状态，
+// 之后按轮询间隔只在状态变化时推送；处理结束、文件被删除或超过最长连接时间时关闭连接。
+func (h *StreamHandler) FileStatus(c *gin.Context) {
+	fileID := c.Param("id")
+	view, err := loadFileStatusView(c, fileID)
+	if err != nil {
+		utils.NotFound(c, "文件不存在")
+		return
+	}
+
+	cfg := config.AppConfig.Stream
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.SSEvent("status", view)
+	if isFinishedStatus(view.Status) {
+		c.SSEvent("done", view)
+		c.Writer.Flush()
+		return
+	}
+	c.Writer.Flush()
+
+	poll := time.NewTicker(cfg.PollInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(cfg.HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	// 超过最长连接时间后关闭，由客户端重新连接，避免连接无限期占用
+	var deadline <-chan time.Time
+	if cfg.MaxDuration > 0 {
+		timer := time.NewTimer(cfg.MaxDuration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-deadline:
+			c.SSEvent("timeout", gin.H{"message": "连接已达到最长时间，请重新连接"})
+			c.Writer.Flush()
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": ping\n\n")
+			c.Writer.Flush()
+		case <-poll.C:
+			current, err := loadFileStatusView(c, fileID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.SSEvent("deleted", gin.H{"id": fileID})
+				c.Writer.Flush()
+				return
+			}
+			if err != nil || current == view {
+				continue
+			}
+
+			view = current
+			c.SSEvent("status", view)
+			if isFinishedStatus(view.Status) {
+				c.SSEvent("done", view)
+				c.Writer.Flush()
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// FileLogs 通过 SSE 实时推送文件的处理日志。连接后先发送已有的日志，之后按轮询间隔推送新写入的日志，
+// 每条日志一个 log 事件；处理结束时发送 done 事件后关闭，文件被删除或超过最长连接时间时同样关闭。
+func (h *StreamHandler) FileLogs(c *gin.Context) {
+	fileID := c.Param("id")
+	view, err := loadFileStatusView(c, fileID)
+	if err != nil {
+		utils.NotFound(c, "文件不存在")
+		return
+	}
+
+	cfg := config.AppConfig.Stream
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	cursor := &logCursor{seen: map[string]bool{}}
+	if err := cursor.send(c, fileID); err != nil {
+		c.SSEvent("error", gin.H{"message": "获取处理日志失败"})
+		c.Writer.Flush()
+		return
+	}
+	if isFinishedStatus(view.Status) {
+		c.SSEvent("done", view)
+		c.Writer.Flush()
+		return
+	}
+	c.Writer.Flush()
+
+	poll := time.NewTicker(cfg.PollInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(cfg.HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	var deadline <-chan time.Time
+	if cfg.MaxDuration > 0 {
+		timer := time.NewTimer(cfg.MaxDuration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-deadline:
+			c.SSEvent("timeout", gin.H{"message": "连接已达到最长时间，请重新连接"})
+			c.Writer.Flush()
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": ping\n\n")
+			c.Writer.Flush()
+		case <-poll.C:
+			// 先读状态再读日志: 结束前的日志都先于最终状态写入，结束时不会漏掉最后几条
+			current, err := loadFileStatusView(c, fileID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.SSEvent("deleted", gin.H{"id": fileID})
+				c.Writer.Flush()
+				return
+			}
+			if err != nil {
+				continue
+			}
+			if err := cursor.send(c, fileID); err != nil {
+				continue
+			}
+			if isFinishedStatus(current.Status) {
+				c.SSEvent("done", current)
+				c.Writer.Flush()
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// logCursor 记录已推送到的位置。created_at 相同的日志可能分两次读到，用 ID 去重
+type logCursor struct {
+	last time.Time
+	seen map[string]bool // created_at 等于 last 的已推送日志
+}
+
+// send 推送 last 之后写入的日志
+func (lc *logCursor) send(c *gin.Context, fileID string) error {
+	var logs []models.ProcessingLog
+	err := database.GetDB().
+		Where("file_id = ? AND created_at >= ?", fileID, lc.last).
+		Order("created_at ASC").
+		Find(&logs).Error
+	if err != nil {
+		return err
+	}
+
+	for _, l := range logs {
+		id := l.ID.String()
+		if lc.seen[id] {
+			continue
+		}
+		if l.CreatedAt.After(lc.last) {
+			lc.last = l.CreatedAt
+			lc.seen = map[string]bool{}
+		}
+		lc.seen[id] = true
+		c.SSEvent("log", l)
+	}
+	return nil
+}
+
+// isFinishedStatus 判断文件是否已结束处理，结束后状态不会再自动变化
+func isFinishedStatus(status string) bool {
+	switch status {
+	case "completed", "completed_empty", "partial", "error", "failed_permanent":
+		return true
+	}
+	return false
+}
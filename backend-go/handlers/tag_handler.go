@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/models"
+	"doc-analysis-backend/queue"
+	"doc-analysis-backend/services"
+	"doc-analysis-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 标签保存在元数据的 tags 字段中，多个标签用逗号分隔（Chroma 的元数据值不支持数组）
+	tagsMetadataKey = "tags"
+	maxTagLength    = 50
+	// 单次请求最多修改的文件数量
+	maxBulkTagFiles = 500
+	// 更新块元数据时每页读取的记录数量
+	tagUpdatePageSize = 500
+)
+
+type TagHandler struct{}
+
+func NewTagHandler() *TagHandler {
+	return &TagHandler{}
+}
+
+type BulkTagRequest struct {
+	FileIDs []string `json:"file_ids"`
+	Add     []string `json:"add"`
+	Remove  []string `json:"remove"`
+}
+
+// TagResult 单个文件的修改结果，status 为 updated / unchanged / failed
+type TagResult struct {
+	FileID string   `json:"file_id"`
+	Status string   `json:"status"`
+	Tags   []string `json:"tags"`
+	Chunks int      `json:"chunks"` // 更新了元数据的块数量
+	Error  string   `json:"error,omitempty"`
+}
+
+// BulkUpdate 为多个文件添加、移除标签，同时更新文件记录和向量库中各块的元数据。
+// 同一个标签同时出现在 add 和 remove 中时以 remove 为准
+func (h *TagHandler) BulkUpdate(c *gin.Context) {
+	var req BulkTagRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	var errs utils.ValidationErrors
+	errs.Check(len(req.FileIDs) > 0, "file_ids", "file_ids 不能为空")
+	errs.Check(len(req.FileIDs) <= maxBulkTagFiles, "file_ids", fmt.Sprintf("一次最多修改 %d 个文件", maxBulkTagFiles))
+	add, addErr := normalizeTags(req.Add)
+	if addErr != nil {
+		errs.Add("add", addErr.Error())
+	}
+	remove, removeErr := normalizeTags(req.Remove)
+	if removeErr != nil {
+		errs.Add("remove", removeErr.Error())
+	}
+	if addErr == nil && removeErr == nil && len(add) == 0 && len(remove) == 0 {
+		errs.Add("add", "add 和 remove 不能都为空")
+	}
+	if len(errs) > 0 {
+		utils.ValidationFailed(c, errs)
+		return
+	}
+
+	results := make([]TagResult, 0, len(req.FileIDs))
+	updated, failed := 0, 0
+	for _, fileID := range req.FileIDs {
+		result := h.updateFileTags(c, fileID, add, remove)
+		switch result.Status {
+		case "updated":
+			updated++
+		case "failed":
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	utils.Success(c, map[string]interface{}{
+		"results": results,
+		"updated": updated,
+		"failed":  failed,
+	})
+}
+
+func (h *TagHandler) updateFileTags(c *gin.Context, fileID string, add, remove []string) TagResult {
+	result := TagResult{FileID: fileID, Status: "failed"}
+
+	// 不等待锁，处理中的文件稍后重试，避免处理任务用旧的元数据写入向量
+	ctx := c.Request.Context()
+	lock, err := queue.AcquireFileLock(ctx, fileID, 0)
+	if err != nil {
+		if errors.Is(err, queue.ErrFileLocked) {
+			result.Error = "文件正在处理中，请稍后再试"
+		} else {
+			result.Error = err.Error()
+		}
+		return result
+	}
+	defer lock.Release()
+
+	db := database.GetDB()
+	var file models.FileRecord
+	if err := db.Scopes(tenantFiles(c)).Where("id = ?", fileID).First(&file).Error; err != nil {
+		result.Error = "文件不存在"
+		return result
+	}
+
+	current := applyTagChanges(fileTags(file.Metadata), nil, nil)
+	tags := applyTagChanges(current, add, remove)
+	result.Tags = tags
+	if strings.Join(tags, ",") == strings.Join(current, ",") {
+		result.Status = "unchanged"
+		return result
+	}
+
+	metadata := make(map[string]interface{}, len(file.Metadata)+1)
+	for key, value := range file.Metadata {
+		metadata[key] = value
+	}
+	if len(tags) > 0 {
+		metadata[tagsMetadataKey] = strings.Join(tags, ",")
+	} else {
+		delete(metadata, tagsMetadataKey)
+	}
+	if len(metadata) > maxMetadataKeys {
+		result.Error = fmt.Sprintf("metadata 最多包含 %d 个字段", maxMetadataKeys)
+		return result
+	}
+	if data, _ := json.Marshal(metadata); len(data) > maxMetadataBytes {
+		result.Error = fmt.Sprintf("metadata 不能超过 %d 字节", maxMetadataBytes)
+		return result
+	}
+
+	// 先更新向量库，失败时文件记录保持不变，重试即可
+	chunks, err := updateChunkTags(ctx, &file, metadata[tagsMetadataKey])
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Chunks = chunks
+
+	if len(metadata) == 0 {
+		metadata = nil
+	}
+	if err := db.Model(&file).Select("metadata").Updates(&models.FileRecord{Metadata: metadata}).Error; err != nil {
+		result.Error = fmt.Sprintf("更新文件记录失败: %v", err)
+		return result
+	}
+
+	result.Status = "updated"
+	return result
+}
+
+// updateChunkTags 分页更新文件所有块的 tags 元数据，value 为 nil 时删除该字段
+func updateChunkTags(ctx context.Context, file *models.FileRecord, value interface{}) (int, error) {
+	chromaClient := services.NewChromaClient()
+	collection := services.ShardCollection(file.Collection, file.Shard)
+	fileID := file.ID.String()
+
+	updated := 0
+	for offset := 0; ; offset += tagUpdatePageSize {
+		page, err := chromaClient.GetDocuments(ctx, collection, &services.ChromaGetRequest{
+			Where:   map[string]interface{}{"file_id": fileID},
+			Include: []string{"metadatas"},
+			Limit:   tagUpdatePageSize,
+			Offset:  offset,
+		})
+		if err != nil {
+			return updated, fmt.Errorf("读取向量数据失败: %w", err)
+		}
+		if len(page.IDs) > 0 {
+			metadatas := make([]map[string]interface{}, len(page.IDs))
+			for i := range metadatas {
+				metadatas[i] = map[string]interface{}{tagsMetadataKey: value}
+			}
+			if err := chromaClient.UpdateMetadatas(ctx, collection, page.IDs, metadatas); err != nil {
+				return updated, err
+			}
+		}
+
+		updated += len(page.IDs)
+		if len(page.IDs) < tagUpdatePageSize {
+			return updated, nil
+		}
+	}
+}
+
+// fileTags 从元数据中读取标签列表
+func fileTags(metadata map[string]interface{}) []string {
+	value, ok := metadata[tagsMetadataKey]
+	if !ok {
+		return nil
+	}
+	return splitTags(fmt.Sprint(value))
+}
+
+func splitTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// normalizeTags 去除空白和重复的标签，标签不能包含逗号
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if strings.Contains(tag, ",") {
+			return nil, fmt.Errorf("标签不能包含逗号: %s", tag)
+		}
+		if len([]rune(tag)) > maxTagLength {
+			return nil, fmt.Errorf("标签长度不能超过 %d 个字符: %s", maxTagLength, tag)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	return result, nil
+}
+
+// applyTagChanges 返回添加、移除后去重排序的标签
+func applyTagChanges(current, add, remove []string) []string {
+	set := make(map[string]bool, len(current)+len(add))
+	for _, tag := range current {
+		set[tag] = true
+	}
+	for _, tag := range add {
+		set[tag] = true
+	}
+	for _, tag := range remove {
+		delete(set, tag)
+	}
+
+	tags := make([]string, 0, len(set))
+	for tag := range set {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/models"
+	"doc-analysis-backend/queue"
+	"doc-analysis-backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hibiken/asynq"
+)
+
+type TaskHandler struct{}
+
+func NewTaskHandler() *TaskHandler {
+	return &TaskHandler{}
+}
+
+// ListTasks 按创建时间倒序返回任务列表，支持按 file_id、status 过滤
+func (h *TaskHandler) ListTasks(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		utils.BadRequest(c, "limit 必须在 1 到 200 之间")
+		return
+	}
+
+	query := database.GetDB().Model(&models.Task{}).Scopes(tenantFileRows(c))
+	if fileID := c.Query("file_id"); fileID != "" {
+		query = query.Where("file_id = ?", fileID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var tasks []models.Task
+	if err := query.Order("created_at DESC").Limit(limit).Find(&tasks).Error; err != nil {
+		utils.InternalError(c, "获取任务列表失败")
+		return
+	}
+
+	// 统计已开始执行的任务的平均排队时间
+	var totalWait float64
+	started := 0
+	for _, task := range tasks {
+		if task.QueueWaitSeconds != nil {
+			totalWait += *task.QueueWaitSeconds
+			started++
+		}
+	}
+	var avgWait *float64
+	if started > 0 {
+		avg := totalWait / float64(started)
+		avgWait = &avg
+	}
+
+	utils.Success(c, map[string]interface{}{
+		"tasks":                  tasks,
+		"avg_queue_wait_seconds": avgWait,
+	})
+}
+
+// GetTask 返回单个任务的记录和所属文件名，并附带 asynq 中的实时状态（排队位置、下次重试时间等）。
+// 任务已被 asynq 清除或 Redis 不可用时 live 为空，live_error 说明原因
+func (h *TaskHandler) GetTask(c *gin.Context) {
+	taskID := c.Param("id")
+
+	var task models.Task
+	if err := database.GetDB().Scopes(tenantFileRows(c)).Where("id = ?", taskID).First(&task).Error; err != nil {
+		utils.NotFound(c, "任务不存在")
+		return
+	}
+
+	var file models.FileRecord
+	filename := ""
+	if err := database.GetDB().Select("filename").Where("id = ?", task.FileID).First(&file).Error; err == nil {
+		filename = file.Filename
+	}
+
+	data := map[string]interface{}{
+		"task":     task,
+		"filename": filename,
+		"live":     nil,
+	}
+	live, err := queue.InspectTask(task.Queue, task.ID)
+	switch {
+	case err == nil:
+		data["live"] = live
+	case errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound):
+		data["live_error"] = "任务已不在队列中"
+	default:
+		data["live_error"] = err.Error()
+	}
+
+	utils.Success(c, data)
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/middleware"
+	"doc-analysis-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// tenantID 返回当前请求所属的租户，为空表示未启用多租户或使用了管理员密钥，不限制租户
+func tenantID(c *gin.Context) string {
+	return c.GetString(middleware.TenantContextKey)
+}
+
+// tenantFiles 将 FileRecord 的查询限制在当前租户的文件内，用法: db.Scopes(tenantFiles(c))
+func tenantFiles(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	tenant := tenantID(c)
+	return func(db *gorm.DB) *gorm.DB {
+		if tenant == "" {
+			return db
+		}
+		return db.Where("tenant_id = ?", tenant)
+	}
+}
+
+// tenantFileRows 将任务、文档块等带 file_id 字段的表的查询限制在当前租户的文件内
+func tenantFileRows(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	tenant := tenantID(c)
+	return func(db *gorm.DB) *gorm.DB {
+		if tenant == "" {
+			return db
+		}
+		return db.Where("file_id IN (?)", database.GetDB().Model(&models.FileRecord{}).Select("id").Where("tenant_id = ?", tenant))
+	}
+}
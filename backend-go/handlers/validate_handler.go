@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/queue"
+	"doc-analysis-backend/services"
+	"doc-analysis-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 校验文件头时读取的字节数，PDF 规范允许 %PDF- 之前有少量其他内容
+const magicHeaderSize = 1024
+
+var (
+	pdfMagic = []byte("%PDF-")
+	zipMagic = []byte("PK\x03\x04")
+)
+
+type ValidateHandler struct{}
+
+func NewValidateHandler() *ValidateHandler {
+	return &ValidateHandler{}
+}
+
+type validationCheck struct {
+	Name    string `json:"name"` // extension / size / magic_bytes / parse
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// ValidationReport 上传前校验的结果，任一检查未通过时 valid 为 false，未执行的检查不出现在 checks 中
+type ValidationReport struct {
+	Filename string            `json:"filename"`
+	Size     int64             `json:"size"`
+	Valid    bool              `json:"valid"`
+	Checks   []validationCheck `json:"checks"`
+	PDF      *services.PDFInfo `json:"pdf,omitempty"`
+	// ZIP 中支持处理的文件数量
+	ArchiveFiles *int `json:"archive_files,omitempty"`
+}
+
+func (r *ValidationReport) check(name string, err error) bool {
+	check := validationCheck{Name: name, Passed: err == nil}
+	if err != nil {
+		check.Message = err.Error()
+	}
+	r.Checks = append(r.Checks, check)
+	return err == nil
+}
+
+// Validate 对文件做与上传相同的类型、大小检查，并校验文件头和能否解析，不保存文件也不创建记录。
+// 文件写入临时目录用于解析，校验结束后立即删除
+func (h *ValidateHandler) Validate(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		utils.BadRequest(c, "未选择文件")
+		return
+	}
+	// 与上传相同，按清理后的文件名检查扩展名
+	fileHeader.Filename = services.SanitizeFilename(fileHeader.Filename)
+
+	report := &ValidationReport{
+		Filename: fileHeader.Filename,
+		Size:     fileHeader.Size,
+	}
+	report.Valid = validateFile(report, fileHeader)
+
+	utils.Success(c, report)
+}
+
+// validateFile 依次执行各项检查，遇到未通过的检查即停止
+func validateFile(report *ValidationReport, fileHeader *multipart.FileHeader) bool {
+	cfg := config.AppConfig.Upload
+	if !report.check("extension", checkExtension(fileHeader.Filename, cfg.AllowExt)) {
+		return false
+	}
+	if !report.check("size", checkSize(fileHeader.Size, cfg.MaxSize)) {
+		return false
+	}
+
+	tempPath, err := saveTempFile(fileHeader)
+	if err != nil {
+		report.check("magic_bytes", fmt.Errorf("读取文件失败: %w", err))
+		return false
+	}
+	defer os.Remove(tempPath)
+
+	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	if !report.check("magic_bytes", checkMagicBytes(tempPath, ext)) {
+		return false
+	}
+
+	if queue.IsArchiveFile(fileHeader.Filename) {
+		count, err := queue.ValidateArchive(tempPath)
+		if err == nil && count == 0 {
+			err = fmt.Errorf("压缩包中没有支持处理的文件")
+		}
+		if err == nil {
+			report.ArchiveFiles = &count
+		}
+		return report.check("parse", err)
+	}
+
+	info, err := services.InspectPDF(tempPath)
+	report.PDF = info
+	return report.check("parse", err)
+}
+
+// saveTempFile 将上传的文件写入临时目录，调用方负责删除
+func saveTempFile(fileHeader *multipart.FileHeader) (string, error) {
+	src, err := fileHeader.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	out, err := services.CreateTempFile("validate-*")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(out, src)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+func checkExtension(filename string, allowExt []string) error {
+	if !services.IsAllowedFileType(filename, allowExt) {
+		return fmt.Errorf("不支持的文件类型，可选 %s", strings.Join(allowExt, " / "))
+	}
+	return nil
+}
+
+func checkSize(size, maxSize int64) error {
+	if size <= 0 {
+		return fmt.Errorf("文件为空")
+	}
+	if size > maxSize {
+		return fmt.Errorf("文件过大: %d 字节，上限 %d 字节", size, maxSize)
+	}
+	return nil
+}
+
+// checkMagicBytes 检查文件头与扩展名是否一致，防止改了扩展名的其他文件
+func checkMagicBytes(path, ext string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("读取文件失败: %w", err)
+	}
+	defer f.Close()
+
+	header := make([]byte, magicHeaderSize)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("读取文件头失败: %w", err)
+	}
+	header = header[:n]
+
+	switch ext {
+	case ".pdf":
+		if !bytes.Contains(header, pdfMagic) {
+			return fmt.Errorf("文件内容不是 PDF")
+		}
+	case ".zip":
+		if !bytes.HasPrefix(header, zipMagic) {
+			return fmt.Errorf("文件内容不是 ZIP 压缩包")
+		}
+	}
+	return nil
+}
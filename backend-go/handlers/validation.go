@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"doc-analysis-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindJSON 解析 JSON 请求体。字段类型错误时按字段返回校验错误，其余格式错误返回 400，返回 false 表示已写入错误响应
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	err := c.ShouldBindJSON(obj)
+	if err == nil {
+		return true
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		utils.ValidationFailed(c, utils.ValidationErrors{{
+			Field:   typeErr.Field,
+			Message: fmt.Sprintf("%s 的类型应为 %s", typeErr.Field, typeErr.Type),
+		}})
+		return false
+	}
+	utils.BadRequest(c, "请求参数错误: "+err.Error())
+	return false
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/models"
+	"doc-analysis-backend/queue"
+	"doc-analysis-backend/services"
+	"doc-analysis-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 未指定 full=true 时每个向量只返回前几个维度
+const vectorPreviewDims = 8
+
+type VectorHandler struct{}
+
+func NewVectorHandler() *VectorHandler {
+	return &VectorHandler{}
+}
+
+// GetFileVectors 返回文件在向量库中的向量，用于排查向量化问题（如全零向量、维度错误）
+func (h *VectorHandler) GetFileVectors(c *gin.Context) {
+	fileID := c.Param("id")
+	if fileID == "" {
+		utils.BadRequest(c, "文件ID不能为空")
+		return
+	}
+
+	db := database.GetDB()
+	var file models.FileRecord
+
+	if err := db.Scopes(tenantFiles(c)).Where("id = ?", fileID).First(&file).Error; err != nil {
+		utils.NotFound(c, "文件不存在")
+		return
+	}
+
+	full, _ := strconv.ParseBool(c.DefaultQuery("full", "false"))
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		utils.BadRequest(c, "limit 必须在 1 到 100 之间")
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		utils.BadRequest(c, "offset 不能为负数")
+		return
+	}
+
+	chromaClient := services.NewChromaClient()
+	result, err := chromaClient.GetDocuments(c.Request.Context(), services.ShardCollection(file.Collection, file.Shard), &services.ChromaGetRequest{
+		Where:   map[string]interface{}{"file_id": fileID},
+		Include: []string{"embeddings", "documents", "metadatas"},
+		Limit:   limit,
+		Offset:  offset,
+	})
+	if err != nil {
+		utils.InternalError(c, fmt.Sprintf("获取向量失败: %v", err))
+		return
+	}
+
+	vectors := make([]map[string]interface{}, 0, len(result.IDs))
+	for i, id := range result.IDs {
+		item := map[string]interface{}{
+			"id": id,
+		}
+		if i < len(result.Metadatas) {
+			item["metadata"] = result.Metadatas[i]
+		}
+		if i < len(result.Documents) {
+			item["document"] = result.Documents[i]
+		}
+		if i < len(result.Embeddings) {
+			embedding := result.Embeddings[i]
+			item["dimension"] = len(embedding)
+			item["norm"] = vectorNorm(embedding)
+			if full || len(embedding) <= vectorPreviewDims {
+				item["embedding"] = embedding
+			} else {
+				item["embedding"] = embedding[:vectorPreviewDims]
+				item["truncated"] = true
+			}
+		}
+		vectors = append(vectors, item)
+	}
+
+	data := map[string]interface{}{
+		"file_id": fileID,
+		"vectors": vectors,
+		"limit":   limit,
+		"offset":  offset,
+	}
+	if full {
+		data["warning"] = "完整向量数据量较大，请配合 limit 分页获取"
+	}
+
+	utils.Success(c, data)
+}
+
+func vectorNorm(v []float32) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	return math.Sqrt(sum)
+}
+
+// 移动集合时每次读取和写入的向量数量
+const moveCollectionPageSize = 500
+
+// Chroma 集合名称: 3-63 个字符，只能包含字母、数字、点、下划线和短横线，首尾为字母或数字
+var collectionNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]{1,61}[a-zA-Z0-9]$`)
+
+type MoveCollectionRequest struct {
+	Target string `json:"target" binding:"required"`
+}
+
+// MoveCollection 将文件的向量从当前集合移动到目标集合（不存在时创建）。
+// 先全部写入目标集合，成功后才更新文件记录并删除源集合中的向量；写入失败时清理目标集合中已写入的部分
+func (h *VectorHandler) MoveCollection(c *gin.Context) {
+	fileID := c.Param("id")
+	if fileID == "" {
+		utils.BadRequest(c, "文件ID不能为空")
+		return
+	}
+
+	var req MoveCollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "请求参数错误: "+err.Error())
+		return
+	}
+	if !collectionNamePattern.MatchString(req.Target) {
+		utils.BadRequest(c, "集合名称只能包含字母、数字、点、下划线和短横线，长度 3-63 且首尾为字母或数字")
+		return
+	}
+
+	db := database.GetDB()
+	var file models.FileRecord
+
+	if err := db.Scopes(tenantFiles(c)).Where("id = ?", fileID).First(&file).Error; err != nil {
+		utils.NotFound(c, "文件不存在")
+		return
+	}
+
+	source := services.ShardCollection(file.Collection, file.Shard)
+	if req.Target == source {
+		utils.BadRequest(c, "文件的向量已在目标集合中")
+		return
+	}
+
+	ctx := c.Request.Context()
+	lock, err := queue.AcquireFileLock(ctx, fileID, config.AppConfig.Lock.Wait)
+	if err != nil {
+		if errors.Is(err, queue.ErrFileLocked) {
+			utils.Error(c, http.StatusConflict, "文件正在处理中，请稍后再试")
+			return
+		}
+		utils.InternalError(c, fmt.Sprintf("获取文件锁失败: %v", err))
+		return
+	}
+	defer lock.Release()
+
+	chromaClient := services.NewChromaClient()
+	if err := chromaClient.CreateCollection(ctx, req.Target); err != nil {
+		utils.InternalError(c, fmt.Sprintf("创建目标集合失败: %v", err))
+		return
+	}
+
+	moved := 0
+	for offset := 0; ; offset += moveCollectionPageSize {
+		page, err := chromaClient.GetDocuments(ctx, source, &services.ChromaGetRequest{
+			Where:   map[string]interface{}{"file_id": fileID},
+			Include: []string{"embeddings", "documents", "metadatas"},
+			Limit:   moveCollectionPageSize,
+			Offset:  offset,
+		})
+		if err == nil && len(page.IDs) > 0 {
+			err = chromaClient.UpsertDocuments(ctx, req.Target, &services.ChromaAddRequest{
+				IDs:        page.IDs,
+				Documents:  page.Documents,
+				Metadatas:  page.Metadatas,
+				Embeddings: page.Embeddings,
+			})
+		}
+		if err != nil {
+			// 源集合保持不变，清理目标集合中已写入的部分
+			if moved > 0 {
+				chromaClient.DeleteDocumentsByFileID(ctx, req.Target, fileID)
+			}
+			utils.InternalError(c, fmt.Sprintf("写入目标集合失败: %v", err))
+			return
+		}
+
+		moved += len(page.IDs)
+		if len(page.IDs) < moveCollectionPageSize {
+			break
+		}
+	}
+
+	// 默认集合在记录中保存为空
+	collection := req.Target
+	if collection == services.CollectionName() {
+		collection = ""
+	}
+	// 目标集合不分片，移动后的向量位于集合本身
+	if err := db.Model(&file).Updates(map[string]interface{}{"collection": collection, "shard": 0}).Error; err != nil {
+		chromaClient.DeleteDocumentsByFileID(ctx, req.Target, fileID)
+		utils.InternalError(c, "更新文件记录失败")
+		return
+	}
+
+	services.InvalidateSearchCache(fileID)
+
+	data := map[string]interface{}{
+		"file_id": fileID,
+		"source":  source,
+		"target":  req.Target,
+		"moved":   moved,
+	}
+	if err := chromaClient.DeleteDocumentsByFileID(ctx, source, fileID); err != nil {
+		// 向量已在目标集合中且记录已更新，只是源集合中留有残余，不影响检索结果
+		data["warning"] = fmt.Sprintf("删除源集合中的向量失败: %v", err)
+	}
+
+	utils.Success(c, data)
+}
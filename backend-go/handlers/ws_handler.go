@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/middleware"
+	"doc-analysis-backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// 单个连接允许积压的消息数，超过说明客户端消费过慢，直接断开
+	wsSendBuffer = 32
+	wsWriteWait  = 10 * time.Second
+)
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		return origin == "" || middleware.IsAllowedOrigin(origin)
+	},
+}
+
+// 推送给看板的文件状态
+type fileStatusView struct {
+	ID             string    `json:"id"`
+	Filename       string    `json:"filename"`
+	Status         string    `json:"status"`
+	Progress       int       `json:"progress"`
+	Message        string    `json:"message"`
+	ChunksCount    int       `json:"chunks_count"`
+	EmbeddedChunks int       `json:"embedded_chunks"` // 部分完成时小于 chunks_count
+	Hold           bool      `json:"hold"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	// 文件所属租户，只推送给同一租户的连接
+	tenantID string
+}
+
+type wsMessage struct {
+	Type    string           `json:"type"` // snapshot / update
+	Files   []fileStatusView `json:"files"`
+	Deleted []string         `json:"deleted,omitempty"`
+}
+
+type wsClient struct {
+	conn *websocket.Conn
+	send chan []byte
+	// 连接所属租户，为空表示不限制租户
+	tenantID string
+}
+
+// visible 判断文件是否属于连接所属的租户
+func (client *wsClient) visible(view fileStatusView) bool {
+	return client.tenantID == "" || client.tenantID == view.tenantID
+}
+
+// fileStatusHub 统一轮询数据库，将文件状态变化广播给所有连接，避免每个连接各自查询
+type fileStatusHub struct {
+	mu      sync.Mutex
+	clients map[*wsClient]struct{}
+	state   map[string]fileStatusView
+}
+
+type WSHandler struct {
+	hub *fileStatusHub
+}
+
+func NewWSHandler() *WSHandler {
+	hub := &fileStatusHub{
+		clients: make(map[*wsClient]struct{}),
+	}
+	go hub.run()
+
+	return &WSHandler{hub: hub}
+}
+
+// FilesStatus 通过 WebSocket 推送所有文件的状态: 连接后先发送全量快照，之后只推送变化。
+// 启用多租户时只推送所属租户的文件
+func (h *WSHandler) FilesStatus(c *gin.Context) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("WebSocket 升级失败: %v", err)
+		return
+	}
+
+	client := &wsClient{
+		conn:     conn,
+		send:     make(chan []byte, wsSendBuffer),
+		tenantID: tenantID(c),
+	}
+	if err := h.hub.register(client); err != nil {
+		log.Printf("发送文件状态快照失败: %v", err)
+		conn.Close()
+		return
+	}
+
+	go client.writeLoop()
+	client.readLoop()
+	h.hub.unregister(client)
+}
+
+func (hub *fileStatusHub) register(client *wsClient) error {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if hub.state == nil {
+		state, err := loadFileStatusViews()
+		if err != nil {
+			return err
+		}
+		hub.state = state
+	}
+
+	files := make([]fileStatusView, 0, len(hub.state))
+	for _, view := range hub.state {
+		if client.visible(view) {
+			files = append(files, view)
+		}
+	}
+	data, err := json.Marshal(wsMessage{Type: "snapshot", Files: files})
+	if err != nil {
+		return err
+	}
+
+	client.send <- data
+	hub.clients[client] = struct{}{}
+	return nil
+}
+
+func (hub *fileStatusHub) unregister(client *wsClient) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if _, ok := hub.clients[client]; ok {
+		delete(hub.clients, client)
+		close(client.send)
+	}
+}
+
+func (hub *fileStatusHub) run() {
+	ticker := time.NewTicker(config.AppConfig.Stream.PollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		hub.poll()
+	}
+}
+
+func (hub *fileStatusHub) poll() {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	// 没有连接时不查询数据库，下次有连接时重新加载快照
+	if len(hub.clients) == 0 {
+		hub.state = nil
+		return
+	}
+
+	current, err := loadFileStatusViews()
+	if err != nil {
+		log.Printf("轮询文件状态失败: %v", err)
+		return
+	}
+
+	var changed, deleted []fileStatusView
+	for id, view := range current {
+		if old, ok := hub.state[id]; !ok || old != view {
+			changed = append(changed, view)
+		}
+	}
+	for id, view := range hub.state {
+		if _, ok := current[id]; !ok {
+			deleted = append(deleted, view)
+		}
+	}
+	hub.state = current
+
+	if len(changed) == 0 && len(deleted) == 0 {
+		return
+	}
+
+	// 同一租户的连接收到的消息相同，每个租户只序列化一次
+	messages := make(map[string][]byte)
+	for client := range hub.clients {
+		data, ok := messages[client.tenantID]
+		if !ok {
+			data = client.updateMessage(changed, deleted)
+			messages[client.tenantID] = data
+		}
+		if data == nil {
+			continue
+		}
+		select {
+		case client.send <- data:
+		default:
+			// 客户端消费过慢，断开连接由其重连后重新获取快照
+			delete(hub.clients, client)
+			close(client.send)
+		}
+	}
+}
+
+// updateMessage 生成推送给该连接的变化消息，没有属于其租户的变化时返回 nil
+func (client *wsClient) updateMessage(changed, deleted []fileStatusView) []byte {
+	msg := wsMessage{Type: "update"}
+	for _, view := range changed {
+		if client.visible(view) {
+			msg.Files = append(msg.Files, view)
+		}
+	}
+	for _, view := range deleted {
+		if client.visible(view) {
+			msg.Deleted = append(msg.Deleted, view.ID)
+		}
+	}
+	if len(msg.Files) == 0 && len(msg.Deleted) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("序列化文件状态失败: %v", err)
+		return nil
+	}
+	return data
+}
+
+func loadFileStatusViews() (map[string]fileStatusView, error) {
+	var files []models.FileRecord
+	err := database.GetDB().
+		Select("id", "tenant_id", "filename", "status", "progress", "message", "chunks_count", "embedded_chunks", "hold", "updated_at").
+		Find(&files).Error
+	if err != nil {
+		return nil, err
+	}
+
+	views := make(map[string]fileStatusView, len(files))
+	for _, f := range files {
+		views[f.ID.String()] = newFileStatusView(&f)
+	}
+	return views, nil
+}
+
+// loadFileStatusView 读取当前租户的单个文件的状态
+func loadFileStatusView(c *gin.Context, fileID string) (fileStatusView, error) {
+	var file models.FileRecord
+	err := database.GetDB().
+		Scopes(tenantFiles(c)).
+		Select("id", "tenant_id", "filename", "status", "progress", "message", "chunks_count", "embedded_chunks", "hold", "updated_at").
+		Where("id = ?", fileID).
+		First(&file).Error
+	if err != nil {
+		return fileStatusView{}, err
+	}
+	return newFileStatusView(&file), nil
+}
+
+func newFileStatusView(f *models.FileRecord) fileStatusView {
+	return fileStatusView{
+		ID:             f.ID.String(),
+		Filename:       f.Filename,
+		Status:         f.Status,
+		Progress:       f.Progress,
+		Message:        f.Message,
+		ChunksCount:    f.ChunksCount,
+		EmbeddedChunks: f.EmbeddedChunks,
+		Hold:           f.Hold,
+		UpdatedAt:      f.UpdatedAt,
+		tenantID:       f.TenantID,
+	}
+}
+
+// writeLoop 发送状态消息，并定期发送 ping 保持连接不被代理断开
+func (client *wsClient) writeLoop() {
+	heartbeat := config.AppConfig.Stream.HeartbeatInterval
+	ticker := time.NewTicker(heartbeat)
+	defer func() {
+		ticker.Stop()
+		client.conn.Close()
+	}()
+
+	for {
+		select {
+		case data, ok := <-client.send:
+			client.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				client.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := client.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			client.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := client.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readLoop 处理 pong 和关闭帧，连续两个心跳周期收不到 pong 视为断开
+func (client *wsClient) readLoop() {
+	readWait := 2 * config.AppConfig.Stream.HeartbeatInterval
+	client.conn.SetReadDeadline(time.Now().Add(readWait))
+	client.conn.SetPongHandler(func(string) error {
+		client.conn.SetReadDeadline(time.Now().Add(readWait))
+		return nil
+	})
+
+	for {
+		if _, _, err := client.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
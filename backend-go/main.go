@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"net/http"
 	"os"
@@ -12,8 +13,12 @@ import (
 	"doc-analysis-backend/config"
 	"doc-analysis-backend/database"
 	"doc-analysis-backend/handlers"
+	"doc-analysis-backend/metrics"
 	"doc-analysis-backend/middleware"
+	"doc-analysis-backend/openapi"
 	"doc-analysis-backend/queue"
+	"doc-analysis-backend/services"
+	"doc-analysis-backend/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -21,6 +26,19 @@ import (
 func main() {
 	// 初始化配置
 	config.InitConfig()
+	mode := flag.String("mode", config.AppConfig.Server.Mode, "运行模式: server（只提供 HTTP 接口）/ worker（只处理队列任务）/ all（两者都运行）")
+	flag.Parse()
+	if *mode != "server" && *mode != "worker" && *mode != "all" {
+		log.Fatalf("不支持的运行模式: %s，可选 server / worker / all", *mode)
+	}
+	utils.InitLogger()
+
+	if err := services.InitTempDir(); err != nil {
+		log.Fatalf("%v", err)
+	}
+	if err := services.InitUploadDir(); err != nil {
+		log.Fatalf("%v", err)
+	}
 
 	// 初始化数据库
 	database.InitDatabase()
@@ -30,11 +48,36 @@ func main() {
 	queue.InitQueue()
 	defer queue.CloseQueue()
 
+	// 初始化向量集合，已有集合的度量或维度与配置不一致时拒绝启动
+	if err := services.InitChromaDB(context.Background()); err != nil {
+		log.Fatalf("%v", err)
+	}
+	// 配置了备用向量化服务时，两者的向量维度不一致则拒绝启动
+	if err := services.VerifyFallbackDimension(); err != nil {
+		log.Fatalf("%v", err)
+	}
+	services.StartEmbeddingHealthCheck()
+
+	log.Printf("运行模式: %s", *mode)
+	log.Printf("ChromaDB 请求超时: %s, 向量化请求超时: %s", config.AppConfig.ChromaDB.Timeout, config.AppConfig.Embedding.Timeout)
+	if *mode == "worker" {
+		// 收到中断信号后等待进行中的任务结束再退出
+		queue.StartWorker()
+		return
+	}
+	runServer(*mode == "all")
+}
+
+// setupRouter 创建 Gin 路由器并注册所有接口
+func setupRouter() *gin.Engine {
 	// 创建 Gin 路由器
-	gin.SetMode(gin.ReleaseMode)
+	gin.SetMode(config.AppConfig.Server.GinMode)
 	r := gin.New()
+	// 上传文件超出该大小的部分写入临时目录，并发上传时的内存占用约为 UPLOAD_MAX_CONCURRENT 倍
+	r.MaxMultipartMemory = config.AppConfig.Upload.MaxMultipartMemory
 
-	// 添加中间件
+	// 添加中间件，压缩需要在访问日志之外，日志中记录的是压缩前的响应体
+	r.Use(middleware.Gzip())
 	r.Use(middleware.Logger())
 	r.Use(middleware.Recovery())
 	r.Use(middleware.CORS())
@@ -47,45 +90,172 @@ func main() {
 		})
 	})
 
-	// API 路由
-	api := r.Group("/api")
+	// 就绪检查
+	r.GET("/ready", handlers.NewHealthHandler().Ready)
+
+	// Prometheus 指标
+	r.GET("/metrics", metrics.Handler())
+
+	// API 文档
+	handlers.RegisterAPIDocs()
+	r.GET("/api/openapi.json", openapi.Handler(r))
+	r.GET("/docs", openapi.DocsHandler("/api/openapi.json"))
+
+	// API 路由，配置了 TENANT_API_KEYS 时按密钥确定请求所属的租户
+	api := r.Group("/api", middleware.Tenant())
 	{
 		fileHandler := handlers.NewFileHandler()
 		statsHandler := handlers.NewStatsHandler()
+		vectorHandler := handlers.NewVectorHandler()
+		wsHandler := handlers.NewWSHandler()
+		estimateHandler := handlers.NewEstimateHandler()
+		taskHandler := handlers.NewTaskHandler()
+		streamHandler := handlers.NewStreamHandler()
+		searchHandler := handlers.NewSearchHandler()
+		reportHandler := handlers.NewReportHandler()
+		pageHandler := handlers.NewPageHandler()
+		metadataHandler := handlers.NewMetadataHandler()
+		tagHandler := handlers.NewTagHandler()
+		validateHandler := handlers.NewValidateHandler()
+		embedHandler := handlers.NewEmbedHandler()
 
 		// 添加 OPTIONS 处理器用于 CORS 预检
 		api.OPTIONS("/upload-files", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/upload-config", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/validate", func(c *gin.Context) { c.Status(200) })
 		api.OPTIONS("/files/status", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/files/status/summary", func(c *gin.Context) { c.Status(200) })
 		api.OPTIONS("/files/:id/status", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/files/:id/status/stream", func(c *gin.Context) { c.Status(200) })
 		api.OPTIONS("/files/:id/process", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/files/:id/reembed", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/files/:id/retry-failed", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/files/:id/logs", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/files/:id/runs", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/files/:id/chunks", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/files/:id/logs/stream", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/files/:id/vectors", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/files/:id/report", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/files/:id/download", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/files/:id/move-collection", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/files/:id/page/:n/image", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/files/cleanup", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/files/tags", func(c *gin.Context) { c.Status(200) })
 		api.OPTIONS("/process-all", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/estimate", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/search", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/files/:id/search", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/embed", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/metadata/values", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/tasks", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/tasks/:id", func(c *gin.Context) { c.Status(200) })
 		api.OPTIONS("/files/:id", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/files/:id/hold", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/files/:id/unhold", func(c *gin.Context) { c.Status(200) })
 		api.OPTIONS("/database/stats", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/stats/throughput", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/admin/config", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/admin/consistency", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/admin/repair", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/admin/retention/run", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/admin/migrate", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/admin/export", func(c *gin.Context) { c.Status(200) })
+		api.OPTIONS("/admin/import", func(c *gin.Context) { c.Status(200) })
 
 		// 文件上传和管理
-		api.POST("/upload-files", fileHandler.UploadFiles)
+		api.POST("/upload-files", middleware.UploadLimiter(), fileHandler.UploadFiles)
+		api.GET("/upload-config", fileHandler.GetUploadConfig)
+		api.POST("/validate", validateHandler.Validate)
 		api.GET("/files/status", fileHandler.GetAllFilesStatus)
+		api.GET("/files/status/summary", statsHandler.GetStatusSummary)
 		api.GET("/files/:id/status", fileHandler.GetFileStatus)
 		api.POST("/files/:id/process", fileHandler.ProcessFile)
+		api.POST("/files/:id/reembed", fileHandler.ReembedFile)
+		api.POST("/files/:id/retry-failed", fileHandler.RetryFailedChunks)
+		api.POST("/files/:id/hold", fileHandler.HoldFile)
+		api.POST("/files/:id/unhold", fileHandler.UnholdFile)
+		api.GET("/files/:id/logs", fileHandler.GetFileLogs)
+		api.GET("/files/:id/runs", fileHandler.GetFileRuns)
+		api.GET("/files/:id/chunks", fileHandler.GetFileChunks)
+		api.GET("/files/:id/vectors", vectorHandler.GetFileVectors)
+		api.POST("/files/:id/move-collection", vectorHandler.MoveCollection)
+		api.GET("/files/:id/report", reportHandler.GetFileReport)
+		api.GET("/files/:id/download", fileHandler.DownloadFile)
+		api.GET("/files/:id/page/:n/image", pageHandler.GetPageImage)
+
+		// 实时状态推送
+		api.GET("/ws/files", wsHandler.FilesStatus)
+		api.GET("/files/:id/status/stream", streamHandler.FileStatus)
+		api.GET("/files/:id/logs/stream", streamHandler.FileLogs)
 		api.POST("/process-all", fileHandler.ProcessAllFiles)
 		api.DELETE("/files/:id", fileHandler.DeleteFile)
+		api.POST("/files/cleanup", fileHandler.CleanupFiles)
+		api.POST("/files/tags", tagHandler.BulkUpdate)
+
+		// 成本估算
+		api.POST("/estimate", estimateHandler.Estimate)
+
+		// 检索
+		api.POST("/search", searchHandler.Search)
+		api.POST("/files/:id/search", searchHandler.SearchFile)
+
+		// 文本向量化，会消耗向量化服务的额度，需要密钥并限制频率
+		embedCfg := config.AppConfig.EmbedAPI
+		api.POST("/embed", middleware.RequireAPIKey(), middleware.RateLimit(embedCfg.RateLimit, embedCfg.RateWindow), embedHandler.Embed)
+		api.GET("/metadata/values", metadataHandler.GetValues)
 
 		// 统计功能
 		api.GET("/database/stats", statsHandler.GetDatabaseStats)
+		api.GET("/stats/throughput", statsHandler.GetThroughput)
+		api.GET("/tasks", taskHandler.ListTasks)
+		api.GET("/tasks/:id", taskHandler.GetTask)
+
+		// 管理接口
+		admin := api.Group("/admin", middleware.AdminAuth())
+		{
+			adminHandler := handlers.NewAdminHandler()
+
+			admin.GET("/config", adminHandler.GetConfig)
+			admin.PUT("/config", adminHandler.UpdateConfig)
+			admin.POST("/retention/run", adminHandler.RunRetention)
+			admin.POST("/migrate", adminHandler.Migrate)
+
+			// 数据库与向量库一致性检查
+			consistencyHandler := handlers.NewConsistencyHandler()
+			admin.GET("/consistency", consistencyHandler.Check)
+			admin.POST("/repair", consistencyHandler.Repair)
+
+			// 文件目录导出和导入，用于备份或迁移到其他环境
+			catalogHandler := handlers.NewCatalogHandler()
+			admin.GET("/export", catalogHandler.Export)
+			admin.POST("/import", catalogHandler.Import)
+		}
 	}
 
+	return r
+}
+
+// runServer 启动 HTTP 服务，withWorker 为 true 时同时在进程内运行任务工作器
+func runServer(withWorker bool) {
+	r := setupRouter()
+
 	// 启动服务器
 	cfg := config.AppConfig
 	srv := &http.Server{
 		Addr:    cfg.Server.Host + ":" + cfg.Server.Port,
-		Handler: r,
+		Handler: middleware.RequestTimeout(r),
 	}
 
+	// 按保留策略定期清理过期文件
+	queue.StartRetentionJob()
+
 	// 启动后台任务处理器
-	go func() {
-		log.Println("启动任务工作器...")
-		queue.StartWorker()
-	}()
+	if withWorker {
+		go func() {
+			log.Println("启动任务工作器...")
+			queue.StartWorker()
+		}()
+	}
 
 	// 优雅启动
 	go func() {
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// 当前正在处理的上传请求数
+	UploadsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "doc_uploads_in_flight",
+		Help: "Number of upload requests currently being processed",
+	})
+
+	// 因并发上限被拒绝的上传请求数
+	UploadsRejected = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "doc_uploads_rejected_total",
+		Help: "Total number of upload requests rejected by the concurrency limit",
+	})
+
+	// 向量化服务探测结果，1 表示可用
+	EmbeddingProviderUp = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "doc_embedding_provider_up",
+		Help: "Whether the embedding provider responded to the last health probe (1 = up, 0 = down)",
+	})
+
+	// 主向量化服务失败后改用备用服务的次数
+	EmbeddingFailovers = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "doc_embedding_failovers_total",
+		Help: "Total number of embedding requests served by the fallback provider after the primary failed",
+	})
+
+	// 向量化熔断器状态，0 正常、1 等待试探请求、2 熔断中
+	EmbeddingBreakerState = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "doc_embedding_circuit_state",
+		Help: "State of the embedding circuit breaker (0 = closed, 1 = half-open, 2 = open)",
+	})
+
+	// 熔断期间直接失败、未发送到向量化服务的请求数
+	EmbeddingBreakerRejected = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "doc_embedding_circuit_rejected_total",
+		Help: "Total number of embedding requests failed fast by the open circuit breaker",
+	})
+
+	// ChromaDB 请求获取到的连接，reused 为 false 表示新建了连接，占比过高说明连接池过小
+	ChromaConnections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "doc_chroma_connections_total",
+		Help: "Total number of connections obtained for ChromaDB requests, labelled by whether an idle connection was reused",
+	}, []string{"reused"})
+
+	// 向量化成功的块数，按存储方式区分，跳过存储的压测同样计入
+	ChunksEmbedded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "doc_chunks_embedded_total",
+		Help: "Total number of chunks embedded successfully, labelled by vector store mode",
+	}, []string{"store_mode"})
+
+	// 任务从入队到开始执行的等待时间
+	TaskQueueWait = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "doc_task_queue_wait_seconds",
+		Help:    "Time tasks spend in the queue before a worker starts processing them",
+		Buckets: prometheus.ExponentialBuckets(0.1, 2, 16),
+	})
+)
+
+// Handler 暴露 Prometheus 指标
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"sync"
+
+	"doc-analysis-backend/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(nil) },
+}
+
+// Gzip 客户端支持 gzip 时压缩 JSON、文本类响应。响应体不足 GZIP_MIN_SIZE 字节时不压缩；
+// PDF、图片等已压缩的内容，SSE 以及设置了 Content-Encoding 的响应原样输出。
+// 需要在 Logger 之前注册，开启 LOG_BODIES 时访问日志记录的才是压缩前的响应体
+func Gzip() gin.HandlerFunc {
+	cfg := config.AppConfig.Compression
+	if !cfg.Enabled {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead || c.GetHeader("Upgrade") != "" || !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		w := &gzipWriter{ResponseWriter: c.Writer, minSize: cfg.MinSize}
+		c.Writer = w
+		defer w.finish()
+
+		c.Next()
+	}
+}
+
+// gzipWriter 先缓存响应体的开头，达到 minSize 后才决定压缩，响应结束时仍不足的内容原样写出
+type gzipWriter struct {
+	gin.ResponseWriter
+	minSize int
+	buf     []byte
+	gz      *gzip.Writer
+	// 已决定是否压缩，之后的内容直接写出
+	decided bool
+}
+
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		if w.gz != nil {
+			return w.gz.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+	if !isCompressible(w.Header()) {
+		w.decided = true
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.buf = append(w.buf, data...)
+	if len(w.buf) >= w.minSize {
+		if err := w.startGzip(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush 流式响应要求立即输出，此时还未达到 minSize 的内容不再等待，按原样写出
+func (w *gzipWriter) Flush() {
+	if !w.decided {
+		w.writePlain()
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *gzipWriter) startGzip() error {
+	w.decided = true
+	header := w.Header()
+	header.Set("Content-Encoding", "gzip")
+	header.Add("Vary", "Accept-Encoding")
+	header.Del("Content-Length")
+
+	w.gz = gzipWriterPool.Get().(*gzip.Writer)
+	w.gz.Reset(w.ResponseWriter)
+	_, err := w.gz.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+func (w *gzipWriter) writePlain() {
+	w.decided = true
+	if len(w.buf) > 0 {
+		w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+}
+
+func (w *gzipWriter) finish() {
+	if !w.decided {
+		w.writePlain()
+		return
+	}
+	if w.gz != nil {
+		w.gz.Close()
+		gzipWriterPool.Put(w.gz)
+		w.gz = nil
+	}
+}
+
+// isCompressible 只压缩文本类内容，PDF、图片、ZIP 等本身已压缩，再压缩几乎没有收益
+func isCompressible(header http.Header) bool {
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := strings.ToLower(header.Get("Content-Type"))
+	if strings.HasPrefix(contentType, "text/event-stream") {
+		return false
+	}
+	return strings.HasPrefix(contentType, "text/") ||
+		strings.Contains(contentType, "json") ||
+		strings.Contains(contentType, "javascript") ||
+		strings.Contains(contentType, "xml")
+}
+
+// acceptsGzip 解析 Accept-Encoding，q=0 表示客户端明确不接受
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "gzip" && name != "*" {
+			continue
+		}
+		q := strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
@@ -1,38 +1,65 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"time"
 
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/utils"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
+// IsAllowedOrigin 判断前端地址是否允许跨域访问，由 CORS_ALLOWED_ORIGINS 配置
+func IsAllowedOrigin(origin string) bool {
+	for _, allowed := range config.AppConfig.Server.CORSOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
 func CORS() gin.HandlerFunc {
 	return cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000", "http://localhost:3001", "http://localhost:5173"},
+		AllowOriginFunc:  IsAllowedOrigin,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	})
 }
 
+// Logger 访问日志，查询参数中的敏感字段会被脱敏。
+// 开启 LOG_BODIES 时额外记录请求头、JSON 请求体和响应体，Authorization 等请求头以及 LOG_REDACT_FIELDS 中的字段会被脱敏
 func Logger() gin.HandlerFunc {
-	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		return fmt.Sprintf("%s - [%s] \"%s %s %s %d %s \"%s\" %s\"\n",
+	logger := gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		line := fmt.Sprintf("%s - [%s] \"%s %s %s %d %s \"%s\" %s\"\n",
 			param.ClientIP,
 			param.TimeStamp.Format(time.RFC1123),
 			param.Method,
-			param.Path,
+			redactQuery(param.Path),
 			param.Request.Proto,
 			param.StatusCode,
 			param.Latency,
 			param.Request.UserAgent(),
 			param.ErrorMessage,
 		)
+		if capture, ok := param.Keys[logBodyKey].(*bodyCapture); ok {
+			line += capture.format()
+		}
+		return line
 	})
+
+	return func(c *gin.Context) {
+		if config.AppConfig.Log.Bodies {
+			captureBodies(c)
+		}
+		logger(c)
+	}
 }
 
 func Recovery() gin.HandlerFunc {
@@ -42,4 +69,24 @@ func Recovery() gin.HandlerFunc {
 		}
 		c.AbortWithStatus(500)
 	})
-}
\ No newline at end of file
+}
+
+// AdminAuth 管理接口鉴权，支持 X-API-Key 或 Authorization: Bearer 两种方式传递密钥
+func AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminKey := config.AppConfig.Auth.AdminAPIKey
+		if adminKey == "" {
+			utils.Error(c, 403, "未配置管理员密钥，管理接口已禁用")
+			c.Abort()
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(requestAPIKey(c)), []byte(adminKey)) != 1 {
+			utils.Error(c, 401, "无效的管理员密钥")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"doc-analysis-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit 按固定时间窗口限制请求频率，每个密钥（未携带密钥时按客户端 IP）在 window 内最多 limit 次，
+// 超出时返回 429 并通过 Retry-After 告知窗口剩余时间。limit 为 0 时不限制。
+// 计数保存在进程内存中，多实例部署时每个实例分别计数
+func RateLimit(limit int, window time.Duration) gin.HandlerFunc {
+	if limit <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	var mu sync.Mutex
+	windowStart := time.Now()
+	counts := map[string]int{}
+
+	return func(c *gin.Context) {
+		key := requestAPIKey(c)
+		if key == "" {
+			key = "ip:" + c.ClientIP()
+		}
+
+		mu.Lock()
+		now := time.Now()
+		// 进入新窗口时清空所有计数，不需要逐个清理过期的键
+		if elapsed := now.Sub(windowStart); elapsed >= window {
+			windowStart = now.Add(-(elapsed % window))
+			counts = map[string]int{}
+		}
+		counts[key]++
+		exceeded := counts[key] > limit
+		retryAfter := windowStart.Add(window).Sub(now)
+		mu.Unlock()
+
+		if exceeded {
+			c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			utils.Error(c, http.StatusTooManyRequests, "请求过于频繁，请稍后重试")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"doc-analysis-backend/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	redactedValue = "***"
+	// Logger 从 context 中读取请求体、响应体的键
+	logBodyKey = "log_body"
+)
+
+// 始终脱敏的请求头，LOG_REDACT_FIELDS 中的字段名同样会作用于请求头
+var sensitiveHeaders = []string{"Authorization", "X-API-Key", "Cookie", "Proxy-Authorization"}
+
+// bodyCapture 记录请求体和响应体开头不超过 limit 字节的内容，供访问日志使用
+type bodyCapture struct {
+	gin.ResponseWriter
+	limit             int
+	header            http.Header
+	request, response []byte
+	requestSize       int64
+	responseTruncated bool
+}
+
+func (w *bodyCapture) Write(data []byte) (int, error) {
+	w.record(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyCapture) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *bodyCapture) record(data []byte) {
+	if !isJSON(w.ResponseWriter.Header().Get("Content-Type")) {
+		return
+	}
+	if room := w.limit - len(w.response); room < len(data) {
+		w.response = append(w.response, data[:max(room, 0)]...)
+		w.responseTruncated = true
+		return
+	}
+	w.response = append(w.response, data...)
+}
+
+// captureBodies 读取 JSON 请求体的开头并替换响应 writer，multipart 上传等非 JSON 内容只记录大小
+func captureBodies(c *gin.Context) {
+	limit := config.AppConfig.Log.MaxBodyBytes
+	capture := &bodyCapture{
+		ResponseWriter: c.Writer,
+		limit:          limit,
+		header:         c.Request.Header.Clone(),
+		requestSize:    c.Request.ContentLength,
+	}
+
+	if c.Request.Body != nil && isJSON(c.GetHeader("Content-Type")) {
+		head, _ := io.ReadAll(io.LimitReader(c.Request.Body, int64(limit)+1))
+		// 读取过的部分重新拼回请求体，处理函数仍能读到完整内容
+		c.Request.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(head), c.Request.Body), c.Request.Body}
+		capture.request = head
+	}
+
+	c.Writer = capture
+	c.Set(logBodyKey, capture)
+}
+
+// format 生成脱敏后的请求头、请求体、响应体日志
+func (w *bodyCapture) format() string {
+	fields := redactFieldSet()
+	return fmt.Sprintf("  headers: %s\n  request: %s\n  response: %s\n",
+		redactHeaders(w.header, fields),
+		redactBody(w.request, len(w.request) > w.limit, w.requestSize, fields),
+		redactBody(w.response, w.responseTruncated, -1, fields),
+	)
+}
+
+func redactFieldSet() map[string]bool {
+	fields := make(map[string]bool)
+	for _, field := range config.AppConfig.Log.RedactFields {
+		fields[strings.ToLower(field)] = true
+	}
+	return fields
+}
+
+func redactHeaders(header http.Header, fields map[string]bool) string {
+	for _, name := range sensitiveHeaders {
+		fields[strings.ToLower(name)] = true
+	}
+
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		value := strings.Join(header[name], ", ")
+		if fields[strings.ToLower(name)] {
+			value = redactedValue
+		}
+		parts = append(parts, name+"="+value)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// redactBody 脱敏 JSON 中的敏感字段。截断或无法解析的内容无法可靠地脱敏，不输出内容只输出大小
+func redactBody(body []byte, truncated bool, size int64, fields map[string]bool) string {
+	if len(body) == 0 {
+		if size > 0 {
+			return fmt.Sprintf("(%d 字节，未记录)", size)
+		}
+		return "-"
+	}
+	if truncated {
+		return fmt.Sprintf("(超过 %d 字节，未记录)", config.AppConfig.Log.MaxBodyBytes)
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Sprintf("(%d 字节，不是合法的 JSON，未记录)", len(body))
+	}
+	data, err := json.Marshal(redactValue(value, fields))
+	if err != nil {
+		return fmt.Sprintf("(%d 字节，未记录)", len(body))
+	}
+	return string(data)
+}
+
+// redactValue 递归替换对象中键名（不区分大小写）属于 fields 的值
+func redactValue(value interface{}, fields map[string]bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if fields[strings.ToLower(key)] {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactValue(item, fields)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item, fields)
+		}
+	}
+	return value
+}
+
+// redactQuery 脱敏路径中查询参数的值，如 ?api_key=xxx
+func redactQuery(path string) string {
+	pathPart, rawQuery, ok := strings.Cut(path, "?")
+	if !ok {
+		return path
+	}
+
+	fields := redactFieldSet()
+	params := strings.Split(rawQuery, "&")
+	for i, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		if name, err := url.QueryUnescape(key); err == nil && fields[strings.ToLower(name)] {
+			params[i] = key + "=" + redactedValue
+		}
+	}
+	return pathPart + "?" + strings.Join(params, "&")
+}
+
+func isJSON(contentType string) bool {
+	return strings.Contains(strings.ToLower(contentType), "json")
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TenantContextKey 当前请求所属租户在 gin.Context 中的键，值为空表示不限制租户
+const TenantContextKey = "tenant_id"
+
+// Tenant 配置了 TENANT_API_KEYS 时按请求携带的密钥确定租户，密钥无效时返回 401；
+// 使用管理员密钥的请求不限制租户，可以访问所有文件。未配置时不做任何处理
+func Tenant() gin.HandlerFunc {
+	tenants := config.AppConfig.Auth.TenantAPIKeys
+	if len(tenants) == 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return func(c *gin.Context) {
+		// CORS 预检请求不携带密钥
+		if c.Request.Method == http.MethodOptions {
+			c.Next()
+			return
+		}
+
+		key := []byte(requestAPIKey(c))
+		if adminKey := config.AppConfig.Auth.AdminAPIKey; adminKey != "" && subtle.ConstantTimeCompare(key, []byte(adminKey)) == 1 {
+			c.Next()
+			return
+		}
+		for tenantKey, tenant := range tenants {
+			if subtle.ConstantTimeCompare(key, []byte(tenantKey)) == 1 {
+				c.Set(TenantContextKey, tenant)
+				c.Next()
+				return
+			}
+		}
+
+		utils.Error(c, 401, "无效的 API 密钥")
+		c.Abort()
+	}
+}
+
+// RequireAPIKey 要求请求携带有效的密钥，用于会消耗向量化额度等需要限制调用方的接口。
+// 配置了 TENANT_API_KEYS 时密钥已由 Tenant 校验；否则只接受管理员密钥，未配置管理员密钥时接口不可用
+func RequireAPIKey() gin.HandlerFunc {
+	if len(config.AppConfig.Auth.TenantAPIKeys) > 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return AdminAuth()
+}
+
+// requestAPIKey 读取请求携带的密钥，支持 X-API-Key 或 Authorization: Bearer 两种方式
+func requestAPIKey(c *gin.Context) string {
+	key := c.GetHeader("X-API-Key")
+	if key == "" {
+		key = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	}
+	return key
+}
@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/utils"
+)
+
+// RequestTimeout 为每个请求的 context 设置超时时间，处理函数超时后返回 504。
+// gin.Context 由对象池复用，在 gin 中间件里无法安全地放弃仍在运行的处理函数，
+// 所以超时控制放在 http.Handler 层，整个 gin 处理链运行在独立的 goroutine 中。
+// 响应在处理完成后统一写出，超时时丢弃已写入的内容改为返回 504；处理函数调用 Flush 后响应头和已缓存的内容
+// 立即写出，之后的写入直接到达客户端，此时已无法再返回 504，超时只会取消请求的 context。
+// SSE、下载等流式接口不受超时限制。
+func RequestTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := routeTimeout(r.URL.Path)
+		if timeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+
+		tw := &timeoutWriter{w: w, header: make(http.Header)}
+		done := make(chan struct{})
+		panicChan := make(chan interface{}, 1)
+
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicChan <- p
+				}
+			}()
+			next.ServeHTTP(tw, r)
+			close(done)
+		}()
+
+		select {
+		case p := <-panicChan:
+			panic(p)
+		case <-done:
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+
+			tw.commit()
+		case <-ctx.Done():
+			tw.mu.Lock()
+			if tw.committed {
+				// 响应已开始写出，处理函数仍在直接写入 w，必须等它结束后才能返回
+				tw.mu.Unlock()
+				select {
+				case p := <-panicChan:
+					panic(p)
+				case <-done:
+				}
+				return
+			}
+			defer tw.mu.Unlock()
+
+			tw.timedOut = true
+			// 客户端主动断开时无需再写响应
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				body, _ := json.Marshal(utils.Response{
+					Code:    http.StatusGatewayTimeout,
+					Message: "请求处理超时",
+				})
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				w.WriteHeader(http.StatusGatewayTimeout)
+				w.Write(body)
+			}
+		}
+	})
+}
+
+// routeTimeout 返回请求路径对应的超时时间，优先使用最长匹配的路由前缀配置，返回 0 表示不限制
+func routeTimeout(path string) time.Duration {
+	cfg := config.AppConfig.Server
+
+	for _, excluded := range cfg.TimeoutExcludePaths {
+		if strings.Contains(path, excluded) {
+			return 0
+		}
+	}
+
+	timeout := cfg.RequestTimeout
+	matched := ""
+	for prefix, d := range cfg.RouteTimeouts {
+		if strings.HasPrefix(path, prefix) && len(prefix) > len(matched) {
+			matched = prefix
+			timeout = d
+		}
+	}
+	return timeout
+}
+
+// timeoutWriter 缓存处理函数的响应，只有在未超时的情况下才写回客户端；Flush 之后改为直接写出
+type timeoutWriter struct {
+	mu        sync.Mutex
+	w         http.ResponseWriter
+	header    http.Header
+	buf       bytes.Buffer
+	code      int
+	timedOut  bool
+	committed bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.committed {
+		return tw.w.Write(p)
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.code != 0 {
+		return
+	}
+	tw.code = code
+}
+
+// Flush 写出响应头和已缓存的内容，之后的写入不再缓存，用于导出等边生成边输出的响应
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return
+	}
+	tw.commit()
+	if flusher, ok := tw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// commit 将响应头、状态码和缓存的内容写到 w，只执行一次，调用方持有锁
+func (tw *timeoutWriter) commit() {
+	if tw.committed {
+		return
+	}
+	tw.committed = true
+
+	dst := tw.w.Header()
+	for k, v := range tw.header {
+		dst[k] = v
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	tw.w.WriteHeader(tw.code)
+	tw.w.Write(tw.buf.Bytes())
+	tw.buf.Reset()
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"doc-analysis-backend/config"
+)
+
+func setTimeoutConfig(t *testing.T, timeout time.Duration) {
+	t.Helper()
+	old := config.AppConfig
+	config.AppConfig = &config.Config{}
+	config.AppConfig.Server.RequestTimeout = timeout
+	t.Cleanup(func() { config.AppConfig = old })
+}
+
+func TestRequestTimeoutBuffersUntilDone(t *testing.T) {
+	setTimeoutConfig(t, time.Second)
+
+	handler := RequestTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("ok"))
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/files", nil))
+
+	if rec.Code != http.StatusCreated || rec.Body.String() != "ok" || rec.Header().Get("X-Test") != "1" {
+		t.Fatalf("unexpected response: %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+}
+
+func TestRequestTimeoutReturns504WithoutFlush(t *testing.T) {
+	setTimeoutConfig(t, 20*time.Millisecond)
+
+	release := make(chan struct{})
+	defer close(release)
+	handler := RequestTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		<-release
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/files", nil))
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("code = %d, want 504", rec.Code)
+	}
+}
+
+// Flush 之后的内容直接写出，处理函数结束前客户端已经能收到
+func TestRequestTimeoutWritesThroughAfterFlush(t *testing.T) {
+	setTimeoutConfig(t, time.Second)
+
+	rec := httptest.NewRecorder()
+	flushed := make(chan struct{})
+	proceed := make(chan struct{})
+	handler := RequestTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first,"))
+		w.(http.Flusher).Flush()
+		close(flushed)
+		<-proceed
+		w.Write([]byte("second"))
+	}))
+
+	served := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/admin/export", nil))
+		close(served)
+	}()
+
+	<-flushed
+	if got := rec.Body.String(); got != "first," {
+		t.Fatalf("body after flush = %q, want %q", got, "first,")
+	}
+	close(proceed)
+	<-served
+	if got := rec.Body.String(); got != "first,second" {
+		t.Fatalf("body = %q", got)
+	}
+}
+
+// 已开始写出的响应超时后不能再改为 504，等待处理函数结束
+func TestRequestTimeoutAfterFlushKeepsResponse(t *testing.T) {
+	setTimeoutConfig(t, 20*time.Millisecond)
+
+	handler := RequestTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first,"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		w.Write([]byte("last"))
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/admin/export", nil))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "first,last" {
+		t.Fatalf("unexpected response: %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/metrics"
+	"doc-analysis-backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UploadLimiter 限制同时处理的上传请求数量，避免大量大文件同时上传占满磁盘 I/O 和内存。
+// 超出上限的请求最多排队等待 UPLOAD_QUEUE_TIMEOUT，仍未获得名额则返回 429。
+func UploadLimiter() gin.HandlerFunc {
+	cfg := config.AppConfig.Upload
+	if cfg.MaxConcurrent <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	slots := make(chan struct{}, cfg.MaxConcurrent)
+
+	return func(c *gin.Context) {
+		if !acquireSlot(c, slots, cfg.QueueTimeout) {
+			metrics.UploadsRejected.Inc()
+			c.Header("Retry-After", strconv.Itoa(int(cfg.QueueTimeout.Seconds())+1))
+			utils.Error(c, http.StatusTooManyRequests, "当前上传请求过多，请稍后重试")
+			c.Abort()
+			return
+		}
+
+		metrics.UploadsInFlight.Inc()
+		defer func() {
+			metrics.UploadsInFlight.Dec()
+			<-slots
+		}()
+
+		c.Next()
+	}
+}
+
+func acquireSlot(c *gin.Context, slots chan struct{}, wait time.Duration) bool {
+	select {
+	case slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if wait <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-c.Request.Context().Done():
+		return false
+	}
+}
@@ -14,22 +14,65 @@ type FileRecord struct {
 	FileSize int64     `gorm:"default:0" json:"file_size"`
 	MimeType string    `gorm:"size:100" json:"mime_type"`
 	
+	// 从 PDF 文档信息中读取的标题、作者和创建时间，缺失或无法解码时为空
+	DocumentTitle     string     `gorm:"size:255" json:"document_title,omitempty"`
+	DocumentAuthor    string     `gorm:"size:255;index" json:"document_author,omitempty"`
+	DocumentCreatedAt *time.Time `json:"document_created_at,omitempty"`
+	
+	// 从 ZIP 压缩包中解压出的文件指向所属压缩包的记录
+	ParentID *uuid.UUID `gorm:"type:uuid;index" json:"parent_id,omitempty"`
+	
+	// 所属租户，由上传时使用的 API 密钥决定（TENANT_API_KEYS），未启用多租户时为空
+	TenantID string `gorm:"size:100;index" json:"tenant_id,omitempty"`
+	
 	// 处理状态
-	Status   string `gorm:"default:pending;size:50" json:"status"`
+	Status   string `gorm:"default:pending;size:50;index" json:"status"`
 	Progress int    `gorm:"default:0" json:"progress"`
 	Message  string `gorm:"type:text;default:'等待处理中...'" json:"message"`
+	// 暂停处理: 不会被批量处理、一致性修复选中，失败后也不再自动重试；手动触发处理不受影响
+	Hold bool `gorm:"default:false" json:"hold"`
+	
+	// 单个文件的分块配置，为空时使用全局配置
+	ChunkSize     *int    `json:"chunk_size,omitempty"`
+	ChunkOverlap  *int    `json:"chunk_overlap,omitempty"`
+	ChunkStrategy *string `gorm:"size:20" json:"chunk_strategy,omitempty"`
+	
+	// 上传时附加的自定义元数据，会写入每个块的向量元数据，可用于检索过滤
+	Metadata map[string]interface{} `gorm:"serializer:json;type:text" json:"metadata,omitempty"`
+	
+	// 向量所在的 Chroma 集合，为空表示默认集合 CHROMA_COLLECTION
+	Collection string `gorm:"size:100" json:"collection,omitempty"`
+	// 开启集合分片（CHROMA_SHARD_MAX_VECTORS）时向量所在的分片，0 为集合本身，N 为集合名加 _N 后缀的分片集合
+	Shard int `gorm:"default:0" json:"shard"`
+	// 最近一次处理时的向量存储方式: chroma 正常写入 / noop 跳过存储 / temp 写入临时集合，后两者用于压测
+	StoreMode string `gorm:"size:20" json:"store_mode,omitempty"`
 	
 	// 处理结果
 	TotalPages        int     `gorm:"default:0" json:"total_pages"`
 	ChunksCount       int     `gorm:"default:0" json:"chunks_count"`
+	OriginalChunks    int     `gorm:"default:0" json:"original_chunks"` // 分块得到的块数量，超过 MAX_CHUNKS 被截断时大于 chunks_count
+	DedupedChunks     int     `gorm:"default:0" json:"deduped_chunks"` // 文档内去重移除的块数量
+	TableExtraction   bool    `gorm:"default:false" json:"table_extraction"` // 解析时是否进行了表格识别
+	TablesCount       int     `gorm:"default:0" json:"tables_count"`
+	ExtractionMode    string  `gorm:"size:20" json:"extraction_mode,omitempty"` // 文本提取方式: simple / columns
+	ExtractedChars    int     `gorm:"default:0" json:"extracted_chars"` // 提取到的非空白字符数
+	StrippedLines     int     `gorm:"default:0" json:"stripped_lines"` // 识别为页眉页脚并删除的行数
+	EmbeddedChunks    int     `gorm:"default:0" json:"embedded_chunks"` // 已写入向量库的块数量
+	StoredVectors     int     `gorm:"default:0" json:"stored_vectors"` // 写入后在向量库中核对到的该文件的向量数量
+	// 部分完成（partial）时向量化失败的块，可通过 retry-failed 只重试这些块
+	FailedChunks []FailedChunk `gorm:"serializer:json;type:text" json:"failed_chunks,omitempty"`
 	ProcessingDuration *float64 `json:"processing_duration,omitempty"`
 	
+	// 原始文件已按保留策略清理，记录和向量仍保留（除非配置了同时删除向量）
+	FilePurged bool       `gorm:"default:false" json:"file_purged"`
+	PurgedAt   *time.Time `json:"purged_at,omitempty"`
+	
 	// 错误信息
 	ErrorCount int    `gorm:"default:0" json:"error_count"`
 	LastError  string `gorm:"type:text" json:"last_error,omitempty"`
 	
 	// 时间戳
-	CreatedAt time.Time `json:"created_at"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
@@ -42,7 +85,7 @@ func (f *FileRecord) BeforeCreate(tx *gorm.DB) error {
 
 type ProcessingLog struct {
 	ID       uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	FileID   uuid.UUID `gorm:"type:uuid;not null" json:"file_id"`
+	FileID   uuid.UUID `gorm:"type:uuid;not null;index" json:"file_id"`
 	Stage    string    `gorm:"not null;size:50" json:"stage"`    // parsing, chunking, embedding, storing
 	Status   string    `gorm:"not null;size:50" json:"status"`   // started, completed, failed
 	Message  string    `gorm:"type:text" json:"message,omitempty"`
@@ -57,6 +100,40 @@ func (p *ProcessingLog) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// 文件的一次处理记录，每个处理任务（包括每次重试）结束时写入一条，用于对比多次处理的配置和结果
+type ProcessingRun struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	FileID    uuid.UUID `gorm:"type:uuid;not null;index" json:"file_id"`
+	RunNumber int       `gorm:"not null" json:"run_number"` // 该文件的第几次处理，从 1 开始
+	Type      string    `gorm:"size:20" json:"type"`        // process, reembed, retry_failed, archive
+	Status    string    `gorm:"size:50" json:"status"`      // 处理结束时文件的状态
+	
+	// 处理时使用的配置
+	ChunkSize          int    `json:"chunk_size"`
+	ChunkOverlap       int    `json:"chunk_overlap"`
+	ChunkStrategy      string `gorm:"size:20" json:"chunk_strategy"`
+	EmbeddingModel     string `gorm:"size:200" json:"embedding_model"`
+	EmbeddingBatchSize int    `json:"embedding_batch_size"`
+	StoreMode          string `gorm:"size:20" json:"store_mode,omitempty"`
+	
+	// 处理结果
+	ChunksCount    int     `json:"chunks_count"`
+	EmbeddedChunks int     `json:"embedded_chunks"`
+	FailedChunks   int     `json:"failed_chunks"`
+	Duration       float64 `json:"duration"` // 耗时（秒）
+	Error          string  `gorm:"type:text" json:"error,omitempty"`
+	
+	StartedAt time.Time `json:"started_at"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+func (r *ProcessingRun) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
 // 任务状态
 type TaskStatus string
 
@@ -70,14 +147,46 @@ const (
 
 type Task struct {
 	ID         string     `gorm:"primary_key;size:100" json:"id"`
-	FileID     uuid.UUID  `gorm:"type:uuid;not null" json:"file_id"`
+	FileID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"file_id"`
 	Type       string     `gorm:"not null;size:50" json:"type"`
+	Queue      string     `gorm:"size:50" json:"queue,omitempty"`
 	Status     TaskStatus `gorm:"default:pending;size:20" json:"status"`
 	Payload    string     `gorm:"type:text" json:"payload,omitempty"`
 	ErrorMsg   string     `gorm:"type:text" json:"error_msg,omitempty"`
 	RetryCount int        `gorm:"default:0" json:"retry_count"`
 	
+	// 从入队到首次开始执行的等待时间（秒），重试的退避等待不计入
+	QueueWaitSeconds *float64 `json:"queue_wait_seconds,omitempty"`
+	
 	CreatedAt time.Time  `json:"created_at"`
 	StartedAt *time.Time `json:"started_at,omitempty"`
 	EndedAt   *time.Time `json:"ended_at,omitempty"`
-}
\ No newline at end of file
+}
+
+// 向量化失败的块及失败原因
+type FailedChunk struct {
+	ChunkIndex int    `json:"chunk_index"`
+	Error      string `json:"error"`
+}
+
+// 文档块文本，ID 与向量数据库中的记录一致，用于关键词检索
+type DocumentChunk struct {
+	ID         string    `gorm:"primaryKey;size:100" json:"id"`
+	FileID     uuid.UUID `gorm:"type:uuid;not null;index" json:"file_id"`
+	ChunkIndex int       `gorm:"not null" json:"chunk_index"`
+	PageNumber int       `json:"page_number"`
+	Content    string    `gorm:"type:text" json:"content"`
+	// 生成该块向量的模型，向量化失败的块为空
+	EmbeddingModel string    `gorm:"size:200;index" json:"embedding_model,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// 运行时可调整的处理配置（单行表，ID 固定为 1）
+type ProcessingSettings struct {
+	ID                 uint      `gorm:"primaryKey" json:"-"`
+	ChunkSize          int       `json:"chunk_size"`
+	ChunkOverlap       int       `json:"chunk_overlap"`
+	EmbeddingBatchSize int       `json:"embedding_batch_size"`
+	MaxPages           int       `json:"max_pages"` // 0 表示不限制
+	UpdatedAt          time.Time `json:"updated_at"`
+}
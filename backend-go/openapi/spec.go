@@ -0,0 +1,257 @@
+package openapi
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Param 路径或查询参数
+type Param struct {
+	Name        string
+	In          string // path / query
+	Type        string // string / integer / boolean
+	Description string
+	Required    bool
+}
+
+// Operation 单个接口的文档描述
+type Operation struct {
+	Summary     string
+	Description string
+	Tag         string
+	Params      []Param
+	// 请求体，RequestSchema 为 JSON 请求体，FormFields 为 multipart 表单字段
+	RequestSchema map[string]interface{}
+	FormFields    map[string]interface{}
+	// 成功响应中 data 字段的结构，Raw 为 true 时响应不使用统一的 Response 包装
+	ResponseSchema map[string]interface{}
+	Raw            bool
+	Admin          bool
+}
+
+var (
+	mu         sync.RWMutex
+	operations = map[string]Operation{}
+)
+
+// Register 登记接口文档，key 与 gin 路由一致，例如 Register("GET", "/api/files/:id/status", ...)
+func Register(method, path string, op Operation) {
+	mu.Lock()
+	defer mu.Unlock()
+	operations[method+" "+path] = op
+}
+
+// Build 根据 gin 实际注册的路由生成 OpenAPI 3 文档，未登记文档的路由也会列出，保证与代码一致
+func Build(routes gin.RoutesInfo) map[string]interface{} {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	paths := map[string]interface{}{}
+	for _, route := range routes {
+		// CORS 预检路由不需要出现在文档中
+		if route.Method == http.MethodOptions {
+			continue
+		}
+
+		path, pathParams := convertPath(route.Path)
+		item, ok := paths[path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[path] = item
+		}
+
+		op, documented := operations[route.Method+" "+route.Path]
+		if !documented {
+			op = Operation{Summary: route.Method + " " + route.Path}
+		}
+		item[strings.ToLower(route.Method)] = buildOperation(op, pathParams)
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "文档分析后端 API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"Response": responseEnvelope(map[string]interface{}{}),
+			},
+			"securitySchemes": map[string]interface{}{
+				"ApiKeyAuth": map[string]interface{}{
+					"type": "apiKey",
+					"in":   "header",
+					"name": "X-API-Key",
+				},
+			},
+		},
+	}
+}
+
+func buildOperation(op Operation, pathParams []string) map[string]interface{} {
+	result := map[string]interface{}{
+		"summary": op.Summary,
+	}
+	if op.Description != "" {
+		result["description"] = op.Description
+	}
+	if op.Tag != "" {
+		result["tags"] = []string{op.Tag}
+	}
+	if op.Admin {
+		result["security"] = []map[string][]string{{"ApiKeyAuth": {}}}
+	}
+
+	// 路径参数从路由中提取，已登记的描述优先
+	documented := map[string]bool{}
+	var params []map[string]interface{}
+	for _, p := range op.Params {
+		documented[p.In+":"+p.Name] = true
+		params = append(params, buildParam(p))
+	}
+	for _, name := range pathParams {
+		if !documented["path:"+name] {
+			params = append(params, buildParam(Param{Name: name, In: "path", Type: "string"}))
+		}
+	}
+	if len(params) > 0 {
+		result["parameters"] = params
+	}
+
+	switch {
+	case op.RequestSchema != nil:
+		result["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": op.RequestSchema},
+			},
+		}
+	case op.FormFields != nil:
+		result["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"multipart/form-data": map[string]interface{}{
+					"schema": map[string]interface{}{"type": "object", "properties": op.FormFields},
+				},
+			},
+		}
+	}
+
+	data := op.ResponseSchema
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	schema := data
+	if !op.Raw {
+		schema = responseEnvelope(data)
+	}
+	result["responses"] = map[string]interface{}{
+		"200": map[string]interface{}{
+			"description": "成功",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": schema},
+			},
+		},
+		"default": map[string]interface{}{
+			"description": "错误，code 与 HTTP 状态码一致",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": map[string]interface{}{"$ref": "#/components/schemas/Response"},
+				},
+			},
+		},
+	}
+	return result
+}
+
+func buildParam(p Param) map[string]interface{} {
+	paramType := p.Type
+	if paramType == "" {
+		paramType = "string"
+	}
+	param := map[string]interface{}{
+		"name":     p.Name,
+		"in":       p.In,
+		"required": p.Required || p.In == "path",
+		"schema":   map[string]interface{}{"type": paramType},
+	}
+	if p.Description != "" {
+		param["description"] = p.Description
+	}
+	return param
+}
+
+// responseEnvelope 统一响应结构 utils.Response
+func responseEnvelope(data map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"code":    map[string]interface{}{"type": "integer"},
+			"message": map[string]interface{}{"type": "string"},
+			"data":    data,
+		},
+	}
+}
+
+// convertPath 将 gin 路径参数 :id 转换为 OpenAPI 的 {id}，同时返回参数名
+func convertPath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			name := segment[1:]
+			params = append(params, name)
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// Handler 返回 OpenAPI 文档，首次请求时根据已注册的路由生成
+func Handler(engine *gin.Engine) gin.HandlerFunc {
+	var once sync.Once
+	var spec map[string]interface{}
+	return func(c *gin.Context) {
+		once.Do(func() {
+			spec = Build(engine.Routes())
+		})
+		c.JSON(http.StatusOK, spec)
+	}
+}
+
+// DocsHandler 提供 Swagger UI 页面
+func DocsHandler(specURL string) gin.HandlerFunc {
+	page := strings.ReplaceAll(swaggerUIPage, "{{SPEC_URL}}", specURL)
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+	}
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>API 文档</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "{{SPEC_URL}}", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
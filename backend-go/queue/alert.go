@@ -0,0 +1,60 @@
+package queue
+
+import (
+	"log"
+	"time"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/models"
+	"doc-analysis-backend/services"
+)
+
+// alertOnErrorCount 文件的失败次数恰好达到 ALERT_ERROR_COUNT_THRESHOLD 时告警，之后继续失败不重复告警
+func alertOnErrorCount(fileID string) {
+	threshold := config.AppConfig.Alert.ErrorCountThreshold
+	if threshold <= 0 || !services.AlertsEnabled() {
+		return
+	}
+
+	var file models.FileRecord
+	if err := database.GetDB().Select("filename", "error_count", "last_error").Where("id = ?", fileID).First(&file).Error; err != nil {
+		return
+	}
+	if file.ErrorCount == threshold {
+		services.SendAlert("文件 %s (%s) 已处理失败 %d 次，最近一次错误: %s", file.Filename, fileID, file.ErrorCount, file.LastError)
+	}
+}
+
+// StartFailedTaskMonitor 在后台定期统计所有队列中重试耗尽（已归档）的任务数量，
+// 超过 ALERT_FAILED_TASKS_THRESHOLD 时告警，回落到阈值以下后再次超过才会重新告警
+func StartFailedTaskMonitor() {
+	cfg := config.AppConfig.Alert
+	if cfg.FailedTasksThreshold <= 0 || !services.AlertsEnabled() || Inspector == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(cfg.BatchInterval)
+		defer ticker.Stop()
+		alerted := false
+		for range ticker.C {
+			queues, err := Inspector.Queues()
+			if err != nil {
+				log.Printf("统计失败任务数量失败: %v", err)
+				continue
+			}
+			archived := 0
+			for _, name := range queues {
+				if info, err := Inspector.GetQueueInfo(name); err == nil {
+					archived += info.Archived
+				}
+			}
+
+			if archived > cfg.FailedTasksThreshold && !alerted {
+				services.SendAlert("重试耗尽的失败任务已有 %d 个，超过阈值 %d", archived, cfg.FailedTasksThreshold)
+			}
+			alerted = archived > cfg.FailedTasksThreshold
+		}
+	}()
+}
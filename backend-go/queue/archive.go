@@ -0,0 +1,207 @@
+package queue
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/models"
+	"doc-analysis-backend/services"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+)
+
+var errEntryTooLarge = errors.New("解压后的大小超过限制")
+
+// IsArchiveFile 判断文件是否为需要解压处理的 ZIP 压缩包
+func IsArchiveFile(filename string) bool {
+	return strings.EqualFold(filepath.Ext(filename), ".zip")
+}
+
+// processArchive 解压 ZIP 压缩包，为其中每个支持的文件创建子记录并加入处理队列。
+// 单个条目失败不影响其他条目，结果逐条写入处理日志；压缩包整体超过限制时直接拒绝。
+func processArchive(ctx context.Context, fileID string) error {
+	cfg := config.AppConfig.Upload
+	db := database.GetDB()
+	p := &pipeline{fileID: fileID, stage: "extracting"}
+
+	var archive models.FileRecord
+	if err := db.Where("id = ?", fileID).First(&archive).Error; err != nil {
+		return p.fail("获取文件记录失败", err)
+	}
+
+	p.begin("extracting", 10, "解压中...")
+	reader, err := zip.OpenReader(archive.Filepath)
+	if err != nil {
+		return p.fail("打开压缩包失败", fmt.Errorf("%v: %w", err, asynq.SkipRetry))
+	}
+	defer reader.Close()
+
+	if err := checkArchiveLimits(reader.File); err != nil {
+		return p.fail("压缩包校验失败", fmt.Errorf("%v: %w", err, asynq.SkipRetry))
+	}
+
+	// 实际解压的字节数，防止条目头中声明的大小与实际内容不符
+	remaining := cfg.ArchiveMaxUncompressed
+	extracted, failed := 0, 0
+	for _, entry := range reader.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+
+		name := services.SanitizeFilename(entry.Name)
+		child, err := extractArchiveEntry(&archive, entry, &remaining)
+		if err != nil {
+			failed++
+			writeProcessingLog(fileID, "extracting", "failed", fmt.Sprintf("%s: %v", entry.Name, err), nil)
+			if errors.Is(err, errEntryTooLarge) && remaining <= 0 {
+				// 总量已耗尽，剩余条目无需再尝试
+				break
+			}
+			continue
+		}
+
+		if _, err := EnqueueProcessDocument(child.ID.String()); err != nil {
+			failed++
+			writeProcessingLog(fileID, "extracting", "failed", fmt.Sprintf("%s: %v", entry.Name, err), nil)
+			continue
+		}
+
+		extracted++
+		writeProcessingLog(fileID, "extracting", "completed", fmt.Sprintf("%s: 已创建文件 %s 并加入处理队列", name, child.ID), nil)
+	}
+
+	log.Printf("压缩包 %s 解压完成: 成功 %d 个, 失败 %d 个", fileID, extracted, failed)
+	p.complete(fmt.Sprintf("解压完成，成功 %d 个，失败 %d 个", extracted, failed))
+	return nil
+}
+
+// ValidateArchive 检查压缩包能否打开以及是否超过限制，返回其中支持处理的文件数量，不解压任何内容
+func ValidateArchive(archivePath string) (int, error) {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return 0, fmt.Errorf("打开压缩包失败: %w", err)
+	}
+	defer reader.Close()
+
+	if err := checkArchiveLimits(reader.File); err != nil {
+		return 0, err
+	}
+
+	cfg := config.AppConfig.Upload
+	supported := 0
+	for _, entry := range reader.File {
+		name := services.SanitizeFilename(entry.Name)
+		if !entry.FileInfo().IsDir() && !IsArchiveFile(name) && services.IsAllowedFileType(name, cfg.AllowExt) && entry.UncompressedSize64 <= uint64(cfg.MaxSize) {
+			supported++
+		}
+	}
+	return supported, nil
+}
+
+// checkArchiveLimits 按条目头中声明的大小检查条目数、解压总大小和压缩比
+func checkArchiveLimits(entries []*zip.File) error {
+	cfg := config.AppConfig.Upload
+
+	if len(entries) > cfg.ArchiveMaxEntries {
+		return fmt.Errorf("压缩包条目数 %d 超过上限 %d", len(entries), cfg.ArchiveMaxEntries)
+	}
+
+	var total uint64
+	for _, entry := range entries {
+		total += entry.UncompressedSize64
+		if total > uint64(cfg.ArchiveMaxUncompressed) {
+			return fmt.Errorf("解压后总大小超过上限 %d 字节", cfg.ArchiveMaxUncompressed)
+		}
+		if entry.CompressedSize64 > 0 && entry.UncompressedSize64/entry.CompressedSize64 > uint64(cfg.ArchiveMaxRatio) {
+			return fmt.Errorf("条目 %s 的压缩比超过上限 %d", entry.Name, cfg.ArchiveMaxRatio)
+		}
+	}
+	return nil
+}
+
+// extractArchiveEntry 解压单个条目到上传目录并创建子文件记录。
+// 只使用条目的文件名而不使用其路径，避免写出上传目录。
+func extractArchiveEntry(archive *models.FileRecord, entry *zip.File, remaining *int64) (*models.FileRecord, error) {
+	cfg := config.AppConfig.Upload
+
+	name := services.SanitizeFilename(entry.Name)
+	ext := strings.ToLower(filepath.Ext(name))
+	if IsArchiveFile(name) || !services.IsAllowedFileType(name, cfg.AllowExt) {
+		return nil, fmt.Errorf("不支持的文件类型")
+	}
+	if entry.UncompressedSize64 > uint64(cfg.MaxSize) {
+		return nil, fmt.Errorf("文件过大")
+	}
+
+	src, err := entry.Open()
+	if err != nil {
+		return nil, fmt.Errorf("读取条目失败: %w", err)
+	}
+	defer src.Close()
+
+	// 先解压到临时目录，完整写入后再移动到上传目录
+	out, err := services.CreateTempFile("extract-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(out.Name())
+
+	limit := cfg.MaxSize
+	if *remaining < limit {
+		limit = *remaining
+	}
+	written, err := io.Copy(out, io.LimitReader(src, limit+1))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	*remaining -= written
+	if err == nil && written > limit {
+		err = errEntryTooLarge
+	}
+	if err != nil {
+		if errors.Is(err, errEntryTooLarge) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("解压失败: %w", err)
+	}
+
+	childID := uuid.New()
+	childPath := filepath.Join(cfg.Dir, childID.String()+ext)
+	if err := services.MoveFile(out.Name(), childPath); err != nil {
+		return nil, fmt.Errorf("保存文件失败: %w", err)
+	}
+
+	child := &models.FileRecord{
+		ID:       childID,
+		ParentID: &archive.ID,
+		TenantID: archive.TenantID,
+		Filename: name,
+		Filepath: childPath,
+		FileSize: written,
+		Status:   "pending",
+		Progress: 0,
+		Message:  "等待处理中...",
+
+		ChunkSize:     archive.ChunkSize,
+		ChunkOverlap:  archive.ChunkOverlap,
+		ChunkStrategy: archive.ChunkStrategy,
+		Metadata:      archive.Metadata,
+		Collection:    archive.Collection,
+	}
+	if err := database.GetDB().Create(child).Error; err != nil {
+		os.Remove(childPath)
+		return nil, fmt.Errorf("创建文件记录失败: %w", err)
+	}
+
+	return child, nil
+}
@@ -0,0 +1,55 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/models"
+	"doc-analysis-backend/services"
+
+	"gorm.io/gorm"
+)
+
+// DeleteFile 删除文件的向量、原始文件、页面图片，以及数据库中的文件记录、处理日志、任务和文档块。
+// 最多等待 wait 让正在进行的处理释放文件锁，避免任务继续写入正在删除的记录和向量，超时返回 ErrFileLocked
+func DeleteFile(ctx context.Context, file *models.FileRecord, wait time.Duration) error {
+	fileID := file.ID.String()
+	lock, err := AcquireFileLock(ctx, fileID, wait)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	// 先删除向量，失败时记录保持不变，可以重试
+	if err := services.NewChromaClient().DeleteDocumentsByFileID(ctx, services.ShardCollection(file.Collection, file.Shard), fileID); err != nil {
+		return fmt.Errorf("删除向量数据失败: %w", err)
+	}
+	services.InvalidateSearchCache(fileID)
+
+	if _, err := os.Stat(file.Filepath); err == nil {
+		os.Remove(file.Filepath)
+	}
+	services.RemovePageImages(fileID)
+
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("file_id = ?", fileID).Delete(&models.ProcessingLog{}).Error; err != nil {
+			return fmt.Errorf("删除处理日志失败: %w", err)
+		}
+		if err := tx.Where("file_id = ?", fileID).Delete(&models.ProcessingRun{}).Error; err != nil {
+			return fmt.Errorf("删除处理记录失败: %w", err)
+		}
+		if err := tx.Where("file_id = ?", fileID).Delete(&models.Task{}).Error; err != nil {
+			return fmt.Errorf("删除任务记录失败: %w", err)
+		}
+		if err := tx.Where("file_id = ?", fileID).Delete(&models.DocumentChunk{}).Error; err != nil {
+			return fmt.Errorf("删除文档块失败: %w", err)
+		}
+		if err := tx.Delete(file).Error; err != nil {
+			return fmt.Errorf("删除文件记录失败: %w", err)
+		}
+		return nil
+	})
+}
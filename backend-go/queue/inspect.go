@@ -0,0 +1,135 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"doc-analysis-backend/config"
+
+	"github.com/hibiken/asynq"
+)
+
+// 计算排队位置时最多扫描的等待任务数量
+const queuePositionScanLimit = 1000
+
+var Inspector *asynq.Inspector
+
+// LiveTaskState 任务在 asynq 中的实时状态，数据库中的任务记录只在开始、结束时更新
+type LiveTaskState struct {
+	State    string `json:"state"` // pending / active / scheduled / retry / archived / completed
+	Queue    string `json:"queue"`
+	Retried  int    `json:"retried"`
+	MaxRetry int    `json:"max_retry"`
+	LastErr  string `json:"last_err,omitempty"`
+	// 等待中的任务在队列中的位置（从 1 开始），超出扫描范围时为空
+	QueuePosition *int       `json:"queue_position,omitempty"`
+	NextProcessAt *time.Time `json:"next_process_at,omitempty"`
+	LastFailedAt  *time.Time `json:"last_failed_at,omitempty"`
+}
+
+// InspectTask 读取任务在 asynq 中的实时状态。任务已被 asynq 清除（完成后不保留）时返回 asynq.ErrTaskNotFound
+func InspectTask(queueName, taskID string) (*LiveTaskState, error) {
+	if Inspector == nil {
+		return nil, errors.New("任务队列未初始化")
+	}
+	if queueName == "" {
+		queueName = "default"
+	}
+
+	info, err := Inspector.GetTaskInfo(queueName, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &LiveTaskState{
+		State:    info.State.String(),
+		Queue:    info.Queue,
+		Retried:  info.Retried,
+		MaxRetry: info.MaxRetry,
+		LastErr:  info.LastErr,
+	}
+	if !info.NextProcessAt.IsZero() {
+		state.NextProcessAt = &info.NextProcessAt
+	}
+	if !info.LastFailedAt.IsZero() {
+		state.LastFailedAt = &info.LastFailedAt
+	}
+	if info.State == asynq.TaskStatePending {
+		position, err := pendingPosition(queueName, taskID)
+		if err != nil {
+			return nil, err
+		}
+		state.QueuePosition = position
+	}
+	return state, nil
+}
+
+// pendingPosition 按处理顺序扫描等待中的任务，返回任务的位置
+func pendingPosition(queueName, taskID string) (*int, error) {
+	const pageSize = 200
+	for page := 1; (page-1)*pageSize < queuePositionScanLimit; page++ {
+		tasks, err := Inspector.ListPendingTasks(queueName, asynq.PageSize(pageSize), asynq.Page(page))
+		if err != nil {
+			return nil, fmt.Errorf("读取等待中的任务失败: %w", err)
+		}
+		for i, task := range tasks {
+			if task.ID == taskID {
+				position := (page-1)*pageSize + i + 1
+				return &position, nil
+			}
+		}
+		if len(tasks) < pageSize {
+			break
+		}
+	}
+	return nil, nil
+}
+
+// QueueDepth 等待执行的任务总数，包括所有队列中等待、定时和等待重试的任务，不包括正在执行的任务
+type QueueDepth struct {
+	Depth     int `json:"depth"`
+	Threshold int `json:"threshold"` // 0 表示不限制
+	// 等待执行的任务数超过阈值，客户端应暂缓提交新的处理任务
+	Backpressure bool `json:"backpressure"`
+}
+
+// InspectQueueDepth 统计所有队列中等待执行的任务数，并与 QUEUE_BACKPRESSURE_THRESHOLD 比较
+func InspectQueueDepth() (*QueueDepth, error) {
+	if Inspector == nil {
+		return nil, errors.New("任务队列未初始化")
+	}
+	queues, err := Inspector.Queues()
+	if err != nil {
+		return nil, fmt.Errorf("读取队列列表失败: %w", err)
+	}
+
+	result := &QueueDepth{Threshold: config.AppConfig.Queue.BackpressureThreshold}
+	for _, name := range queues {
+		info, err := Inspector.GetQueueInfo(name)
+		if err != nil {
+			return nil, fmt.Errorf("读取队列 %s 失败: %w", name, err)
+		}
+		result.Depth += info.Pending + info.Scheduled + info.Retry
+	}
+	result.Backpressure = result.Threshold > 0 && result.Depth > result.Threshold
+	return result, nil
+}
+
+// CheckBackpressure 判断是否应暂缓提交新的处理任务。未配置阈值时不查询 Redis；
+// 读取队列状态失败时只记录日志并放行，不因检查失败阻止处理
+func CheckBackpressure() *QueueDepth {
+	if config.AppConfig.Queue.BackpressureThreshold <= 0 {
+		return nil
+	}
+	depth, err := InspectQueueDepth()
+	if err != nil {
+		log.Printf("检查任务队列积压失败: %v", err)
+		return nil
+	}
+	if !depth.Backpressure {
+		return nil
+	}
+	return depth
+}
@@ -0,0 +1,108 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"doc-analysis-backend/config"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrFileLocked 文件正被其他操作持有锁
+var ErrFileLocked = errors.New("文件正在被其他操作占用")
+
+// 仅当锁仍由自己持有时才释放/续期，避免误删其他持有者的锁
+var (
+	releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+	refreshScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+var lockClient *redis.Client
+
+// FileLock 基于 Redis 的文件级互斥锁，用于防止处理任务与删除等操作交错执行。
+//
+// 锁的生命周期: 持有期间后台每 TTL/3 续期一次，Release 时停止续期并删除锁；
+// 如果持有者进程崩溃，锁会在 TTL 到期后自动失效，不会永久阻塞该文件。
+type FileLock struct {
+	key    string
+	token  string
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// AcquireFileLock 获取文件锁，锁被占用时最多等待 wait，超时返回 ErrFileLocked
+func AcquireFileLock(ctx context.Context, fileID string, wait time.Duration) (*FileLock, error) {
+	ttl := config.AppConfig.Lock.TTL
+	lock := &FileLock{
+		key:   "doc-analysis:file-lock:" + fileID,
+		token: uuid.NewString(),
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		ok, err := lockClient.SetNX(ctx, lock.key, lock.token, ttl).Result()
+		if err != nil {
+			return nil, fmt.Errorf("获取文件锁失败: %w", err)
+		}
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrFileLocked
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+
+	lock.stopCh = make(chan struct{})
+	lock.doneCh = make(chan struct{})
+	go lock.keepAlive(ttl)
+
+	return lock, nil
+}
+
+func (l *FileLock) keepAlive(ttl time.Duration) {
+	defer close(l.doneCh)
+
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stopCh:
+			return
+		case <-ticker.C:
+			if err := refreshScript.Run(context.Background(), lockClient, []string{l.key}, l.token, ttl.Milliseconds()).Err(); err != nil {
+				log.Printf("文件锁续期失败 %s: %v", l.key, err)
+			}
+		}
+	}
+}
+
+// Release 释放文件锁
+func (l *FileLock) Release() {
+	close(l.stopCh)
+	<-l.doneCh
+
+	if err := releaseScript.Run(context.Background(), lockClient, []string{l.key}, l.token).Err(); err != nil {
+		log.Printf("释放文件锁失败 %s: %v", l.key, err)
+	}
+}
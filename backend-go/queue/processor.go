@@ -0,0 +1,657 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/metrics"
+	"doc-analysis-backend/models"
+	"doc-analysis-backend/services"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"gorm.io/gorm"
+)
+
+// StageError 记录失败发生在流水线的哪个阶段
+type StageError struct {
+	Stage string
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// pipeline 跟踪当前所处的处理阶段，并将各阶段的开始/完成写入 ProcessingLog
+type pipeline struct {
+	fileID     string
+	stage      string
+	stageStart time.Time
+}
+
+// begin 进入新的处理阶段，同时更新文件状态
+func (p *pipeline) begin(stage string, progress int, message string) {
+	p.stage = stage
+	p.stageStart = time.Now()
+	updateFileStage(p.fileID, stage, progress, message)
+	writeProcessingLog(p.fileID, stage, "started", message, nil)
+}
+
+// complete 标记当前阶段完成并记录耗时
+func (p *pipeline) complete(message string) {
+	duration := time.Since(p.stageStart).Seconds()
+	writeProcessingLog(p.fileID, p.stage, "completed", message, &duration)
+}
+
+// fail 将错误包装为带阶段信息的 StageError
+func (p *pipeline) fail(format string, err error) error {
+	return &StageError{Stage: p.stage, Err: fmt.Errorf(format+": %w", err)}
+}
+
+// 文档中没有可提取的文本，EMPTY_TEXT_ACTION=completed_empty 时 processDocument 直接返回该错误，
+// 由任务处理函数将文件标记为 completed_empty
+var errEmptyDocument = errors.New("文档中没有可提取的文本")
+
+// 部分块向量化失败，成功的块已写入向量库，由任务处理函数将文件标记为 partial
+var errPartialDocument = errors.New("部分块向量化失败")
+
+// processDocument 文档处理流水线: 解析 -> 分块 -> (向量化 -> 存储)
+func processDocument(ctx context.Context, fileID string) error {
+	db := database.GetDB()
+	p := &pipeline{fileID: fileID, stage: "processing"}
+
+	// 每个任务开始时读取最新的运行时配置
+	settings, err := database.GetProcessingSettings()
+	if err != nil {
+		return p.fail("读取处理配置失败", err)
+	}
+
+	var file models.FileRecord
+	if err := db.Where("id = ?", fileID).First(&file).Error; err != nil {
+		return p.fail("获取文件记录失败", err)
+	}
+	if err := resolveStoreMode(ctx, &file); err != nil {
+		return p.fail("更新文件记录失败", err)
+	}
+
+	// 阶段1: 解析PDF
+	p.begin("parsing", 10, "PDF解析中...")
+	doc, err := parseDocument(&file, settings)
+	if err != nil {
+		return p.fail("文档解析失败", err)
+	}
+	extractTables := config.AppConfig.Processing.ExtractTables
+	extractedChars := doc.TextLength()
+	parsed := map[string]interface{}{
+		"total_pages":      doc.TotalPages,
+		"extraction_mode":  config.AppConfig.Processing.LayoutMode,
+		"table_extraction": extractTables,
+		"tables_count":     doc.TablesCount,
+		"extracted_chars":  extractedChars,
+		"stripped_lines":   doc.StrippedLines,
+	}
+	if meta := doc.Metadata; meta != nil {
+		// 写入向量元数据时使用，重新处理时文档信息为空也要覆盖之前的值
+		file.DocumentTitle, file.DocumentAuthor, file.DocumentCreatedAt = meta.Title, meta.Author, meta.CreatedAt
+		parsed["document_title"] = meta.Title
+		parsed["document_author"] = meta.Author
+		parsed["document_created_at"] = meta.CreatedAt
+	}
+	db.Model(&models.FileRecord{}).Where("id = ?", fileID).Updates(parsed)
+	// 扫描件等没有文本层的文档不会产生任何块，不能当作正常完成
+	if extractedChars == 0 {
+		if config.AppConfig.Processing.EmptyTextAction == "completed_empty" {
+			p.complete(fmt.Sprintf("文档解析完成，共%d页，未提取到文本", doc.TotalPages))
+			return errEmptyDocument
+		}
+		return p.fail("文档解析失败", fmt.Errorf("文档中没有可提取的文本（可能是扫描件）: %w", asynq.SkipRetry))
+	}
+	if extractTables {
+		p.complete(fmt.Sprintf("文档解析完成，共%d页，识别到%d个表格", doc.TotalPages, doc.TablesCount))
+	} else {
+		p.complete(fmt.Sprintf("文档解析完成，共%d页", doc.TotalPages))
+	}
+
+	// 阶段2: 文本分块
+	p.begin("chunking", 40, "文本分块中...")
+	chunks, dedupedCount := chunkDocument(doc, &file, settings)
+	if dedupedCount > 0 {
+		log.Printf("文档 %s 去除了 %d 个重复块", fileID, dedupedCount)
+	}
+	originalChunks := len(chunks)
+	if chunks, err = limitChunks(chunks); err != nil {
+		db.Model(&models.FileRecord{}).Where("id = ?", fileID).Update("original_chunks", originalChunks)
+		return p.fail("文本分块失败", err)
+	}
+
+	db.Model(&models.FileRecord{}).Where("id = ?", fileID).Updates(map[string]interface{}{
+		"chunks_count":    len(chunks),
+		"original_chunks": originalChunks,
+		"deduped_chunks":  dedupedCount,
+	})
+	if len(chunks) < originalChunks {
+		p.complete(fmt.Sprintf("分块完成，共%d块，超过上限只保留前%d块", originalChunks, len(chunks)))
+	} else {
+		p.complete(fmt.Sprintf("分块完成，共%d块", len(chunks)))
+	}
+
+	// 阶段3: 向量化
+	p.begin("embedding", 60, "向量化中...")
+	embeddings, chunkModels, failed, err := embedChunks(ctx, chunks, settings)
+	if err != nil {
+		return p.fail("向量化失败", err)
+	}
+	metrics.ChunksEmbedded.WithLabelValues(file.StoreMode).Add(float64(len(chunks) - len(failed)))
+	p.complete(embeddingSummary(len(chunks), failed))
+
+	// 阶段4: 存储到向量数据库
+	p.begin("storing", 90, "存储向量中...")
+	if err := storeChunks(ctx, &file, chunks, embeddings, chunkModels); err != nil {
+		return p.fail("向量存储失败", err)
+	}
+	// 失败的块也保存文本，重试时只需重新向量化
+	if err := saveDocumentChunks(&file, chunks, chunkModels); err != nil {
+		return p.fail("保存文档块失败", err)
+	}
+	if err := recordEmbeddingResult(&file, len(chunks)-len(failed), failed); err != nil {
+		return p.fail("更新文件记录失败", err)
+	}
+	if err := verifyStoredVectors(ctx, &file, len(chunks)-len(failed)); err != nil {
+		return p.fail("向量存储校验失败", err)
+	}
+	p.complete(storeSummary(&file))
+
+	return partialError(failed)
+}
+
+// reembedDocument 用当前模型重新向量化数据库中保存的块文本并覆盖向量库中的记录，不重新解析和分块
+func reembedDocument(ctx context.Context, fileID string) error {
+	return embedStoredChunks(ctx, fileID, false)
+}
+
+// retryFailedChunks 只重新向量化上次失败的块
+func retryFailedChunks(ctx context.Context, fileID string) error {
+	return embedStoredChunks(ctx, fileID, true)
+}
+
+func embedStoredChunks(ctx context.Context, fileID string, failedOnly bool) error {
+	db := database.GetDB()
+	p := &pipeline{fileID: fileID, stage: "processing"}
+
+	settings, err := database.GetProcessingSettings()
+	if err != nil {
+		return p.fail("读取处理配置失败", err)
+	}
+
+	var file models.FileRecord
+	if err := db.Where("id = ?", fileID).First(&file).Error; err != nil {
+		return p.fail("获取文件记录失败", err)
+	}
+	// 沿用上次处理时的存储方式，不切换集合；记录存储方式之前处理的文件都写入了向量库
+	if file.StoreMode == "" {
+		file.StoreMode = "chroma"
+	}
+
+	query := db.Where("file_id = ?", fileID)
+	if failedOnly {
+		if len(file.FailedChunks) == 0 {
+			return p.fail("读取文档块失败", fmt.Errorf("没有向量化失败的块: %w", asynq.SkipRetry))
+		}
+		indices := make([]int, len(file.FailedChunks))
+		for i, failed := range file.FailedChunks {
+			indices[i] = failed.ChunkIndex
+		}
+		query = query.Where("chunk_index IN ?", indices)
+	}
+
+	var rows []models.DocumentChunk
+	if err := query.Order("chunk_index").Find(&rows).Error; err != nil {
+		return p.fail("读取文档块失败", err)
+	}
+	if len(rows) == 0 {
+		return p.fail("读取文档块失败", fmt.Errorf("没有保存的块文本，请重新处理文件: %w", asynq.SkipRetry))
+	}
+	chunks := make([]services.Chunk, len(rows))
+	for i, row := range rows {
+		chunks[i] = services.Chunk{Index: row.ChunkIndex, Content: row.Content, PageNumber: row.PageNumber}
+	}
+
+	p.begin("embedding", 60, "重新向量化中...")
+	embeddings, chunkModels, failed, err := embedChunks(ctx, chunks, settings)
+	if err != nil {
+		return p.fail("向量化失败", err)
+	}
+	metrics.ChunksEmbedded.WithLabelValues(file.StoreMode).Add(float64(len(chunks) - len(failed)))
+	p.complete(embeddingSummary(len(chunks), failed))
+
+	p.begin("storing", 90, "存储向量中...")
+	if err := storeChunks(ctx, &file, chunks, embeddings, chunkModels); err != nil {
+		return p.fail("向量存储失败", err)
+	}
+	if err := recordChunkModel(&file, chunks, chunkModels); err != nil {
+		return p.fail("更新文档块失败", err)
+	}
+	embedded := len(chunks) - len(failed)
+	if failedOnly {
+		embedded += file.EmbeddedChunks
+	}
+	if err := recordEmbeddingResult(&file, embedded, failed); err != nil {
+		return p.fail("更新文件记录失败", err)
+	}
+	if err := verifyStoredVectors(ctx, &file, embedded); err != nil {
+		return p.fail("向量存储校验失败", err)
+	}
+	p.complete(storeSummary(&file))
+
+	return partialError(failed)
+}
+
+// embedChunks 为块生成向量并检查维度。单个批次失败不影响其他批次，失败的块记录在 failed 中、
+// 对应的向量为 nil；所有块都失败或维度不一致时返回错误。chunkModels 为每个块实际使用的模型，
+// 改用备用服务的块与 EMBEDDING_MODEL 不同。ctx 取消时正在进行的请求立即中断，返回 ctx 的错误
+func embedChunks(ctx context.Context, chunks []services.Chunk, settings *models.ProcessingSettings) ([][]float32, []string, []models.FailedChunk, error) {
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.Content
+	}
+	embeddings, chunkModels, failures := services.NewEmbeddingClient().EmbedPartial(ctx, texts, settings.EmbeddingBatchSize)
+	// 取消导致的失败与块的内容无关，不能记为失败的块
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+	if len(chunks) > 0 && len(failures) == len(chunks) {
+		return nil, nil, nil, failures[0]
+	}
+	// 熔断后的块没有真正请求过，与块的内容无关，整个任务在冷却结束后重试，而不是标记为部分完成
+	for _, err := range failures {
+		if errors.Is(err, services.ErrEmbeddingCircuitOpen) {
+			return nil, nil, nil, err
+		}
+	}
+
+	var failed []models.FailedChunk
+	dimension := config.AppConfig.Embedding.Dimension
+	for i, chunk := range chunks {
+		if err, ok := failures[i]; ok {
+			failed = append(failed, models.FailedChunk{ChunkIndex: chunk.Index, Error: err.Error()})
+			continue
+		}
+		// 模型输出的维度与集合不一致时写入会失败或污染检索结果，重试也无法恢复
+		if dimension > 0 && len(embeddings[i]) != dimension {
+			return nil, nil, nil, fmt.Errorf("模型返回的向量维度为 %d，与配置的 EMBEDDING_DIMENSION=%d 不一致: %w",
+				len(embeddings[i]), dimension, asynq.SkipRetry)
+		}
+	}
+	return embeddings, chunkModels, failed, nil
+}
+
+func embeddingSummary(total int, failed []models.FailedChunk) string {
+	if len(failed) > 0 {
+		return fmt.Sprintf("向量化完成，成功%d个，失败%d个", total-len(failed), len(failed))
+	}
+	return fmt.Sprintf("向量化完成，共%d个向量", total)
+}
+
+// recordEmbeddingResult 记录已写入向量库的块数量和失败的块
+func recordEmbeddingResult(file *models.FileRecord, embedded int, failed []models.FailedChunk) error {
+	return database.GetDB().Model(&models.FileRecord{ID: file.ID}).
+		Select("embedded_chunks", "failed_chunks").
+		Updates(&models.FileRecord{EmbeddedChunks: embedded, FailedChunks: failed}).Error
+}
+
+func partialError(failed []models.FailedChunk) error {
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %d 个块未写入向量库，可重试失败的块", errPartialDocument, len(failed))
+}
+
+// DocumentAnalysis 解析并分块后的结果，不包含向量化
+type DocumentAnalysis struct {
+	TotalPages    int
+	TablesCount   int
+	Chunks        []services.Chunk
+	DedupedChunks int
+	// 按 MAX_CHUNKS 截断前的块数量
+	OriginalChunks int
+}
+
+// AnalyzeDocument 按与处理流水线相同的配置解析并分块文档，但不调用向量化接口，
+// 用于成本估算等需要预先了解分块结果的场景
+func AnalyzeDocument(file *models.FileRecord, settings *models.ProcessingSettings) (*DocumentAnalysis, error) {
+	doc, err := parseDocument(file, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, dedupedCount := chunkDocument(doc, file, settings)
+	originalChunks := len(chunks)
+	if chunks, err = limitChunks(chunks); err != nil {
+		return nil, err
+	}
+	return &DocumentAnalysis{
+		TotalPages:     doc.TotalPages,
+		TablesCount:    doc.TablesCount,
+		Chunks:         chunks,
+		DedupedChunks:  dedupedCount,
+		OriginalChunks: originalChunks,
+	}, nil
+}
+
+// parseDocument 解析文档并检查页数上限，开启 STRIP_HEADER_FOOTER 时删除页眉页脚
+func parseDocument(file *models.FileRecord, settings *models.ProcessingSettings) (*services.ParsedDocument, error) {
+	doc, err := services.ParsePDF(file.Filepath, services.ParseOptions{
+		ExtractTables:   config.AppConfig.Processing.ExtractTables,
+		DetectColumns:   config.AppConfig.Processing.LayoutMode == "columns",
+		ExtractMetadata: config.AppConfig.Processing.ExtractMetadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if settings.MaxPages > 0 && doc.TotalPages > settings.MaxPages {
+		return nil, fmt.Errorf("文档页数 %d 超过上限 %d: %w", doc.TotalPages, settings.MaxPages, asynq.SkipRetry)
+	}
+	// 在分块之前删除页眉页脚，避免每个块都混入相同的文本
+	if cfg := config.AppConfig.Processing; cfg.StripHeaderFooter {
+		doc.StrippedLines = services.StripHeadersFooters(doc, cfg.HeaderFooterMinRatio)
+	}
+	return doc, nil
+}
+
+// chunkDocument 按文件级覆盖或全局配置分块，可选去重，并拆分超过模型上下文的块
+func chunkDocument(doc *services.ParsedDocument, file *models.FileRecord, settings *models.ProcessingSettings) ([]services.Chunk, int) {
+	cfg := config.AppConfig.Processing
+
+	chunkSize, chunkOverlap, strategy := effectiveChunking(file, settings)
+	chunks := services.ChunkDocument(doc, strategy, chunkSize, chunkOverlap)
+
+	dedupedCount := 0
+	if cfg.DedupEnabled {
+		chunks, dedupedCount = services.DeduplicateChunks(chunks, cfg.DedupThreshold)
+	}
+
+	// 超过模型上下文的块继续拆分
+	chunks = services.SplitOversizedChunks(chunks, config.AppConfig.Embedding.MaxChunkTokens)
+	return chunks, dedupedCount
+}
+
+// limitChunks 检查块数量上限 MAX_CHUNKS，超过时按 MAX_CHUNKS_ACTION 拒绝处理或只保留前 MAX_CHUNKS 个块。
+// 拒绝时重试也无法通过，不再重试
+func limitChunks(chunks []services.Chunk) ([]services.Chunk, error) {
+	cfg := config.AppConfig.Processing
+	if cfg.MaxChunks == 0 || len(chunks) <= cfg.MaxChunks {
+		return chunks, nil
+	}
+	if cfg.MaxChunksAction == "truncate" {
+		return chunks[:cfg.MaxChunks], nil
+	}
+	return nil, fmt.Errorf("文档分块后共 %d 个块，超过上限 %d，可调大 MAX_CHUNKS、增大分块大小或设置 MAX_CHUNKS_ACTION=truncate: %w",
+		len(chunks), cfg.MaxChunks, asynq.SkipRetry)
+}
+
+// effectiveChunking 返回文件实际使用的分块配置，文件级覆盖优先于全局配置
+func effectiveChunking(file *models.FileRecord, settings *models.ProcessingSettings) (int, int, string) {
+	chunkSize, chunkOverlap := settings.ChunkSize, settings.ChunkOverlap
+	if file.ChunkSize != nil {
+		chunkSize = *file.ChunkSize
+	}
+	if file.ChunkOverlap != nil {
+		chunkOverlap = *file.ChunkOverlap
+	}
+	strategy := config.AppConfig.Processing.ChunkStrategy
+	if file.ChunkStrategy != nil {
+		strategy = *file.ChunkStrategy
+	}
+	return chunkSize, chunkOverlap, strategy
+}
+
+// 单次写入 ChromaDB 的最大块数
+const storeBatchSize = 500
+
+func storeChunks(ctx context.Context, file *models.FileRecord, chunks []services.Chunk, embeddings [][]float32, chunkModels []string) error {
+	// noop 只用于压测解析、分块和向量化的吞吐量，不写入向量库
+	if file.StoreMode == "noop" {
+		return nil
+	}
+	chromaClient := services.NewChromaClient()
+	collection := services.ShardCollection(file.Collection, file.Shard)
+
+	for start := 0; start < len(chunks); start += storeBatchSize {
+		end := start + storeBatchSize
+		if end > len(chunks) {
+			end = len(chunks)
+		}
+
+		req := &services.ChromaAddRequest{}
+		for i := start; i < end; i++ {
+			// 向量化失败的块不写入
+			if embeddings[i] == nil {
+				continue
+			}
+			chunk := chunks[i]
+			req.IDs = append(req.IDs, fmt.Sprintf("%s_%d", file.ID, chunk.Index))
+			req.Documents = append(req.Documents, chunk.Content)
+			req.Embeddings = append(req.Embeddings, embeddings[i])
+			metadata := map[string]interface{}{
+				"file_id":     file.ID.String(),
+				"filename":    file.Filename,
+				"chunk_index": chunk.Index,
+				"page_number": chunk.PageNumber,
+				// 更换模型后据此找出需要重新向量化的块
+				services.EmbeddingModelKey: chunkModels[i],
+			}
+			if file.TenantID != "" {
+				metadata[services.TenantIDKey] = file.TenantID
+			}
+			// PDF 文档信息中的标题和作者，可在检索时按作者过滤
+			if file.DocumentTitle != "" {
+				metadata[services.DocumentTitleKey] = file.DocumentTitle
+			}
+			if file.DocumentAuthor != "" {
+				metadata[services.DocumentAuthorKey] = file.DocumentAuthor
+			}
+			// 上传时的自定义元数据，系统字段优先；租户字段只能由系统写入，否则可能出现在其他租户的检索结果中
+			for key, value := range file.Metadata {
+				if _, exists := metadata[key]; !exists && key != services.TenantIDKey {
+					metadata[key] = value
+				}
+			}
+			req.Metadatas = append(req.Metadatas, metadata)
+		}
+
+		if len(req.IDs) == 0 {
+			continue
+		}
+		// 使用 upsert，任务重试或重新向量化时已存在的 ID 会被覆盖；集合不存在时按 CHROMA_AUTO_CREATE_COLLECTION 自动创建
+		if err := chromaClient.UpsertDocuments(ctx, collection, req); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolveStoreMode 确定本次处理的向量存储方式并写入文件记录，文件未指定时使用 PROCESSING_STORE_MODE。
+// temp 模式将集合改为 PROCESSING_TEMP_COLLECTION，删除文件、检索时都按该集合处理；
+// 之后改回 chroma 时重新按元数据选择集合。开启分片时同时选择写入的分片
+func resolveStoreMode(ctx context.Context, file *models.FileRecord) error {
+	cfg := config.AppConfig.Processing
+	mode := file.StoreMode
+	if mode == "" {
+		mode = cfg.StoreMode
+	}
+
+	collection := file.Collection
+	switch {
+	case mode == "temp":
+		collection = cfg.TempCollection
+	case collection == cfg.TempCollection:
+		collection = services.RouteCollection(file.Metadata)
+	}
+
+	shard := 0
+	if limit := config.AppConfig.ChromaDB.ShardMaxVectors; limit > 0 && mode == "chroma" {
+		var err error
+		if shard, err = activeShard(ctx, collection, limit); err != nil {
+			return err
+		}
+	}
+	// 完整处理会重新写入所有向量，换到新分片前删除文件在原分片中的向量，避免残留
+	if collection == file.Collection && shard != file.Shard {
+		previous := services.ShardCollection(file.Collection, file.Shard)
+		if err := services.NewChromaClient().DeleteDocumentsByFileID(ctx, previous, file.ID.String()); err != nil {
+			return fmt.Errorf("删除原分片 %s 中的向量失败: %w", previous, err)
+		}
+	}
+
+	file.StoreMode, file.Collection, file.Shard = mode, collection, shard
+	return database.GetDB().Model(&models.FileRecord{ID: file.ID}).Updates(map[string]interface{}{
+		"store_mode": mode,
+		"collection": collection,
+		"shard":      shard,
+	}).Error
+}
+
+// activeShard 返回集合当前写入的分片: 从已使用的最大分片开始，其向量数量达到 limit 时创建并使用下一个分片。
+// 多个任务同时选择时可能写入同一个分片，分片的实际向量数量会略微超过 limit
+func activeShard(ctx context.Context, collection string, limit int) (int, error) {
+	shard, err := database.MaxShard(collection)
+	if err != nil {
+		return 0, fmt.Errorf("查询集合分片失败: %w", err)
+	}
+
+	chromaClient := services.NewChromaClient()
+	count, err := chromaClient.CountDocuments(ctx, services.ShardCollection(collection, shard))
+	// 分片集合在外部被删除时视为空集合，写入时会自动重新创建
+	if err != nil && !errors.Is(err, services.ErrCollectionNotFound) {
+		return 0, err
+	}
+	if count < limit {
+		return shard, nil
+	}
+
+	shard++
+	name := services.ShardCollection(collection, shard)
+	if err := chromaClient.CreateCollection(ctx, name); err != nil {
+		return 0, fmt.Errorf("创建分片集合 %s 失败: %w", name, err)
+	}
+	log.Printf("集合 %s 的向量数量已达到 %d，新文件写入分片 %s", services.FileCollection(collection), limit, name)
+	return shard, nil
+}
+
+func storeSummary(file *models.FileRecord) string {
+	switch file.StoreMode {
+	case "noop":
+		return "已跳过向量存储（store_mode=noop）"
+	case "temp":
+		return fmt.Sprintf("向量存储完成，写入临时集合 %s", file.Collection)
+	}
+	return "向量存储完成"
+}
+
+// verifyStoredVectors 统计向量库中该文件的向量数量并记录到文件记录，少于 expected 说明写入时丢失了部分向量，
+// 返回错误由任务重试。多于 expected 通常是之前处理时留下的旧向量，只记录日志，由一致性检查处理
+func verifyStoredVectors(ctx context.Context, file *models.FileRecord, expected int) error {
+	if !config.AppConfig.Processing.VerifyVectors || file.StoreMode == "noop" {
+		return nil
+	}
+
+	stored, err := services.NewChromaClient().CountDocumentsByFileID(ctx, services.ShardCollection(file.Collection, file.Shard), file.ID.String())
+	if err != nil {
+		return fmt.Errorf("统计向量数量失败: %w", err)
+	}
+	if err := database.GetDB().Model(&models.FileRecord{ID: file.ID}).Update("stored_vectors", stored).Error; err != nil {
+		return err
+	}
+
+	switch {
+	case stored < expected:
+		return fmt.Errorf("向量库中只有 %d 个向量，应为 %d 个", stored, expected)
+	case stored > expected:
+		log.Printf("文件 %s 在向量库中有 %d 个向量，多于本次写入的 %d 个，可能残留了之前处理时的向量", file.ID, stored, expected)
+	}
+	return nil
+}
+
+// saveDocumentChunks 保存块文本用于关键词检索，替换该文件之前的块。
+// 按 CHUNK_INSERT_BATCH_SIZE 分批插入，所有批次在同一事务中，任一批次失败时整体回滚并在错误中指明失败的批次
+func saveDocumentChunks(file *models.FileRecord, chunks []services.Chunk, chunkModels []string) error {
+	rows := make([]models.DocumentChunk, len(chunks))
+	for i, chunk := range chunks {
+		rows[i] = models.DocumentChunk{
+			ID:         fmt.Sprintf("%s_%d", file.ID, chunk.Index),
+			FileID:     file.ID,
+			ChunkIndex: chunk.Index,
+			PageNumber: chunk.PageNumber,
+			Content:    chunk.Content,
+			// 向量化失败的块为空
+			EmbeddingModel: chunkModels[i],
+		}
+	}
+
+	batchSize := config.AppConfig.Processing.ChunkInsertBatchSize
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("file_id = ?", file.ID).Delete(&models.DocumentChunk{}).Error; err != nil {
+			return err
+		}
+		for start := 0; start < len(rows); start += batchSize {
+			end := min(start+batchSize, len(rows))
+			if err := tx.CreateInBatches(rows[start:end], batchSize).Error; err != nil {
+				return fmt.Errorf("写入第 %d-%d 个块失败（共 %d 个，已全部回滚）: %w", start+1, end, len(rows), err)
+			}
+		}
+		return nil
+	})
+}
+
+// recordChunkModel 重新向量化后更新成功的块记录的模型，按实际使用的模型分组更新
+func recordChunkModel(file *models.FileRecord, chunks []services.Chunk, chunkModels []string) error {
+	indicesByModel := make(map[string][]int)
+	for i, chunk := range chunks {
+		if chunkModels[i] != "" {
+			indicesByModel[chunkModels[i]] = append(indicesByModel[chunkModels[i]], chunk.Index)
+		}
+	}
+	for model, indices := range indicesByModel {
+		err := database.GetDB().Model(&models.DocumentChunk{}).
+			Where("file_id = ? AND chunk_index IN ?", file.ID, indices).
+			Update("embedding_model", model).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateFileStage 更新文件所处的阶段和进度。进度只增不减: 由数据库在同一条语句中比较后写入，
+// 并发的更新（如多个向量化批次同时完成）不会让较小的进度覆盖较大的进度
+func updateFileStage(fileID string, status string, progress int, message string) {
+	database.GetDB().Model(&models.FileRecord{}).Where("id = ?", fileID).Updates(map[string]interface{}{
+		"status":   status,
+		"progress": gorm.Expr("CASE WHEN progress < ? THEN ? ELSE progress END", progress, progress),
+		"message":  message,
+	})
+}
+
+func writeProcessingLog(fileID string, stage string, status string, message string, duration *float64) {
+	entry := &models.ProcessingLog{
+		FileID:   uuid.MustParse(fileID),
+		Stage:    stage,
+		Status:   status,
+		Message:  message,
+		Duration: duration,
+	}
+	if err := database.GetDB().Create(entry).Error; err != nil {
+		log.Printf("写入处理日志失败: %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+package queue
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/models"
+	"doc-analysis-backend/services"
+
+	"github.com/google/uuid"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB 在临时目录中打开 SQLite 数据库替换 database.DB，测试结束后恢复。
+// 使用文件数据库和多个连接，让并发的更新真正在不同连接上执行。
+// FileRecord 的主键默认值 gen_random_uuid() 只在 PostgreSQL 中可用，因此表手动创建，只包含测试用到的列
+func openTestDB(t testing.TB, schema ...string) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=10000&_journal_mode=WAL"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	for _, stmt := range schema {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("create schema: %v", err)
+		}
+	}
+
+	saved := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = saved
+		sqlDB.Close()
+	})
+	return db
+}
+
+const fileRecordsSchema = `CREATE TABLE file_records (
+	id TEXT PRIMARY KEY,
+	status TEXT NOT NULL DEFAULT 'pending',
+	progress INTEGER DEFAULT 0,
+	message TEXT,
+	chunks_count INTEGER DEFAULT 0,
+	updated_at DATETIME
+)`
+
+func TestUpdateFileStageProgressNeverDecreases(t *testing.T) {
+	db := openTestDB(t, fileRecordsSchema)
+	const fileID = "7d0c1f4e-0000-4000-8000-000000000001"
+	if err := db.Exec("INSERT INTO file_records (id, status, progress) VALUES (?, 'embedding', 0)", fileID).Error; err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	readProgress := func() int {
+		var record models.FileRecord
+		if err := db.Select("progress").Where("id = ?", fileID).Take(&record).Error; err != nil {
+			t.Errorf("read progress: %v", err)
+		}
+		return record.Progress
+	}
+
+	// 观察者持续读取进度，记录是否出现回退
+	stop := make(chan struct{})
+	observed := make(chan error, 1)
+	go func() {
+		last := 0
+		for {
+			select {
+			case <-stop:
+				observed <- nil
+				return
+			default:
+			}
+			if p := readProgress(); p < last {
+				observed <- fmt.Errorf("progress went from %d back to %d", last, p)
+				return
+			} else {
+				last = p
+			}
+		}
+	}()
+
+	// 模拟多个向量化批次以乱序完成，各自写入自己的进度
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				progress := (i*8 + worker*37) % 96
+				updateFileStage(fileID, "embedding", progress, fmt.Sprintf("批次 %d/%d", worker, i))
+			}
+		}(worker)
+	}
+	wg.Wait()
+	close(stop)
+	if err := <-observed; err != nil {
+		t.Fatal(err)
+	}
+
+	maxProgress := 0
+	for worker := 0; worker < 8; worker++ {
+		for i := 0; i < 25; i++ {
+			maxProgress = max(maxProgress, (i*8+worker*37)%96)
+		}
+	}
+	if got := readProgress(); got != maxProgress {
+		t.Fatalf("final progress = %d, want the highest reported %d", got, maxProgress)
+	}
+
+	// 较小的进度仍会更新状态和消息
+	updateFileStage(fileID, "completed", 10, "处理完成")
+	var record models.FileRecord
+	db.Where("id = ?", fileID).Take(&record)
+	if record.Status != "completed" || record.Message != "处理完成" || record.Progress != maxProgress {
+		t.Fatalf("after lower progress update: status=%q message=%q progress=%d", record.Status, record.Message, record.Progress)
+	}
+}
+
+// withChunkInsertBatchSize 临时替换 config.AppConfig，测试结束后恢复
+func withChunkInsertBatchSize(t testing.TB, batchSize int) {
+	t.Helper()
+	saved := config.AppConfig
+	cfg := &config.Config{}
+	cfg.Processing.ChunkInsertBatchSize = batchSize
+	config.AppConfig = cfg
+	t.Cleanup(func() { config.AppConfig = saved })
+}
+
+func openChunkTestDB(t testing.TB) *gorm.DB {
+	t.Helper()
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&models.DocumentChunk{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func testChunks(n int) ([]services.Chunk, []string) {
+	chunks := make([]services.Chunk, n)
+	chunkModels := make([]string, n)
+	for i := range chunks {
+		chunks[i] = services.Chunk{Index: i, PageNumber: i/10 + 1, Content: strings.Repeat("文档内容", 50)}
+		chunkModels[i] = "test-embedding"
+	}
+	return chunks, chunkModels
+}
+
+func countChunks(t testing.TB, db *gorm.DB, fileID uuid.UUID) int64 {
+	t.Helper()
+	var count int64
+	if err := db.Model(&models.DocumentChunk{}).Where("file_id = ?", fileID).Count(&count).Error; err != nil {
+		t.Fatalf("count chunks: %v", err)
+	}
+	return count
+}
+
+func TestSaveDocumentChunksReplacesPreviousChunks(t *testing.T) {
+	withChunkInsertBatchSize(t, 4)
+	db := openChunkTestDB(t)
+	file := &models.FileRecord{ID: uuid.New()}
+
+	chunks, chunkModels := testChunks(10)
+	if err := saveDocumentChunks(file, chunks, chunkModels); err != nil {
+		t.Fatalf("first save: %v", err)
+	}
+	chunks, chunkModels = testChunks(7)
+	if err := saveDocumentChunks(file, chunks, chunkModels); err != nil {
+		t.Fatalf("second save: %v", err)
+	}
+	if got := countChunks(t, db, file.ID); got != 7 {
+		t.Fatalf("stored %d chunks, want 7", got)
+	}
+}
+
+func TestSaveDocumentChunksRollsBackFailedBatch(t *testing.T) {
+	withChunkInsertBatchSize(t, 5)
+	db := openChunkTestDB(t)
+	file := &models.FileRecord{ID: uuid.New()}
+
+	previous, previousModels := testChunks(3)
+	if err := saveDocumentChunks(file, previous, previousModels); err != nil {
+		t.Fatalf("save previous chunks: %v", err)
+	}
+
+	// 第 8 个块（chunk_index 7，位于第二批 6-10）写入时失败
+	err := db.Exec(`CREATE TRIGGER fail_chunk BEFORE INSERT ON document_chunks
+		WHEN NEW.chunk_index = 7 BEGIN SELECT RAISE(ABORT, 'injected failure'); END`).Error
+	if err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+
+	chunks, chunkModels := testChunks(12)
+	err = saveDocumentChunks(file, chunks, chunkModels)
+	if err == nil {
+		t.Fatal("expected an error from the failing batch")
+	}
+	if !strings.Contains(err.Error(), "第 6-10 个块") || !strings.Contains(err.Error(), "共 12 个") {
+		t.Fatalf("error does not name the failing batch: %v", err)
+	}
+	if !strings.Contains(err.Error(), "injected failure") {
+		t.Fatalf("error does not wrap the database error: %v", err)
+	}
+
+	// 第一批已写入的块和删除旧块的操作都应回滚，保留之前的 3 个块
+	var stored []models.DocumentChunk
+	db.Where("file_id = ?", file.ID).Order("chunk_index").Find(&stored)
+	if len(stored) != len(previous) {
+		t.Fatalf("stored %d chunks after rollback, want the previous %d", len(stored), len(previous))
+	}
+	for i, row := range stored {
+		if row.ChunkIndex != i {
+			t.Fatalf("unexpected chunk after rollback: %+v", row)
+		}
+	}
+}
+
+// BenchmarkSaveDocumentChunks 对比逐条插入（批大小 1）和分批插入的耗时
+func BenchmarkSaveDocumentChunks(b *testing.B) {
+	chunks, chunkModels := testChunks(2000)
+	for _, batchSize := range []int{1, 100, 500} {
+		b.Run(fmt.Sprintf("batch=%d", batchSize), func(b *testing.B) {
+			withChunkInsertBatchSize(b, batchSize)
+			openChunkTestDB(b)
+			file := &models.FileRecord{ID: uuid.New()}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := saveDocumentChunks(file, chunks, chunkModels); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -3,13 +3,16 @@ package queue
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
 	"doc-analysis-backend/config"
 	"doc-analysis-backend/database"
+	"doc-analysis-backend/metrics"
 	"doc-analysis-backend/models"
+	"doc-analysis-backend/services"
 
 	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
@@ -20,12 +23,20 @@ import (
 var (
 	Client *asynq.Client
 	Server *asynq.Server
+
+	// 文件大小分级队列各自使用独立的 Server，Concurrency 即该级别的并发上限
+	tierServers []*asynq.Server
 )
 
 const (
-	TaskProcessDocument = "process_document"
+	TaskProcessDocument   = "process_document"
+	TaskReembedDocument   = "reembed_document"
+	TaskRetryFailedChunks = "retry_failed_chunks"
 )
 
+// 向量化服务探测失败时推迟任务，而不是让任务反复失败
+var errEmbeddingUnavailable = errors.New("向量化服务暂不可用")
+
 type TaskPayload struct {
 	FileID string `json:"file_id"`
 }
@@ -40,31 +51,100 @@ func InitQueue() {
 	}
 	
 	Client = asynq.NewClient(redisOpt)
+	Inspector = asynq.NewInspector(redisOpt)
+	lockClient = GetRedisClient()
 	
-	Server = asynq.NewServer(redisOpt, asynq.Config{
-		Concurrency: 10,
-		Queues: map[string]int{
-			"critical": 6,
-			"default":  3,
-			"low":      1,
-		},
+	Server = asynq.NewServer(redisOpt, serverConfig(defaultConcurrency, map[string]int{
+		"critical": 6,
+		"default":  3,
+		"low":      1,
+	}))
+	for _, tier := range config.AppConfig.Queue.SizeTiers {
+		tierServers = append(tierServers, asynq.NewServer(redisOpt, serverConfig(tier.Concurrency, map[string]int{
+			tierQueueName(tier): 1,
+		})))
+		log.Printf("文件大小分级队列 %s: 不小于 %dMB，并发 %d", tierQueueName(tier), tier.MinSize/1024/1024, tier.Concurrency)
+	}
+	
+	log.Println("任务队列初始化成功")
+}
+
+// 默认队列 (critical/default/low) 的并发数
+const defaultConcurrency = 10
+
+// WorkerConcurrency 返回所有工作器的并发数之和，包括文件大小分级队列
+func WorkerConcurrency() int {
+	total := defaultConcurrency
+	for _, tier := range config.AppConfig.Queue.SizeTiers {
+		total += tier.Concurrency
+	}
+	return total
+}
+
+func serverConfig(concurrency int, queues map[string]int) asynq.Config {
+	return asynq.Config{
+		Concurrency: concurrency,
+		Queues:      queues,
 		RetryDelayFunc: func(n int, e error, t *asynq.Task) time.Duration {
+			// 向量化熔断时等到冷却结束后再尝试
+			if errors.Is(e, services.ErrEmbeddingCircuitOpen) {
+				return max(services.EmbeddingBreakerRetryAfter(), time.Second)
+			}
+			// 向量化服务不可用时等到下一次探测后再尝试
+			if errors.Is(e, errEmbeddingUnavailable) {
+				return config.AppConfig.Embedding.ProbeInterval
+			}
 			return time.Duration(n) * time.Second
 		},
-	})
-	
-	log.Println("任务队列初始化成功")
+		// 因向量化服务不可用或熔断而推迟的任务不消耗重试次数
+		IsFailure: func(err error) bool {
+			return !errors.Is(err, errEmbeddingUnavailable) && !errors.Is(err, services.ErrEmbeddingCircuitOpen)
+		},
+	}
+}
+
+func tierQueueName(tier config.SizeTier) string {
+	return "size_" + tier.Name
+}
+
+// queueForFile 按文件大小选择队列，超过分级阈值的文件进入对应的低并发队列
+func queueForFile(fileID string) string {
+	var file models.FileRecord
+	if err := database.GetDB().Select("file_size").Where("id = ?", fileID).First(&file).Error; err != nil {
+		return "default"
+	}
+	for _, tier := range config.AppConfig.Queue.SizeTiers {
+		if file.FileSize >= tier.MinSize {
+			return tierQueueName(tier)
+		}
+	}
+	return "default"
 }
 
 func EnqueueProcessDocument(fileID string) (*asynq.TaskInfo, error) {
+	return enqueueFileTask(TaskProcessDocument, fileID)
+}
+
+// EnqueueReembedDocument 提交重新向量化任务，只使用已保存的块文本，不重新解析文件
+func EnqueueReembedDocument(fileID string) (*asynq.TaskInfo, error) {
+	return enqueueFileTask(TaskReembedDocument, fileID)
+}
+
+// EnqueueRetryFailedChunks 提交任务，只重新向量化部分完成的文件中失败的块
+func EnqueueRetryFailedChunks(fileID string) (*asynq.TaskInfo, error) {
+	return enqueueFileTask(TaskRetryFailedChunks, fileID)
+}
+
+func enqueueFileTask(taskType string, fileID string) (*asynq.TaskInfo, error) {
 	payload := TaskPayload{FileID: fileID}
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return nil, fmt.Errorf("序列化任务载荷失败: %w", err)
 	}
 	
-	task := asynq.NewTask(TaskProcessDocument, data)
-	info, err := Client.Enqueue(task, asynq.MaxRetry(3), asynq.Queue("default"))
+	queueName := queueForFile(fileID)
+	task := asynq.NewTask(taskType, data)
+	info, err := Client.Enqueue(task, asynq.MaxRetry(3), asynq.Queue(queueName))
 	if err != nil {
 		return nil, fmt.Errorf("任务入队失败: %w", err)
 	}
@@ -73,7 +153,8 @@ func EnqueueProcessDocument(fileID string) (*asynq.TaskInfo, error) {
 	taskRecord := &models.Task{
 		ID:     info.ID,
 		FileID: uuid.MustParse(fileID),
-		Type:   TaskProcessDocument,
+		Type:   taskType,
+		Queue:  queueName,
 		Status: models.TaskPending,
 	}
 	
@@ -88,8 +169,16 @@ func EnqueueProcessDocument(fileID string) (*asynq.TaskInfo, error) {
 func StartWorker() {
 	mux := asynq.NewServeMux()
 	mux.HandleFunc(TaskProcessDocument, HandleProcessDocument)
+	mux.HandleFunc(TaskReembedDocument, HandleProcessDocument)
+	mux.HandleFunc(TaskRetryFailedChunks, HandleProcessDocument)
 	
 	log.Println("任务工作器启动中...")
+	StartFailedTaskMonitor()
+	for _, srv := range tierServers {
+		if err := srv.Start(mux); err != nil {
+			log.Fatalf("文件大小分级队列工作器启动失败: %v", err)
+		}
+	}
 	if err := Server.Run(mux); err != nil {
 		log.Fatalf("任务工作器启动失败: %v", err)
 	}
@@ -103,6 +192,48 @@ func HandleProcessDocument(ctx context.Context, t *asynq.Task) error {
 	
 	db := database.GetDB()
 	
+	// 持有文件锁期间，删除等操作无法修改该文件的记录和向量
+	lock, err := AcquireFileLock(ctx, payload.FileID, 0)
+	if err != nil {
+		return fmt.Errorf("文件 %s 暂时无法处理: %w", payload.FileID, err)
+	}
+	defer lock.Release()
+	
+	// 文件可能在排队期间已被删除
+	var file models.FileRecord
+	if err := db.Select("id", "filename", "hold").Where("id = ?", payload.FileID).First(&file).Error; err != nil {
+		return fmt.Errorf("文件 %s 不存在: %w", payload.FileID, asynq.SkipRetry)
+	}
+	
+	// 暂停处理的文件不再自动重试，首次执行（手动触发）照常处理
+	if retried, _ := asynq.GetRetryCount(ctx); file.Hold && retried > 0 {
+		taskID, _ := asynq.GetTaskID(ctx)
+		endTime := time.Now()
+		db.Model(&models.Task{}).Where("id = ?", taskID).Updates(map[string]interface{}{
+			"status":    models.TaskFailed,
+			"ended_at":  &endTime,
+			"error_msg": "文件已暂停处理，跳过自动重试",
+		})
+		return fmt.Errorf("文件 %s 已暂停处理，跳过自动重试: %w", payload.FileID, asynq.SkipRetry)
+	}
+	
+	// 压缩包只解压不需要向量化，其余文件在向量化服务恢复或熔断冷却结束前推迟处理
+	if !IsArchiveFile(file.Filename) {
+		var unavailable error
+		switch {
+		case services.EmbeddingCircuitOpen():
+			unavailable = services.ErrEmbeddingCircuitOpen
+		case !services.EmbeddingAvailable():
+			unavailable = errEmbeddingUnavailable
+		}
+		if unavailable != nil {
+			db.Model(&models.FileRecord{}).Where("id = ?", payload.FileID).Update("message", "向量化服务暂不可用，等待恢复后自动处理...")
+			taskID, _ := asynq.GetTaskID(ctx)
+			db.Model(&models.Task{}).Where("id = ?", taskID).Update("status", models.TaskRetrying)
+			return fmt.Errorf("文件 %s 推迟处理: %w", payload.FileID, unavailable)
+		}
+	}
+	
 	// 更新任务状态
 	now := time.Now()
 	taskID, _ := asynq.GetTaskID(ctx)
@@ -110,67 +241,134 @@ func HandleProcessDocument(ctx context.Context, t *asynq.Task) error {
 		"status":     models.TaskRunning,
 		"started_at": &now,
 	}
+	
+	// 首次开始执行时记录排队等待时间
+	var task models.Task
+	if err := db.Select("id", "created_at", "started_at").Where("id = ?", taskID).First(&task).Error; err == nil && task.StartedAt == nil {
+		wait := now.Sub(task.CreatedAt).Seconds()
+		taskUpdate["queue_wait_seconds"] = wait
+		metrics.TaskQueueWait.Observe(wait)
+	}
 	db.Model(&models.Task{}).Where("id = ?", taskID).Updates(taskUpdate)
 	
 	// 更新文件状态
 	fileID := uuid.MustParse(payload.FileID)
+	// 进度只增不减，每次开始处理时从 0 开始
 	db.Model(&models.FileRecord{}).Where("id = ?", fileID).Updates(map[string]interface{}{
-		"status":  "processing",
-		"message": "正在处理文档...",
+		"status":   "processing",
+		"progress": 0,
+		"message":  "正在处理文档...",
 	})
 	
 	log.Printf("开始处理文档: %s", payload.FileID)
 	
-	// 这里是实际的文档处理逻辑
-	if err := processDocument(payload.FileID); err != nil {
-		// 任务失败
+	// 压缩包只解压并为其中的文件创建新任务，其余文件走文档处理流水线，
+	// 重新向量化任务跳过解析和分块
+	process, runType := processDocument, "process"
+	switch {
+	case t.Type() == TaskReembedDocument:
+		process, runType = reembedDocument, "reembed"
+	case t.Type() == TaskRetryFailedChunks:
+		process, runType = retryFailedChunks, "retry_failed"
+	case IsArchiveFile(file.Filename):
+		process, runType = processArchive, "archive"
+	}
+	
+	run := startProcessingRun(payload.FileID, runType)
+	err = process(ctx, payload.FileID)
+	// 无论成功与否向量都可能已经变化
+	services.InvalidateSearchCache(payload.FileID)
+	status, finishErr := finishProcessing(taskID, payload.FileID, err, isLastAttempt(ctx, err))
+	run.finish(status, err)
+	if finishErr != nil {
+		return finishErr
+	}
+
+	log.Printf("文档处理完成: %s", payload.FileID)
+	return nil
+}
+
+// isLastAttempt 判断失败的任务是否不会再被自动重试: 重试次数已用完，或错误标记为不可重试
+func isLastAttempt(ctx context.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, asynq.SkipRetry) {
+		return true
+	}
+	// 熔断导致的失败不消耗重试次数，冷却结束后总会再次执行
+	if errors.Is(err, services.ErrEmbeddingCircuitOpen) {
+		return false
+	}
+	retried, ok := asynq.GetRetryCount(ctx)
+	maxRetry, hasMax := asynq.GetMaxRetry(ctx)
+	return ok && hasMax && retried >= maxRetry
+}
+
+// finishProcessing 根据处理结果更新任务和文件的最终状态，返回文件的最终状态；
+// 处理失败时原样返回错误。taskID 为空表示不是通过队列执行的（同步处理）。
+// lastAttempt 为 true 时任务不会再自动重试，文件标记为 failed_permanent 而不是 error
+func finishProcessing(taskID, fileID string, err error, lastAttempt bool) (string, error) {
+	db := database.GetDB()
+
+	finalStatus, finalMessage := "completed", "处理完成"
+	switch {
+	case errors.Is(err, errEmptyDocument):
+		finalStatus, finalMessage = "completed_empty", "处理完成，文档中没有可提取的文本"
+		err = nil
+	case errors.Is(err, errPartialDocument):
+		finalStatus, finalMessage = "partial", fmt.Sprintf("部分完成: %v", err)
+		err = nil
+	}
+	if err != nil {
+		// 任务失败，记录失败所在的阶段
+		stage := "processing"
+		var stageErr *StageError
+		if errors.As(err, &stageErr) {
+			stage = stageErr.Stage
+		}
+		writeProcessingLog(fileID, stage, "failed", err.Error(), nil)
+
 		endTime := time.Now()
-		taskID, _ := asynq.GetTaskID(ctx)
-		db.Model(&models.Task{}).Where("id = ?", taskID).Updates(map[string]interface{}{
-			"status":    models.TaskFailed,
-			"ended_at":  &endTime,
-			"error_msg": err.Error(),
-		})
+		if taskID != "" {
+			db.Model(&models.Task{}).Where("id = ?", taskID).Updates(map[string]interface{}{
+				"status":    models.TaskFailed,
+				"ended_at":  &endTime,
+				"error_msg": err.Error(),
+			})
+		}
 		
+		status, message := "error", fmt.Sprintf("处理失败: %v", err)
+		if lastAttempt {
+			status, message = "failed_permanent", fmt.Sprintf("处理失败，不会再自动重试: %v", err)
+		}
 		db.Model(&models.FileRecord{}).Where("id = ?", fileID).Updates(map[string]interface{}{
-			"status":      "error",
-			"message":     fmt.Sprintf("处理失败: %v", err),
+			"status":      status,
+			"message":     message,
 			"error_count": gorm.Expr("error_count + 1"),
 			"last_error":  err.Error(),
 		})
+		alertOnErrorCount(fileID)
 		
-		return err
+		return status, err
 	}
 	
 	// 任务成功
 	endTime := time.Now()
-	taskID, _ = asynq.GetTaskID(ctx)
-	db.Model(&models.Task{}).Where("id = ?", taskID).Updates(map[string]interface{}{
-		"status":   models.TaskCompleted,
-		"ended_at": &endTime,
-	})
+	if taskID != "" {
+		db.Model(&models.Task{}).Where("id = ?", taskID).Updates(map[string]interface{}{
+			"status":   models.TaskCompleted,
+			"ended_at": &endTime,
+		})
+	}
 	
 	db.Model(&models.FileRecord{}).Where("id = ?", fileID).Updates(map[string]interface{}{
-		"status":   "completed",
+		"status":   finalStatus,
 		"progress": 100,
-		"message":  "处理完成",
+		"message":  finalMessage,
 	})
 	
-	log.Printf("文档处理完成: %s", payload.FileID)
-	return nil
-}
-
-func processDocument(fileID string) error {
-	// TODO: 实现实际的文档处理逻辑
-	// 1. 解析PDF
-	// 2. 文本分块
-	// 3. 生成向量嵌入
-	// 4. 存储到ChromaDB
-	
-	// 模拟处理时间
-	time.Sleep(5 * time.Second)
-	
-	return nil
+	return finalStatus, nil
 }
 
 func GetRedisClient() *redis.Client {
@@ -186,8 +384,18 @@ func CloseQueue() {
 	if Client != nil {
 		Client.Close()
 	}
+	if Inspector != nil {
+		Inspector.Close()
+	}
+	if lockClient != nil {
+		lockClient.Close()
+	}
 	if Server != nil {
 		Server.Stop()
 		Server.Shutdown()
 	}
+	for _, srv := range tierServers {
+		srv.Stop()
+		srv.Shutdown()
+	}
 }
\ No newline at end of file
@@ -0,0 +1,137 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/models"
+	"doc-analysis-backend/services"
+)
+
+// 处理已结束、原始文件不再需要的状态
+var retentionStatuses = []string{"completed", "completed_empty", "partial"}
+
+// PurgedFile 一个被清理（或 dry-run 时将被清理）的文件
+type PurgedFile struct {
+	FileID   string `json:"file_id"`
+	Filename string `json:"filename"`
+	FileSize int64  `json:"file_size"`
+	Error    string `json:"error,omitempty"`
+}
+
+// RetentionResult 一次清理的结果
+type RetentionResult struct {
+	DryRun     bool         `json:"dry_run"`
+	Cutoff     time.Time    `json:"cutoff"`
+	Files      []PurgedFile `json:"files"`
+	Purged     int          `json:"purged"`
+	Failed     int          `json:"failed"`
+	FreedBytes int64        `json:"freed_bytes"`
+}
+
+// StartRetentionJob 在后台按 RETENTION_CHECK_INTERVAL 定期清理过期文件，RETENTION_DAYS 为 0 时不启动
+func StartRetentionJob() {
+	cfg := config.AppConfig.Retention
+	if cfg.Days <= 0 || cfg.CheckInterval <= 0 {
+		return
+	}
+
+	log.Printf("启动文件清理任务: 保留 %d 天，每 %s 检查一次", cfg.Days, cfg.CheckInterval)
+	go func() {
+		ticker := time.NewTicker(cfg.CheckInterval)
+		defer ticker.Stop()
+		for {
+			if _, err := PurgeExpiredFiles(context.Background(), cfg.DryRun); err != nil {
+				log.Printf("清理过期文件失败: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// PurgeExpiredFiles 删除上传超过 RETENTION_DAYS 天且处理已结束的文件的原始文件，并标记记录为已清理。
+// dryRun 为 true 时只记录将被清理的文件；正在被其他操作持有锁的文件本次跳过
+func PurgeExpiredFiles(ctx context.Context, dryRun bool) (*RetentionResult, error) {
+	cfg := config.AppConfig.Retention
+	if cfg.Days <= 0 {
+		return nil, errors.New("未配置 RETENTION_DAYS")
+	}
+
+	result := &RetentionResult{
+		DryRun: dryRun,
+		Cutoff: time.Now().AddDate(0, 0, -cfg.Days),
+		Files:  []PurgedFile{},
+	}
+
+	var files []models.FileRecord
+	err := database.GetDB().
+		Where("status IN ? AND file_purged = ? AND created_at < ?", retentionStatuses, false, result.Cutoff).
+		Order("created_at").
+		Find(&files).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询过期文件失败: %w", err)
+	}
+
+	for _, file := range files {
+		item := PurgedFile{FileID: file.ID.String(), Filename: file.Filename, FileSize: file.FileSize}
+		if dryRun {
+			log.Printf("[dry-run] 将清理文件 %s (%s, %d 字节)", file.ID, file.Filename, file.FileSize)
+		} else if err := purgeFile(ctx, &file, cfg.DeleteVectors); err != nil {
+			item.Error = err.Error()
+			result.Failed++
+			log.Printf("清理文件 %s 失败: %v", file.ID, err)
+		} else {
+			result.Purged++
+			result.FreedBytes += file.FileSize
+		}
+		result.Files = append(result.Files, item)
+	}
+
+	if !dryRun && len(files) > 0 {
+		log.Printf("过期文件清理完成: 清理 %d 个，失败 %d 个，释放 %d 字节", result.Purged, result.Failed, result.FreedBytes)
+	}
+	return result, nil
+}
+
+func purgeFile(ctx context.Context, file *models.FileRecord, deleteVectors bool) error {
+	fileID := file.ID.String()
+	lock, err := AcquireFileLock(ctx, fileID, 0)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	db := database.GetDB()
+	if deleteVectors {
+		if err := services.NewChromaClient().DeleteDocumentsByFileID(ctx, services.ShardCollection(file.Collection, file.Shard), fileID); err != nil {
+			return fmt.Errorf("删除向量数据失败: %w", err)
+		}
+		services.InvalidateSearchCache(fileID)
+		if err := db.Where("file_id = ?", fileID).Delete(&models.DocumentChunk{}).Error; err != nil {
+			return fmt.Errorf("删除文档块失败: %w", err)
+		}
+	}
+
+	if err := os.Remove(file.Filepath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除原始文件失败: %w", err)
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"file_purged": true,
+		"purged_at":   &now,
+	}
+	if deleteVectors {
+		updates["embedded_chunks"] = 0
+	}
+	if err := db.Model(&models.FileRecord{}).Where("id = ?", fileID).Updates(updates).Error; err != nil {
+		return fmt.Errorf("更新文件记录失败: %w", err)
+	}
+	return nil
+}
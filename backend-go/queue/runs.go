@@ -0,0 +1,74 @@
+package queue
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/models"
+
+	"github.com/google/uuid"
+)
+
+// processingRun 记录处理开始时的配置，处理结束后由 finish 连同结果写入一条 ProcessingRun
+type processingRun struct {
+	record models.ProcessingRun
+}
+
+// startProcessingRun 在处理开始前读取当前生效的配置，处理过程中修改的配置不影响本次记录
+func startProcessingRun(fileID, runType string) *processingRun {
+	run := &processingRun{record: models.ProcessingRun{
+		FileID:    uuid.MustParse(fileID),
+		Type:      runType,
+		StartedAt: time.Now(),
+	}}
+	// 压缩包只解压，不涉及分块和向量化的配置
+	if runType == "archive" {
+		return run
+	}
+
+	var file models.FileRecord
+	if err := database.GetDB().Where("id = ?", fileID).First(&file).Error; err != nil {
+		return run
+	}
+	settings, err := database.GetProcessingSettings()
+	if err != nil {
+		return run
+	}
+	run.record.ChunkSize, run.record.ChunkOverlap, run.record.ChunkStrategy = effectiveChunking(&file, settings)
+	run.record.EmbeddingModel = config.AppConfig.Embedding.Model
+	run.record.EmbeddingBatchSize = settings.EmbeddingBatchSize
+	return run
+}
+
+// finish 写入本次处理的结果，status 为处理结束时文件的状态，err 为处理流水线返回的原始错误。
+// 写入失败只记录日志，不影响处理结果
+func (r *processingRun) finish(status string, err error) {
+	db := database.GetDB()
+	record := &r.record
+	record.Status = status
+	record.Duration = time.Since(record.StartedAt).Seconds()
+	if err != nil && !errors.Is(err, errEmptyDocument) {
+		record.Error = err.Error()
+	}
+
+	var file models.FileRecord
+	if err := db.Select("id", "chunks_count", "embedded_chunks", "failed_chunks", "store_mode").
+		Where("id = ?", record.FileID).First(&file).Error; err == nil {
+		record.ChunksCount = file.ChunksCount
+		record.EmbeddedChunks = file.EmbeddedChunks
+		record.FailedChunks = len(file.FailedChunks)
+		record.StoreMode = file.StoreMode
+	}
+
+	// 同一文件的处理持有文件锁，不会并发写入，序号不会重复
+	var count int64
+	db.Model(&models.ProcessingRun{}).Where("file_id = ?", record.FileID).Count(&count)
+	record.RunNumber = int(count) + 1
+
+	if err := db.Create(record).Error; err != nil {
+		log.Printf("保存文件 %s 的处理记录失败: %v", record.FileID, err)
+	}
+}
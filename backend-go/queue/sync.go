@@ -0,0 +1,51 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"doc-analysis-backend/database"
+	"doc-analysis-backend/models"
+	"doc-analysis-backend/services"
+)
+
+// ErrSyncTimeout 同步处理超出时间预算，调用方应改为提交异步任务
+var ErrSyncTimeout = errors.New("同步处理超时")
+
+// ProcessDocumentSync 在当前请求中直接执行文档处理流水线，返回文件的最终状态。
+// 超过 budget 时取消处理，在流水线退出、释放文件锁后返回 ErrSyncTimeout，此时不写入最终状态，
+// 由调用方改为提交异步任务。向量化和向量库请求会随 budget 立即中断；解析等不可中断的步骤
+// 会先执行完，因此返回时间可能略晚于 budget，但返回后同步处理不会再修改文件记录，异步任务也能立即获取文件锁
+func ProcessDocumentSync(fileID string, budget time.Duration) (string, error) {
+	// 不使用请求的 context，客户端断开时处理仍会完成并写入最终状态
+	ctx, cancel := context.WithTimeout(context.Background(), budget)
+	defer cancel()
+
+	lock, err := AcquireFileLock(ctx, fileID, 0)
+	if err != nil {
+		return "", err
+	}
+	defer lock.Release()
+
+	database.GetDB().Model(&models.FileRecord{}).Where("id = ?", fileID).Updates(map[string]interface{}{
+		"status":   "processing",
+		"progress": 0,
+		"message":  "正在同步处理文档...",
+	})
+	log.Printf("开始同步处理文档: %s", fileID)
+
+	run := startProcessingRun(fileID, "process")
+	err = processDocument(ctx, fileID)
+	services.InvalidateSearchCache(fileID)
+	if ctx.Err() != nil {
+		// 已超时，最终状态和处理记录由异步任务写入
+		log.Printf("文档 %s 同步处理超过 %s，改为异步处理", fileID, budget)
+		return "", ErrSyncTimeout
+	}
+	// 同步处理失败后不会自动重试，但可以重新提交，仍标记为 error
+	status, finishErr := finishProcessing("", fileID, err, false)
+	run.finish(status, err)
+	return status, finishErr
+}
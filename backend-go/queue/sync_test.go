@@ -0,0 +1,312 @@
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/models"
+
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// startFakeRedis 启动一个只支持文件锁所需命令（SET NX、GET、DEL 和锁的两个 Lua 脚本）的 Redis 服务，
+// 并将 lockClient 指向它，测试结束后恢复
+func startFakeRedis(t *testing.T) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	data := map[string]string{}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeRedis(conn, &mu, data)
+		}
+	}()
+
+	saved := lockClient
+	lockClient = redis.NewClient(&redis.Options{Addr: listener.Addr().String(), Protocol: 2})
+	t.Cleanup(func() {
+		lockClient.Close()
+		lockClient = saved
+		listener.Close()
+	})
+}
+
+func serveFakeRedis(conn net.Conn, mu *sync.Mutex, data map[string]string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readRESPCommand(r)
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		reply := fakeRedisReply(args, data)
+		mu.Unlock()
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func fakeRedisReply(args []string, data map[string]string) string {
+	const nilReply = "$-1\r\n"
+	bulk := func(s string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s) }
+	integer := func(n int) string { return fmt.Sprintf(":%d\r\n", n) }
+
+	switch strings.ToUpper(args[0]) {
+	case "SET":
+		nx := false
+		for _, arg := range args[3:] {
+			nx = nx || strings.EqualFold(arg, "NX")
+		}
+		if _, exists := data[args[1]]; exists && nx {
+			return nilReply
+		}
+		data[args[1]] = args[2]
+		return "+OK\r\n"
+	case "GET":
+		if value, ok := data[args[1]]; ok {
+			return bulk(value)
+		}
+		return nilReply
+	case "DEL":
+		_, ok := data[args[1]]
+		delete(data, args[1])
+		if ok {
+			return integer(1)
+		}
+		return integer(0)
+	case "EVALSHA":
+		return "-NOSCRIPT No matching script\r\n"
+	case "EVAL":
+		// releaseScript 和 refreshScript: 只在锁仍由 ARGV[1] 持有时删除或续期
+		script, key, token := args[1], args[3], args[4]
+		if data[key] != token {
+			return integer(0)
+		}
+		if strings.Contains(script, "DEL") {
+			delete(data, key)
+		}
+		return integer(1)
+	case "CLIENT", "PING":
+		return "+OK\r\n"
+	default:
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
+
+func readRESPCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected RESP line %q", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(header[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+// writeTestPDF 生成一个单页、包含 text 的最小 PDF 文件
+func writeTestPDF(t *testing.T, text string) string {
+	t.Helper()
+	content := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	path := filepath.Join(t.TempDir(), "doc.pdf")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// migrateForSQLite 在 SQLite 中创建模型对应的表。gen_random_uuid() 默认值只在 PostgreSQL 中可用，
+// 建表前从解析后的模型中去掉，主键由模型的 BeforeCreate 生成
+func migrateForSQLite(t testing.TB, db *gorm.DB, dst ...interface{}) {
+	t.Helper()
+	for _, model := range dst {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatalf("parse model: %v", err)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DefaultValue == "gen_random_uuid()" {
+				field.HasDefaultValue, field.DefaultValue, field.DefaultValueInterface = false, "", nil
+			}
+		}
+	}
+	if err := db.AutoMigrate(dst...); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+}
+
+// slowEmbeddingServer 向量化服务，slow 为 true 时请求一直阻塞到客户端取消
+func slowEmbeddingServer(t *testing.T, slow *atomic.Bool) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slow.Load() {
+			// 读完请求体后服务端才能感知客户端断开
+			io.Copy(io.Discard, r.Body)
+			select {
+			case <-r.Context().Done():
+			case <-time.After(30 * time.Second):
+			}
+			return
+		}
+		var req struct {
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		data := make([]map[string]interface{}, len(req.Input))
+		for i := range req.Input {
+			data[i] = map[string]interface{}{"index": i, "embedding": []float32{0.1, 0.2, 0.3}}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProcessDocumentSyncTimeoutHandsOverToAsyncTask(t *testing.T) {
+	startFakeRedis(t)
+	db := openTestDB(t)
+	migrateForSQLite(t, db, &models.FileRecord{}, &models.ProcessingLog{}, &models.ProcessingRun{},
+		&models.Task{}, &models.ProcessingSettings{}, &models.DocumentChunk{})
+
+	var slow atomic.Bool
+	slow.Store(true)
+	server := slowEmbeddingServer(t, &slow)
+
+	saved := config.AppConfig
+	cfg := &config.Config{}
+	cfg.Lock.TTL = 30 * time.Second
+	cfg.Embedding.BaseURL = server.URL
+	cfg.Embedding.Model = "test-embedding"
+	cfg.Processing.StoreMode = "noop"
+	cfg.Processing.ChunkInsertBatchSize = 100
+	config.AppConfig = cfg
+	t.Cleanup(func() { config.AppConfig = saved })
+
+	file := &models.FileRecord{
+		Filename: "doc.pdf",
+		Filepath: writeTestPDF(t, "Sync processing timeout test document."),
+		Status:   "pending",
+	}
+	if err := db.Create(file).Error; err != nil {
+		t.Fatalf("create file: %v", err)
+	}
+	fileID := file.ID.String()
+
+	// 向量化请求阻塞，超过时间预算后应立即取消，而不是等到请求自行结束
+	started := time.Now()
+	_, err := ProcessDocumentSync(fileID, 300*time.Millisecond)
+	if !errors.Is(err, ErrSyncTimeout) {
+		t.Fatalf("ProcessDocumentSync err = %v, want ErrSyncTimeout", err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Fatalf("ProcessDocumentSync returned after %s, embedding was not cancelled", elapsed)
+	}
+
+	// 返回时文件锁已释放，异步任务可以立即获取
+	lock, err := AcquireFileLock(context.Background(), fileID, 0)
+	if err != nil {
+		t.Fatalf("file lock still held after sync timeout: %v", err)
+	}
+	lock.Release()
+
+	// 与 ProcessFile 相同: 超时后标记为等待处理并提交异步任务
+	db.Model(&models.FileRecord{}).Where("id = ?", fileID).Updates(map[string]interface{}{
+		"status":   "pending",
+		"progress": 0,
+		"message":  "同步处理超时，已加入处理队列...",
+	})
+	time.Sleep(100 * time.Millisecond)
+	var record models.FileRecord
+	db.Where("id = ?", fileID).Take(&record)
+	if record.Status != "pending" {
+		t.Fatalf("sync processing changed the file to %q after returning", record.Status)
+	}
+
+	slow.Store(false)
+	payload, _ := json.Marshal(TaskPayload{FileID: fileID})
+	if err := HandleProcessDocument(context.Background(), asynq.NewTask(TaskProcessDocument, payload)); err != nil {
+		t.Fatalf("async task failed: %v", err)
+	}
+
+	db.Where("id = ?", fileID).Take(&record)
+	if record.Status != "completed" || record.Progress != 100 {
+		t.Fatalf("file ended as status=%q progress=%d message=%q, want completed", record.Status, record.Progress, record.Message)
+	}
+	// 超时的同步处理不写入处理记录，只有异步任务的一条
+	var runs int64
+	db.Model(&models.ProcessingRun{}).Where("file_id = ?", file.ID).Count(&runs)
+	if runs != 1 {
+		t.Fatalf("got %d processing runs, want 1", runs)
+	}
+}
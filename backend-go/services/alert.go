@@ -0,0 +1,110 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+
+	"doc-analysis-backend/config"
+)
+
+// 一封告警邮件中最多列出的告警条数，超出的部分只给出数量
+const maxAlertsPerMail = 100
+
+var alerts struct {
+	sync.Mutex
+	pending []string
+	dropped int
+	once    sync.Once
+}
+
+// AlertsEnabled 是否配置了邮件告警
+func AlertsEnabled() bool {
+	cfg := config.AppConfig.Alert
+	return cfg.SMTPHost != "" && len(cfg.To) > 0
+}
+
+// SendAlert 记录一条告警，告警不会立即发送，而是按 ALERT_BATCH_INTERVAL 合并成一封邮件。
+// 未配置邮件告警时直接忽略
+func SendAlert(format string, args ...interface{}) {
+	if !AlertsEnabled() {
+		return
+	}
+	message := fmt.Sprintf("[%s] %s", time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf(format, args...))
+	log.Printf("告警: %s", message)
+
+	alerts.Lock()
+	if len(alerts.pending) < maxAlertsPerMail {
+		alerts.pending = append(alerts.pending, message)
+	} else {
+		alerts.dropped++
+	}
+	alerts.Unlock()
+
+	// 第一次产生告警时才启动发送循环
+	alerts.once.Do(func() {
+		go func() {
+			ticker := time.NewTicker(config.AppConfig.Alert.BatchInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				flushAlerts()
+			}
+		}()
+	})
+}
+
+// flushAlerts 将积累的告警合并为一封邮件发送，发送失败时丢弃，只记录日志
+func flushAlerts() {
+	alerts.Lock()
+	pending, dropped := alerts.pending, alerts.dropped
+	alerts.pending, alerts.dropped = nil, 0
+	alerts.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	var body strings.Builder
+	for _, message := range pending {
+		body.WriteString(message)
+		body.WriteString("\r\n")
+	}
+	if dropped > 0 {
+		fmt.Fprintf(&body, "\r\n另有 %d 条告警未列出，请查看服务日志\r\n", dropped)
+	}
+
+	subject := fmt.Sprintf("文档处理告警: %d 条", len(pending)+dropped)
+	if err := sendMail(subject, body.String()); err != nil {
+		log.Printf("发送告警邮件失败: %v", err)
+	}
+}
+
+func sendMail(subject, body string) error {
+	cfg := config.AppConfig.Alert
+	from := cfg.From
+	if from == "" {
+		from = cfg.SMTPUsername
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(cfg.To, ", "))
+	// 主题包含中文，按 RFC 2047 编码
+	fmt.Fprintf(&msg, "Subject: =?UTF-8?B?%s?=\r\n", base64.StdEncoding.EncodeToString([]byte(subject)))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(body)
+
+	var auth smtp.Auth
+	if cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	// 服务器支持时 smtp.SendMail 会通过 STARTTLS 加密连接
+	return smtp.SendMail(net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort), auth, from, cfg.To, []byte(msg.String()))
+}
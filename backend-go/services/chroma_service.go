@@ -2,15 +2,72 @@ package services
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
-	"time"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"sync"
 
 	"doc-analysis-backend/config"
+	"doc-analysis-backend/metrics"
 )
 
+// ErrCollectionNotFound 集合不存在，通常是集合在外部被删除
+var ErrCollectionNotFound = errors.New("集合不存在")
+
+// ErrChromaUnavailable ChromaDB 无法连接、请求超时或返回网关错误，通常是服务暂时不可用
+var ErrChromaUnavailable = errors.New("ChromaDB 不可用")
+
+// CollectionName 返回存储文档向量的集合名称
+func CollectionName() string {
+	return config.AppConfig.ChromaDB.Collection
+}
+
+// FileCollection 返回文件向量所在的集合，name 为空时为默认集合
+func FileCollection(name string) string {
+	if name == "" {
+		return CollectionName()
+	}
+	return name
+}
+
+// ShardCollection 返回文件向量实际所在的集合，shard 为 0 时为集合本身，否则为集合名加 _N 后缀的分片集合
+func ShardCollection(name string, shard int) string {
+	if shard == 0 {
+		return FileCollection(name)
+	}
+	return fmt.Sprintf("%s_%d", FileCollection(name), shard)
+}
+
+// RouteCollection 按 CHROMA_COLLECTION_ROUTES 为元数据选择集合，未配置或没有匹配的取值时返回空（默认集合）
+func RouteCollection(metadata map[string]interface{}) string {
+	key := config.AppConfig.ChromaDB.RouteKey
+	if key == "" {
+		return ""
+	}
+	value, ok := metadata[key]
+	if !ok {
+		return ""
+	}
+	return RouteCollectionByValue(fmt.Sprint(value))
+}
+
+// RouteCollectionByValue 返回路由字段取值对应的集合，没有对应的集合或对应默认集合时返回空
+func RouteCollectionByValue(value string) string {
+	collection := config.AppConfig.ChromaDB.Routes[value]
+	if collection == CollectionName() {
+		return ""
+	}
+	return collection
+}
+
 type ChromaClient struct {
 	BaseURL    string
 	HTTPClient *http.Client
@@ -20,6 +77,8 @@ type ChromaCollection struct {
 	Name     string                 `json:"name"`
 	ID       string                 `json:"id,omitempty"`
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	// 写入第一批向量后由 Chroma 确定，此前为空
+	Dimension *int `json:"dimension,omitempty"`
 }
 
 type ChromaAddRequest struct {
@@ -30,10 +89,11 @@ type ChromaAddRequest struct {
 }
 
 type ChromaQueryRequest struct {
-	QueryTexts  []string `json:"query_texts"`
-	NResults    int      `json:"n_results"`
-	Where       map[string]interface{} `json:"where,omitempty"`
-	Include     []string `json:"include,omitempty"`
+	QueryTexts      []string               `json:"query_texts,omitempty"`
+	QueryEmbeddings [][]float32            `json:"query_embeddings,omitempty"`
+	NResults        int                    `json:"n_results"`
+	Where           map[string]interface{} `json:"where,omitempty"`
+	Include         []string               `json:"include,omitempty"`
 }
 
 type ChromaQueryResponse struct {
@@ -43,135 +103,510 @@ type ChromaQueryResponse struct {
 	Metadatas [][]map[string]interface{} `json:"metadatas"`
 }
 
-func NewChromaClient() *ChromaClient {
+type ChromaGetRequest struct {
+	IDs     []string               `json:"ids,omitempty"`
+	Where   map[string]interface{} `json:"where,omitempty"`
+	Include []string               `json:"include,omitempty"`
+	Limit   int                    `json:"limit,omitempty"`
+	Offset  int                    `json:"offset,omitempty"`
+}
+
+type ChromaGetResponse struct {
+	IDs        []string                 `json:"ids"`
+	Documents  []string                 `json:"documents"`
+	Embeddings [][]float32              `json:"embeddings"`
+	Metadatas  []map[string]interface{} `json:"metadatas"`
+}
+
+// sharedChromaClient 所有调用方共用的客户端。默认 Transport 每个地址只保留 2 个空闲连接，
+// 并发写入多个批次时连接会被频繁关闭重建，这里按 CHROMA_MAX_IDLE_CONNS_PER_HOST 等配置调整连接池
+var sharedChromaClient = sync.OnceValue(func() *ChromaClient {
 	cfg := config.AppConfig.ChromaDB
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = max(transport.MaxIdleConns, cfg.MaxIdleConnsPerHost)
+	transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	transport.MaxConnsPerHost = cfg.MaxConnsPerHost
+	transport.IdleConnTimeout = cfg.IdleConnTimeout
+	transport.DisableKeepAlives = !cfg.KeepAlive
+	if cfg.TLSSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
 	return &ChromaClient{
-		BaseURL: fmt.Sprintf("http://%s:%s", cfg.Host, cfg.Port),
+		BaseURL: cfg.URL,
 		HTTPClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   cfg.Timeout,
+			Transport: transport,
 		},
 	}
+})
+
+// NewChromaClient 返回共用的客户端，各处调用不会各自创建连接池
+func NewChromaClient() *ChromaClient {
+	return sharedChromaClient()
 }
 
-func (c *ChromaClient) CreateCollection(name string) error {
-	collection := ChromaCollection{
-		Name: name,
-		Metadata: map[string]interface{}{
-			"description": "文档向量存储集合",
+// doJSON 发送带 context 的请求，body 不为 nil 时序列化为 JSON 请求体。
+// context 取消或超时时会中断进行中的请求
+func (c *ChromaClient) doJSON(ctx context.Context, method, url string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("序列化请求失败: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	// 记录每个请求是否复用了已有连接，通过 doc_chroma_connections_total 观察连接池是否生效
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			metrics.ChromaConnections.WithLabelValues(strconv.FormatBool(info.Reused)).Inc()
 		},
+	})
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
-	
-	data, err := json.Marshal(collection)
+
+	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("序列化请求失败: %w", err)
-	}
-	
-	resp, err := c.HTTPClient.Post(
-		c.BaseURL+"/api/v1/collections",
-		"application/json",
-		bytes.NewBuffer(data),
-	)
+		// 调用方取消的请求不代表服务不可用
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("请求失败: %w", err)
+		}
+		return nil, fmt.Errorf("请求失败: %w: %w", ErrChromaUnavailable, err)
+	}
+	return resp, nil
+}
+
+// TenantIDKey 块元数据中记录所属租户的键，检索时按该字段过滤；上传时的自定义元数据不能使用该键
+const TenantIDKey = "tenant_id"
+
+// 集合元数据的结构版本，系统写入的元数据字段新增或含义变化时递增，用于识别旧版本创建的集合
+const CollectionSchemaVersion = 1
+
+const (
+	collectionSchemaKey    = "schema_version"
+	collectionDimensionKey = "embedding_dimension"
+)
+
+// CreateCollection 创建集合，集合已存在时检查其配置，不会修改已有集合的元数据
+func (c *ChromaClient) CreateCollection(ctx context.Context, name string) error {
+	metric := config.AppConfig.ChromaDB.DistanceMetric
+	if !isValidDistanceMetric(metric) {
+		return fmt.Errorf("不支持的距离度量: %s (可选 cosine/l2/ip)", metric)
+	}
+
+	collection := ChromaCollection{
+		Name:     name,
+		Metadata: collectionMetadata(metric),
+	}
+
+	resp, err := c.doJSON(ctx, http.MethodPost, c.BaseURL+"/api/v1/collections", collection)
 	if err != nil {
-		return fmt.Errorf("请求失败: %w", err)
+		return err
 	}
 	defer resp.Body.Close()
-	
+
 	if resp.StatusCode == http.StatusConflict {
-		// 集合已存在，这是正常的
+		// 集合已存在，这是正常的；但距离度量和向量维度创建后无法修改，需要检查是否与配置一致
+		existing, err := c.GetCollection(ctx, name)
+		if err != nil {
+			return fmt.Errorf("获取已有集合 %s 信息失败: %w", name, err)
+		}
+		if err := verifyCollection(existing, metric, config.AppConfig.Embedding.Dimension); err != nil {
+			return err
+		}
+		warnCollectionMetadata(existing)
 		return nil
 	}
-	
+
 	if resp.StatusCode != http.StatusCreated {
 		return fmt.Errorf("创建集合失败，状态码: %d", resp.StatusCode)
 	}
-	
+
 	return nil
 }
 
-func (c *ChromaClient) AddDocuments(collectionName string, req *ChromaAddRequest) error {
-	data, err := json.Marshal(req)
+// GetCollection 获取集合信息（包含创建时写入的元数据）
+func (c *ChromaClient) GetCollection(ctx context.Context, name string) (*ChromaCollection, error) {
+	resp, err := c.doJSON(ctx, http.MethodGet, fmt.Sprintf("%s/api/v1/collections/%s", c.BaseURL, name), nil)
 	if err != nil {
-		return fmt.Errorf("序列化请求失败: %w", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("获取集合失败，状态码: %d", resp.StatusCode)
+	}
+
+	var collection ChromaCollection
+	if err := json.NewDecoder(resp.Body).Decode(&collection); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
 	}
-	
+
+	return &collection, nil
+}
+
+func (c *ChromaClient) AddDocuments(ctx context.Context, collectionName string, req *ChromaAddRequest) error {
+	return c.withCollection(ctx, collectionName, func() error {
+		return c.addDocuments(ctx, collectionName, req)
+	})
+}
+
+func (c *ChromaClient) addDocuments(ctx context.Context, collectionName string, req *ChromaAddRequest) error {
 	url := fmt.Sprintf("%s/api/v1/collections/%s/add", c.BaseURL, collectionName)
-	resp, err := c.HTTPClient.Post(url, "application/json", bytes.NewBuffer(data))
+	resp, err := c.doJSON(ctx, http.MethodPost, url, req)
 	if err != nil {
-		return fmt.Errorf("请求失败: %w", err)
+		return err
 	}
 	defer resp.Body.Close()
-	
+
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		if isCollectionNotFound(resp) {
+			return fmt.Errorf("添加文档失败，集合 %s: %w", collectionName, ErrCollectionNotFound)
+		}
 		return fmt.Errorf("添加文档失败，状态码: %d", resp.StatusCode)
 	}
-	
+
 	return nil
 }
 
-func (c *ChromaClient) QueryDocuments(collectionName string, req *ChromaQueryRequest) (*ChromaQueryResponse, error) {
-	if req.Include == nil {
-		req.Include = []string{"documents", "distances", "metadatas"}
+// UpsertDocuments 写入文档，ID 已存在时覆盖原有的向量、文本和元数据
+func (c *ChromaClient) UpsertDocuments(ctx context.Context, collectionName string, req *ChromaAddRequest) error {
+	return c.withCollection(ctx, collectionName, func() error {
+		return c.upsertDocuments(ctx, collectionName, req)
+	})
+}
+
+// withCollection 执行写入，集合不存在（未初始化或在外部被删除）且开启了 CHROMA_AUTO_CREATE_COLLECTION 时，
+// 按配置的距离度量和集合元数据创建集合后重试一次
+func (c *ChromaClient) withCollection(ctx context.Context, collectionName string, write func() error) error {
+	err := write()
+	if !errors.Is(err, ErrCollectionNotFound) || !config.AppConfig.ChromaDB.AutoCreateCollection {
+		return err
 	}
-	
-	data, err := json.Marshal(req)
+	log.Printf("写入向量时集合 %s 不存在，自动创建", collectionName)
+	if err := c.CreateCollection(ctx, collectionName); err != nil {
+		return fmt.Errorf("自动创建集合 %s 失败: %w", collectionName, err)
+	}
+	return write()
+}
+
+func (c *ChromaClient) upsertDocuments(ctx context.Context, collectionName string, req *ChromaAddRequest) error {
+	url := fmt.Sprintf("%s/api/v1/collections/%s/upsert", c.BaseURL, collectionName)
+	resp, err := c.doJSON(ctx, http.MethodPost, url, req)
 	if err != nil {
-		return nil, fmt.Errorf("序列化请求失败: %w", err)
+		return err
 	}
-	
-	url := fmt.Sprintf("%s/api/v1/collections/%s/query", c.BaseURL, collectionName)
-	resp, err := c.HTTPClient.Post(url, "application/json", bytes.NewBuffer(data))
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		if isCollectionNotFound(resp) {
+			return fmt.Errorf("写入文档失败，集合 %s: %w", collectionName, ErrCollectionNotFound)
+		}
+		return fmt.Errorf("写入文档失败，状态码: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// UpdateMetadatas 更新已有记录的元数据，不修改向量和文本。
+// Chroma 按字段合并元数据，未传入的字段保持不变，值为 null 的字段会被删除
+func (c *ChromaClient) UpdateMetadatas(ctx context.Context, collectionName string, ids []string, metadatas []map[string]interface{}) error {
+	url := fmt.Sprintf("%s/api/v1/collections/%s/update", c.BaseURL, collectionName)
+	resp, err := c.doJSON(ctx, http.MethodPost, url, map[string]interface{}{
+		"ids":       ids,
+		"metadatas": metadatas,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("请求失败: %w", err)
+		return err
 	}
 	defer resp.Body.Close()
-	
+
 	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("更新元数据失败，状态码: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (c *ChromaClient) QueryDocuments(ctx context.Context, collectionName string, req *ChromaQueryRequest) (*ChromaQueryResponse, error) {
+	if req.Include == nil {
+		req.Include = []string{"documents", "distances", "metadatas"}
+	}
+
+	url := fmt.Sprintf("%s/api/v1/collections/%s/query", c.BaseURL, collectionName)
+	resp, err := c.doJSON(ctx, http.MethodPost, url, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return nil, fmt.Errorf("查询失败，状态码: %d: %w", resp.StatusCode, ErrChromaUnavailable)
+	default:
 		return nil, fmt.Errorf("查询失败，状态码: %d", resp.StatusCode)
 	}
-	
+
 	var result ChromaQueryResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, fmt.Errorf("解析响应失败: %w", err)
 	}
-	
+
 	return &result, nil
 }
 
-func (c *ChromaClient) DeleteDocuments(collectionName string, ids []string) error {
-	reqData := map[string]interface{}{
-		"ids": ids,
-	}
-	
-	data, err := json.Marshal(reqData)
+// GetDocuments 按 ID 或元数据条件获取集合中的记录
+func (c *ChromaClient) GetDocuments(ctx context.Context, collectionName string, req *ChromaGetRequest) (*ChromaGetResponse, error) {
+	url := fmt.Sprintf("%s/api/v1/collections/%s/get", c.BaseURL, collectionName)
+	resp, err := c.doJSON(ctx, http.MethodPost, url, req)
 	if err != nil {
-		return fmt.Errorf("序列化请求失败: %w", err)
+		return nil, err
 	}
-	
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("获取文档失败，状态码: %d", resp.StatusCode)
+	}
+
+	var result ChromaGetResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (c *ChromaClient) DeleteDocuments(ctx context.Context, collectionName string, ids []string) error {
 	url := fmt.Sprintf("%s/api/v1/collections/%s/delete", c.BaseURL, collectionName)
-	req, err := http.NewRequest("DELETE", url, bytes.NewBuffer(data))
+	resp, err := c.doJSON(ctx, http.MethodDelete, url, map[string]interface{}{"ids": ids})
 	if err != nil {
-		return fmt.Errorf("创建请求失败: %w", err)
+		return err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	
-	resp, err := c.HTTPClient.Do(req)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("删除文档失败，状态码: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// CountDocuments 返回集合中的向量总数
+func (c *ChromaClient) CountDocuments(ctx context.Context, collectionName string) (int, error) {
+	url := fmt.Sprintf("%s/api/v1/collections/%s/count", c.BaseURL, collectionName)
+	resp, err := c.doJSON(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return fmt.Errorf("请求失败: %w", err)
+		return 0, err
 	}
 	defer resp.Body.Close()
-	
+
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("删除文档失败，状态码: %d", resp.StatusCode)
+		if isCollectionNotFound(resp) {
+			return 0, fmt.Errorf("统计向量数量失败，集合 %s: %w", collectionName, ErrCollectionNotFound)
+		}
+		return 0, fmt.Errorf("统计向量数量失败，状态码: %d", resp.StatusCode)
+	}
+
+	var count int
+	if err := json.NewDecoder(resp.Body).Decode(&count); err != nil {
+		return 0, fmt.Errorf("解析响应失败: %w", err)
+	}
+	return count, nil
+}
+
+// 统计向量数量时每页读取的记录数
+const countPageSize = 1000
+
+// CountDocumentsByFileID 分页读取某个文件的记录，返回其向量数量
+func (c *ChromaClient) CountDocumentsByFileID(ctx context.Context, collectionName string, fileID string) (int, error) {
+	count := 0
+	for offset := 0; ; offset += countPageSize {
+		result, err := c.GetDocuments(ctx, collectionName, &ChromaGetRequest{
+			Where:   map[string]interface{}{"file_id": fileID},
+			Include: []string{"metadatas"},
+			Limit:   countPageSize,
+			Offset:  offset,
+		})
+		if err != nil {
+			return 0, err
+		}
+		count += len(result.IDs)
+		if len(result.IDs) < countPageSize {
+			return count, nil
+		}
+	}
+}
+
+// DeleteDocumentsByFileID 按 file_id 元数据删除某个文件的全部向量
+func (c *ChromaClient) DeleteDocumentsByFileID(ctx context.Context, collectionName string, fileID string) error {
+	reqData := map[string]interface{}{
+		"where": map[string]interface{}{
+			"file_id": fileID,
+		},
+	}
+
+	url := fmt.Sprintf("%s/api/v1/collections/%s/delete", c.BaseURL, collectionName)
+	resp, err := c.doJSON(ctx, http.MethodPost, url, reqData)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("删除文件向量失败，状态码: %d", resp.StatusCode)
 	}
-	
+
 	return nil
 }
 
-func InitChromaDB() error {
+func InitChromaDB(ctx context.Context) error {
 	client := NewChromaClient()
-	if err := client.CreateCollection("documents"); err != nil {
+	if config.AppConfig.ChromaDB.TLSSkipVerify {
+		log.Println("警告: 已关闭 ChromaDB 的 TLS 证书校验 (CHROMA_TLS_SKIP_VERIFY=true)")
+	}
+	if err := client.CreateCollection(ctx, CollectionName()); err != nil {
 		return fmt.Errorf("初始化ChromaDB失败: %w", err)
 	}
+	for value, collection := range config.AppConfig.ChromaDB.Routes {
+		if err := client.CreateCollection(ctx, collection); err != nil {
+			return fmt.Errorf("初始化集合 %s 失败: %w", collection, err)
+		}
+		log.Printf("%s=%s 的文件写入集合 %s", config.AppConfig.ChromaDB.RouteKey, value, collection)
+	}
 	log.Println("ChromaDB初始化成功")
 	return nil
-}
\ No newline at end of file
+}
+
+// isCollectionNotFound 判断失败响应是否因为集合不存在。
+// 不同版本的 Chroma 返回 404 或 500，后者只能通过错误信息识别
+func isCollectionNotFound(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusNotFound {
+		return true
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return strings.Contains(string(body), "does not exist")
+}
+
+func isValidDistanceMetric(metric string) bool {
+	switch metric {
+	case "cosine", "l2", "ip":
+		return true
+	}
+	return false
+}
+
+// DistanceToScore 按 CHROMA_DISTANCE 将 Chroma 返回的距离转换为 [0, 1] 的相关度，1 表示最相似。
+// cosine 和 ip 的距离为 1 - 相似度，取值 [0, 2]，转换为 1 - distance/2（ip 只对归一化向量有意义，超出范围时截断）；
+// l2 的距离没有上限，转换为 1/(1+distance)
+func DistanceToScore(distance float64) float64 {
+	var score float64
+	switch config.AppConfig.ChromaDB.DistanceMetric {
+	case "cosine", "ip":
+		score = 1 - distance/2
+	default:
+		score = 1 / (1 + distance)
+	}
+	return min(max(score, 0), 1)
+}
+
+// verifyCollection 检查已有集合的距离度量和向量维度是否与配置一致。
+// 不一致时继续写入会导致检索结果错误，所以直接报错而不是沿用已有集合。
+func verifyCollection(collection *ChromaCollection, metric string, dimension int) error {
+	if existingMetric := collectionDistanceMetric(collection); existingMetric != metric {
+		return fmt.Errorf("集合 %s 的距离度量为 %s，与配置的 CHROMA_DISTANCE=%s 不一致，请重置该集合或通过 CHROMA_COLLECTION 使用新的集合名称",
+			collection.Name, existingMetric, metric)
+	}
+
+	if dimension <= 0 {
+		return nil
+	}
+	if existingDimension := collectionDimension(collection); existingDimension > 0 && existingDimension != dimension {
+		return fmt.Errorf("集合 %s 的向量维度为 %d，与配置的 EMBEDDING_DIMENSION=%d 不一致，请重置该集合或通过 CHROMA_COLLECTION 使用新的集合名称",
+			collection.Name, existingDimension, dimension)
+	}
+	return nil
+}
+
+// collectionMetadata 创建集合时写入的元数据: 默认描述、CHROMA_COLLECTION_METADATA，
+// 以及距离度量、向量化模型、维度和结构版本。后几项是系统字段，初始化和一致性检查会读取，不能被自定义字段覆盖
+func collectionMetadata(metric string) map[string]interface{} {
+	metadata := map[string]interface{}{"description": "文档向量存储集合"}
+	for key, value := range config.AppConfig.ChromaDB.CollectionMetadata {
+		metadata[key] = value
+	}
+
+	metadata["hnsw:space"] = metric
+	metadata[EmbeddingModelKey] = config.AppConfig.Embedding.Model
+	metadata[collectionSchemaKey] = CollectionSchemaVersion
+	if dimension := config.AppConfig.Embedding.Dimension; dimension > 0 {
+		metadata[collectionDimensionKey] = dimension
+	}
+	return metadata
+}
+
+// warnCollectionMetadata 已有集合由旧版本创建或创建时的模型与当前配置不同时记录日志。
+// 两者都不影响继续使用，过期的向量由一致性检查按块识别
+func warnCollectionMetadata(collection *ChromaCollection) {
+	info := DescribeCollection(collection)
+	if info.Outdated {
+		log.Printf("集合 %s 的元数据结构版本为 %d，低于当前的 %d，缺少的系统字段无法用于校验", info.Name, info.SchemaVersion, CollectionSchemaVersion)
+	}
+	if info.EmbeddingModel != "" && IsStaleEmbeddingModel(info.EmbeddingModel) {
+		log.Printf("集合 %s 创建时使用的向量化模型为 %s，与当前的 %s 不同", info.Name, info.EmbeddingModel, config.AppConfig.Embedding.Model)
+	}
+}
+
+// CollectionInfo 从集合元数据中读取的系统字段
+type CollectionInfo struct {
+	Name string `json:"name"`
+	// 0 表示集合在记录结构版本之前创建
+	SchemaVersion  int                    `json:"schema_version"`
+	Outdated       bool                   `json:"outdated"`
+	DistanceMetric string                 `json:"distance_metric"`
+	EmbeddingModel string                 `json:"embedding_model,omitempty"`
+	Dimension      int                    `json:"dimension,omitempty"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+}
+
+func DescribeCollection(collection *ChromaCollection) CollectionInfo {
+	info := CollectionInfo{
+		Name:           collection.Name,
+		DistanceMetric: collectionDistanceMetric(collection),
+		Dimension:      collectionDimension(collection),
+		Metadata:       collection.Metadata,
+	}
+	// JSON 中的数字解析为 float64
+	if version, ok := collection.Metadata[collectionSchemaKey].(float64); ok {
+		info.SchemaVersion = int(version)
+	}
+	info.Outdated = info.SchemaVersion < CollectionSchemaVersion
+	info.EmbeddingModel, _ = collection.Metadata[EmbeddingModelKey].(string)
+	return info
+}
+
+// collectionDimension 返回集合的向量维度，优先使用 Chroma 记录的实际维度，其次是创建时写入的元数据，未知时返回 0
+func collectionDimension(collection *ChromaCollection) int {
+	if collection.Dimension != nil {
+		return *collection.Dimension
+	}
+	if dimension, ok := collection.Metadata[collectionDimensionKey].(float64); ok {
+		return int(dimension)
+	}
+	return 0
+}
+
+// collectionDistanceMetric 返回集合使用的距离度量，未设置时为 Chroma 默认的 l2
+func collectionDistanceMetric(collection *ChromaCollection) string {
+	if metric, ok := collection.Metadata["hnsw:space"].(string); ok && metric != "" {
+		return metric
+	}
+	return "l2"
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"strings"
+)
+
+// Chunk 文档分块
+type Chunk struct {
+	Index      int    `json:"chunk_index"`
+	Content    string `json:"content"`
+	PageNumber int    `json:"page_number"`
+}
+
+// 分块策略
+const (
+	ChunkStrategyFixed     = "fixed"     // 按固定字符数切分
+	ChunkStrategySentence  = "sentence"  // 按句子边界切分后合并
+	ChunkStrategyRecursive = "recursive" // 依次尝试段落、换行、句子、空格分隔符切分后合并
+)
+
+// span 表示文档字符序列中的一段 [start, end)
+type span struct {
+	start, end int
+}
+
+// splitFunc 将字符序列切分为若干连续的片段，每个片段不超过 chunkSize，并按 overlap 处理重叠
+type splitFunc func(runes []rune, chunkSize, overlap int) []span
+
+var chunkStrategies = map[string]splitFunc{
+	ChunkStrategyFixed:     splitFixed,
+	ChunkStrategySentence:  splitSentences,
+	ChunkStrategyRecursive: splitRecursive,
+}
+
+// IsValidChunkStrategy 判断分块策略是否受支持
+func IsValidChunkStrategy(strategy string) bool {
+	_, ok := chunkStrategies[strategy]
+	return ok
+}
+
+// ChunkDocument 按指定策略对文档分块，相邻块之间保留约 overlap 个字符的重叠。
+// 未知策略按 fixed 处理。
+func ChunkDocument(doc *ParsedDocument, strategy string, chunkSize, overlap int) []Chunk {
+	if chunkSize <= 0 {
+		chunkSize = 1000
+	}
+	if overlap < 0 || overlap >= chunkSize {
+		overlap = 0
+	}
+	split, ok := chunkStrategies[strategy]
+	if !ok {
+		split = splitFixed
+	}
+
+	// 将所有页面拼接成一个字符序列，同时记录每一页的起始位置
+	var runes []rune
+	pageStarts := make([]int, 0, len(doc.Pages))
+	for _, page := range doc.Pages {
+		pageStarts = append(pageStarts, len(runes))
+		runes = append(runes, []rune(page.Text)...)
+		runes = append(runes, '\n')
+	}
+
+	var chunks []Chunk
+	for _, s := range split(runes, chunkSize, overlap) {
+		content := strings.TrimSpace(string(runes[s.start:s.end]))
+		if content == "" {
+			continue
+		}
+		chunks = append(chunks, Chunk{
+			Index:      len(chunks),
+			Content:    content,
+			PageNumber: pageNumberAt(doc, pageStarts, s.start),
+		})
+	}
+
+	return chunks
+}
+
+// splitFixed 按固定字符数切分，每次前进 chunkSize-overlap 个字符
+func splitFixed(runes []rune, chunkSize, overlap int) []span {
+	var spans []span
+	step := chunkSize - overlap
+	for start := 0; start < len(runes); start += step {
+		end := start + chunkSize
+		if end > len(runes) {
+			end = len(runes)
+		}
+		spans = append(spans, span{start, end})
+		if end == len(runes) {
+			break
+		}
+	}
+	return spans
+}
+
+// splitSentences 按句子边界切分，再将句子合并为不超过 chunkSize 的块，重叠部分以整句为单位
+func splitSentences(runes []rune, chunkSize, overlap int) []span {
+	var segments []span
+	for _, s := range splitOnSentences(runes, span{0, len(runes)}) {
+		segments = append(segments, splitOversizedSpan(s, chunkSize)...)
+	}
+	return mergeSpans(segments, chunkSize, overlap)
+}
+
+// 递归切分依次尝试的分隔符，nil 表示句子边界
+var recursiveSeparators = [][]rune{
+	[]rune("\n\n"),
+	[]rune("\n"),
+	nil,
+	[]rune(" "),
+}
+
+// splitRecursive 类似 LangChain 的 RecursiveCharacterTextSplitter: 优先按段落切分，
+// 片段仍超过 chunkSize 时依次改用换行、句子、空格，最后按字符切分，再将片段合并为块
+func splitRecursive(runes []rune, chunkSize, overlap int) []span {
+	segments := splitRecursiveSpan(runes, span{0, len(runes)}, chunkSize, 0)
+	return mergeSpans(segments, chunkSize, overlap)
+}
+
+func splitRecursiveSpan(runes []rune, s span, chunkSize, level int) []span {
+	if s.end-s.start <= chunkSize {
+		return []span{s}
+	}
+	if level >= len(recursiveSeparators) {
+		return splitOversizedSpan(s, chunkSize)
+	}
+
+	var parts []span
+	if sep := recursiveSeparators[level]; sep == nil {
+		parts = splitOnSentences(runes, s)
+	} else {
+		parts = splitOnSeparator(runes, s, sep)
+	}
+
+	var segments []span
+	for _, part := range parts {
+		segments = append(segments, splitRecursiveSpan(runes, part, chunkSize, level+1)...)
+	}
+	return segments
+}
+
+// splitOnSeparator 在分隔符之后切分，分隔符保留在前一个片段末尾，保证片段首尾相连
+func splitOnSeparator(runes []rune, s span, sep []rune) []span {
+	var parts []span
+	start := s.start
+	for i := s.start; i+len(sep) <= s.end; i++ {
+		if !hasRunePrefix(runes[i:s.end], sep) {
+			continue
+		}
+		end := i + len(sep)
+		parts = append(parts, span{start, end})
+		start = end
+		i = end - 1
+	}
+	if start < s.end {
+		parts = append(parts, span{start, s.end})
+	}
+	return parts
+}
+
+// splitOnSentences 在句末标点（及其后的空白）之后切分
+func splitOnSentences(runes []rune, s span) []span {
+	var parts []span
+	start := s.start
+	for i := s.start; i < s.end; i++ {
+		if !isSentenceEnd(runes, i, s.end) {
+			continue
+		}
+		end := i + 1
+		for end < s.end && isSpace(runes[end]) {
+			end++
+		}
+		parts = append(parts, span{start, end})
+		start = end
+		i = end - 1
+	}
+	if start < s.end {
+		parts = append(parts, span{start, s.end})
+	}
+	return parts
+}
+
+func isSentenceEnd(runes []rune, i, end int) bool {
+	switch runes[i] {
+	case '。', '！', '？', '；', '!', '?', ';', '\n':
+		return true
+	case '.':
+		// 英文句号后需跟空白或位于末尾，避免切开小数和缩写
+		return i+1 >= end || isSpace(runes[i+1])
+	}
+	return false
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+func hasRunePrefix(runes, prefix []rune) bool {
+	if len(runes) < len(prefix) {
+		return false
+	}
+	for i, r := range prefix {
+		if runes[i] != r {
+			return false
+		}
+	}
+	return true
+}
+
+// splitOversizedSpan 将超过 chunkSize 的片段按固定字符数切开
+func splitOversizedSpan(s span, chunkSize int) []span {
+	var parts []span
+	for start := s.start; start < s.end; start += chunkSize {
+		end := start + chunkSize
+		if end > s.end {
+			end = s.end
+		}
+		parts = append(parts, span{start, end})
+	}
+	return parts
+}
+
+// mergeSpans 将首尾相连的片段贪心地合并为不超过 chunkSize 的块。
+// 下一个块从上一个块末尾的若干片段开始，这些片段总长不超过 overlap，从而以完整片段为单位保留重叠。
+func mergeSpans(segments []span, chunkSize, overlap int) []span {
+	var merged []span
+	for i := 0; i < len(segments); {
+		j := i + 1
+		for j < len(segments) && segments[j].end-segments[i].start <= chunkSize {
+			j++
+		}
+		last := segments[j-1]
+		merged = append(merged, span{segments[i].start, last.end})
+		if j >= len(segments) {
+			break
+		}
+
+		// 向前回退若干片段作为重叠，同时保证下一个块仍能容纳新的片段
+		k := j
+		for k-1 > i &&
+			last.end-segments[k-1].start <= overlap &&
+			segments[j].end-segments[k-1].start <= chunkSize {
+			k--
+		}
+		i = k
+	}
+	return merged
+}
+
+// pageNumberAt 返回字符偏移量 offset 所在的页码
+func pageNumberAt(doc *ParsedDocument, pageStarts []int, offset int) int {
+	pageNumber := 0
+	for i, start := range pageStarts {
+		if start > offset {
+			break
+		}
+		pageNumber = doc.Pages[i].Number
+	}
+	return pageNumber
+}
+
+// SplitOversizedChunks 将估算 token 数超过 maxTokens 的块按字符对半拆分，
+// 保证每个块都能被向量化模型完整接收
+func SplitOversizedChunks(chunks []Chunk, maxTokens int) []Chunk {
+	if maxTokens <= 0 {
+		return chunks
+	}
+
+	var result []Chunk
+	var split func(chunk Chunk)
+	split = func(chunk Chunk) {
+		runes := []rune(chunk.Content)
+		if EstimateTokens(chunk.Content) <= maxTokens || len(runes) < 2 {
+			chunk.Index = len(result)
+			result = append(result, chunk)
+			return
+		}
+
+		mid := len(runes) / 2
+		left, right := chunk, chunk
+		left.Content = strings.TrimSpace(string(runes[:mid]))
+		right.Content = strings.TrimSpace(string(runes[mid:]))
+		split(left)
+		split(right)
+	}
+
+	for _, chunk := range chunks {
+		split(chunk)
+	}
+	return result
+}
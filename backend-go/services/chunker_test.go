@@ -0,0 +1,166 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+// 两个段落，每段三句，句子长度不同，用于对比三种策略的切分位置
+const chunkSampleText = "第一段的第一句话。第一段的第二句稍微长一些，包含逗号。第一段结束！\n\n" +
+	"Second paragraph starts here. It has a decimal 3.14 inside. The end?\n\n" +
+	"第三段只有一句话；但是带有分号。最后一句"
+
+func chunkSpans(t *testing.T, strategy string, chunkSize, overlap int) ([]rune, []span) {
+	t.Helper()
+	runes := []rune(chunkSampleText)
+	return runes, chunkStrategies[strategy](runes, chunkSize, overlap)
+}
+
+// checkSpans 检查所有策略共同的约束: 覆盖整个文本、块不超过 chunkSize、相邻块的重叠不超过 overlap
+func checkSpans(t *testing.T, runes []rune, spans []span, chunkSize, overlap int) {
+	t.Helper()
+	if len(spans) == 0 {
+		t.Fatal("no spans")
+	}
+	if spans[0].start != 0 || spans[len(spans)-1].end != len(runes) {
+		t.Fatalf("spans do not cover the text: first %v, last %v, len %d", spans[0], spans[len(spans)-1], len(runes))
+	}
+	for i, s := range spans {
+		if s.end <= s.start || s.end-s.start > chunkSize {
+			t.Fatalf("span %d %v: length %d exceeds chunk size %d", i, s, s.end-s.start, chunkSize)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := spans[i-1]
+		if s.start > prev.end {
+			t.Fatalf("gap between span %d %v and %v", i, prev, s)
+		}
+		if s.start <= prev.start {
+			t.Fatalf("span %d %v does not advance past %v", i, s, prev)
+		}
+		if got := prev.end - s.start; got > overlap {
+			t.Fatalf("span %d overlaps previous by %d, limit %d", i, got, overlap)
+		}
+	}
+}
+
+func TestChunkStrategiesInvariants(t *testing.T) {
+	for _, strategy := range []string{ChunkStrategyFixed, ChunkStrategySentence, ChunkStrategyRecursive} {
+		for _, tc := range []struct{ size, overlap int }{{40, 0}, {40, 10}, {80, 20}, {15, 5}} {
+			runes, spans := chunkSpans(t, strategy, tc.size, tc.overlap)
+			t.Run(strategy, func(t *testing.T) {
+				checkSpans(t, runes, spans, tc.size, tc.overlap)
+			})
+		}
+	}
+}
+
+func TestSplitFixedOverlap(t *testing.T) {
+	runes := []rune(strings.Repeat("a", 25))
+	got := splitFixed(runes, 10, 3)
+	want := []span{{0, 10}, {7, 17}, {14, 24}, {21, 25}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+// 固定长度切分会切开句子，句子策略的块总是在句末结束
+func TestSentenceStrategyEndsOnSentenceBoundaries(t *testing.T) {
+	fixedRunes, fixed := chunkSpans(t, ChunkStrategyFixed, 40, 0)
+	cutsMidSentence := false
+	for _, s := range fixed[:len(fixed)-1] {
+		if !endsSentence(fixedRunes, s) {
+			cutsMidSentence = true
+		}
+	}
+	if !cutsMidSentence {
+		t.Fatal("expected fixed strategy to cut inside a sentence on the sample text")
+	}
+
+	runes, spans := chunkSpans(t, ChunkStrategySentence, 40, 0)
+	for i, s := range spans[:len(spans)-1] {
+		if !endsSentence(runes, s) {
+			t.Fatalf("sentence chunk %d %q does not end on a sentence boundary", i, string(runes[s.start:s.end]))
+		}
+	}
+	// 小数点不是句子边界
+	for _, s := range spans {
+		if strings.HasSuffix(strings.TrimSpace(string(runes[s.start:s.end])), "3.") {
+			t.Fatalf("sentence strategy split the decimal 3.14: %q", string(runes[s.start:s.end]))
+		}
+	}
+}
+
+// 句子策略的重叠以整句为单位: 下一个块从上一个块中某个句子的开头开始
+func TestSentenceStrategyOverlapIsWholeSentences(t *testing.T) {
+	runes, spans := chunkSpans(t, ChunkStrategySentence, 60, 30)
+	overlapped := false
+	for i := 1; i < len(spans); i++ {
+		prev, s := spans[i-1], spans[i]
+		if s.start >= prev.end {
+			continue
+		}
+		overlapped = true
+		if !endsSentence(runes, span{0, s.start}) {
+			t.Fatalf("chunk %d starts mid-sentence: %q", i, string(runes[s.start:s.end]))
+		}
+	}
+	if !overlapped {
+		t.Fatal("expected at least one overlapping chunk")
+	}
+}
+
+// 递归策略在段落能放进一个块时按段落切分，不与相邻段落合并时块以空行结束
+func TestRecursiveStrategyKeepsParagraphs(t *testing.T) {
+	runes, spans := chunkSpans(t, ChunkStrategyRecursive, 80, 0)
+	var texts []string
+	for _, s := range spans {
+		texts = append(texts, string(runes[s.start:s.end]))
+	}
+	paragraphs := strings.SplitAfter(chunkSampleText, "\n\n")
+	if len(texts) != len(paragraphs) {
+		t.Fatalf("got %d chunks %q, want one per paragraph %q", len(texts), texts, paragraphs)
+	}
+	for i := range paragraphs {
+		if texts[i] != paragraphs[i] {
+			t.Fatalf("chunk %d = %q, want %q", i, texts[i], paragraphs[i])
+		}
+	}
+
+	// 段落超过 chunkSize 时退回到句子边界，重叠同样以完整片段为单位
+	runes, spans = chunkSpans(t, ChunkStrategyRecursive, 30, 15)
+	checkSpans(t, runes, spans, 30, 15)
+}
+
+func TestChunkDocumentPageNumbers(t *testing.T) {
+	doc := &ParsedDocument{Pages: []ParsedPage{
+		{Number: 1, Text: strings.Repeat("甲", 30)},
+		{Number: 2, Text: strings.Repeat("乙", 30)},
+	}}
+	chunks := ChunkDocument(doc, ChunkStrategyFixed, 20, 0)
+	if len(chunks) == 0 || chunks[0].PageNumber != 1 || chunks[len(chunks)-1].PageNumber != 2 {
+		t.Fatalf("unexpected page numbers: %+v", chunks)
+	}
+	for i, chunk := range chunks {
+		if chunk.Index != i {
+			t.Fatalf("chunk %d has index %d", i, chunk.Index)
+		}
+	}
+}
+
+func endsSentence(runes []rune, s span) bool {
+	end := s.end
+	for end > s.start && isSpace(runes[end-1]) && runes[end-1] != '\n' {
+		end--
+	}
+	if end == s.start {
+		return false
+	}
+	return isSentenceEnd(runes, end-1, s.end) || runes[end-1] == '\n'
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// simhash 使用的字符 n-gram 长度，按字符切分可以同时兼顾中英文
+const shingleSize = 3
+
+// DeduplicateChunks 去除文档内的近似重复块（如重复的页眉页脚、模板页）
+// 对归一化后的文本计算 SimHash，相似度 >= threshold 的块视为重复，只保留首次出现的块。
+// 返回去重后的块（重新编号）以及被去除的块数量。
+func DeduplicateChunks(chunks []Chunk, threshold float64) ([]Chunk, int) {
+	if len(chunks) == 0 {
+		return chunks, 0
+	}
+
+	seen := make(map[string]bool, len(chunks))
+	var kept []Chunk
+	var keptHashes []uint64
+
+	for _, chunk := range chunks {
+		normalized := normalizeForDedup(chunk.Content)
+
+		// 完全相同的文本直接判定为重复
+		if seen[normalized] {
+			continue
+		}
+
+		hash := simhash(normalized)
+		duplicate := false
+		if threshold < 1 {
+			for _, h := range keptHashes {
+				if simhashSimilarity(hash, h) >= threshold {
+					duplicate = true
+					break
+				}
+			}
+		}
+		if duplicate {
+			continue
+		}
+
+		seen[normalized] = true
+		keptHashes = append(keptHashes, hash)
+		chunk.Index = len(kept)
+		kept = append(kept, chunk)
+	}
+
+	return kept, len(chunks) - len(kept)
+}
+
+// normalizeForDedup 转小写、去除数字和标点、合并空白，避免页码等细微差异影响判断
+func normalizeForDedup(text string) string {
+	var sb strings.Builder
+	lastSpace := true
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r):
+			sb.WriteRune(r)
+			lastSpace = false
+		case unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsDigit(r):
+			if !lastSpace {
+				sb.WriteRune(' ')
+				lastSpace = true
+			}
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func simhash(text string) uint64 {
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return 0
+	}
+
+	var weights [64]int
+	addFeature := func(feature string) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		for i := 0; i < 64; i++ {
+			if sum&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+
+	if len(runes) < shingleSize {
+		addFeature(text)
+	} else {
+		for i := 0; i+shingleSize <= len(runes); i++ {
+			addFeature(string(runes[i : i+shingleSize]))
+		}
+	}
+
+	var result uint64
+	for i := 0; i < 64; i++ {
+		if weights[i] > 0 {
+			result |= 1 << uint(i)
+		}
+	}
+	return result
+}
+
+func simhashSimilarity(a, b uint64) float64 {
+	return 1 - float64(bits.OnesCount64(a^b))/64
+}
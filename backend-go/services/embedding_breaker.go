@@ -0,0 +1,167 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/metrics"
+)
+
+// ErrEmbeddingCircuitOpen 向量化服务连续失败后熔断，冷却期间的请求直接失败，不再发送到服务
+var ErrEmbeddingCircuitOpen = errors.New("向量化服务熔断中")
+
+// 熔断器状态: closed 正常请求 / open 熔断中，请求直接失败 / half_open 冷却结束，放行一个试探请求
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// EmbeddingBreakerStatus 熔断器的当前状态
+type EmbeddingBreakerStatus struct {
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	// 熔断中时冷却结束的时间，之后的第一个请求作为试探请求
+	RetryAt *time.Time `json:"retry_at,omitempty"`
+}
+
+var embeddingBreaker struct {
+	sync.Mutex
+	state        string
+	failures     int
+	firstFailure time.Time
+	openedAt     time.Time
+}
+
+// breakerEnabled EMBEDDING_BREAKER_THRESHOLD 为 0 时不熔断
+func breakerEnabled() bool {
+	return config.AppConfig.Embedding.BreakerThreshold > 0
+}
+
+// allowEmbeddingRequest 判断是否可以发送向量化请求。熔断中时返回 ErrEmbeddingCircuitOpen；
+// 冷却结束后放行一个试探请求并进入 half_open，试探请求结束前其余请求仍直接失败
+func allowEmbeddingRequest() error {
+	if !breakerEnabled() {
+		return nil
+	}
+	b := &embeddingBreaker
+	b.Lock()
+	defer b.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if left := config.AppConfig.Embedding.BreakerCooldown - time.Since(b.openedAt); left > 0 {
+			metrics.EmbeddingBreakerRejected.Inc()
+			return fmt.Errorf("%w，%s 后重试", ErrEmbeddingCircuitOpen, (left + time.Second - 1).Truncate(time.Second))
+		}
+		setBreakerState(BreakerHalfOpen)
+		log.Println("向量化服务熔断冷却结束，发送试探请求")
+	case BreakerHalfOpen:
+		metrics.EmbeddingBreakerRejected.Inc()
+		return fmt.Errorf("%w，等待试探请求结果", ErrEmbeddingCircuitOpen)
+	}
+	return nil
+}
+
+// recordEmbeddingResult 记录一次向量化请求的结果。窗口 EMBEDDING_BREAKER_WINDOW 内连续失败
+// EMBEDDING_BREAKER_THRESHOLD 次时熔断；试探请求成功时恢复，失败时重新熔断
+func recordEmbeddingResult(err error) {
+	if !breakerEnabled() {
+		return
+	}
+	cfg := config.AppConfig.Embedding
+	b := &embeddingBreaker
+	b.Lock()
+	defer b.Unlock()
+
+	if err == nil {
+		if b.state == BreakerHalfOpen {
+			log.Println("向量化服务试探请求成功，熔断恢复")
+		}
+		b.failures = 0
+		setBreakerState(BreakerClosed)
+		return
+	}
+
+	now := time.Now()
+	switch b.state {
+	case BreakerOpen:
+		// 熔断前已发出的请求，不重新计时
+		return
+	case BreakerHalfOpen:
+		b.openedAt = now
+		setBreakerState(BreakerOpen)
+		log.Printf("向量化服务试探请求失败，继续熔断 %s: %v", cfg.BreakerCooldown, err)
+		return
+	}
+
+	// 距离第一次失败超过窗口时重新计数
+	if b.failures == 0 || now.Sub(b.firstFailure) > cfg.BreakerWindow {
+		b.failures = 0
+		b.firstFailure = now
+	}
+	b.failures++
+	if b.failures >= cfg.BreakerThreshold {
+		b.openedAt = now
+		setBreakerState(BreakerOpen)
+		log.Printf("向量化服务连续失败 %d 次，熔断 %s: %v", b.failures, cfg.BreakerCooldown, err)
+		SendAlert("向量化服务连续失败 %d 次，熔断 %s: %v", b.failures, cfg.BreakerCooldown, err)
+	}
+}
+
+// setBreakerState 修改状态并更新指标，调用方持有锁
+func setBreakerState(state string) {
+	embeddingBreaker.state = state
+	value := 0.0
+	switch state {
+	case BreakerHalfOpen:
+		value = 1
+	case BreakerOpen:
+		value = 2
+	}
+	metrics.EmbeddingBreakerState.Set(value)
+}
+
+// EmbeddingBreaker 返回熔断器的当前状态，未开启熔断时返回 nil
+func EmbeddingBreaker() *EmbeddingBreakerStatus {
+	if !breakerEnabled() {
+		return nil
+	}
+	b := &embeddingBreaker
+	b.Lock()
+	defer b.Unlock()
+
+	status := &EmbeddingBreakerStatus{State: b.state, ConsecutiveFailures: b.failures}
+	if status.State == "" {
+		status.State = BreakerClosed
+	}
+	if b.state == BreakerOpen {
+		retryAt := b.openedAt.Add(config.AppConfig.Embedding.BreakerCooldown)
+		status.RetryAt = &retryAt
+	}
+	return status
+}
+
+// EmbeddingCircuitOpen 是否处于熔断冷却期，冷却结束等待试探时返回 false
+func EmbeddingCircuitOpen() bool {
+	return EmbeddingBreakerRetryAfter() > 0
+}
+
+// EmbeddingBreakerRetryAfter 返回熔断冷却的剩余时间，不在冷却期时返回 0
+func EmbeddingBreakerRetryAfter() time.Duration {
+	if !breakerEnabled() {
+		return 0
+	}
+	b := &embeddingBreaker
+	b.Lock()
+	defer b.Unlock()
+
+	if b.state != BreakerOpen {
+		return 0
+	}
+	return max(config.AppConfig.Embedding.BreakerCooldown-time.Since(b.openedAt), 0)
+}
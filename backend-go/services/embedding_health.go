@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/metrics"
+)
+
+// 单次探测的超时时间
+const embeddingProbeTimeout = 10 * time.Second
+
+// EmbeddingHealthStatus 向量化服务最近一次探测的结果
+type EmbeddingHealthStatus struct {
+	Healthy   bool      `json:"healthy"`
+	CheckedAt time.Time `json:"checked_at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+var embeddingHealth struct {
+	sync.RWMutex
+	status  EmbeddingHealthStatus
+	checked bool
+}
+
+// StartEmbeddingHealthCheck 在后台定期用一条很短的文本探测向量化服务是否可用，
+// EMBEDDING_PROBE_INTERVAL 为 0 时不启动
+func StartEmbeddingHealthCheck() {
+	interval := config.AppConfig.Embedding.ProbeInterval
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		probeEmbedding()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			probeEmbedding()
+		}
+	}()
+}
+
+func probeEmbedding() {
+	client := NewEmbeddingClient()
+	client.HTTPClient = &http.Client{Timeout: embeddingProbeTimeout}
+
+	status := EmbeddingHealthStatus{Healthy: true, CheckedAt: time.Now()}
+	if _, err := client.Embed(context.Background(), []string{"ping"}, 1); err != nil {
+		status.Healthy = false
+		status.Error = err.Error()
+	}
+
+	embeddingHealth.Lock()
+	changed := !embeddingHealth.checked || embeddingHealth.status.Healthy != status.Healthy
+	embeddingHealth.status = status
+	embeddingHealth.checked = true
+	embeddingHealth.Unlock()
+
+	if status.Healthy {
+		metrics.EmbeddingProviderUp.Set(1)
+	} else {
+		metrics.EmbeddingProviderUp.Set(0)
+	}
+	if changed {
+		if status.Healthy {
+			log.Println("向量化服务可用")
+		} else {
+			log.Printf("向量化服务不可用: %s", status.Error)
+		}
+	}
+}
+
+// EmbeddingHealth 返回最近一次探测结果，尚未探测时返回 nil
+func EmbeddingHealth() *EmbeddingHealthStatus {
+	embeddingHealth.RLock()
+	defer embeddingHealth.RUnlock()
+
+	if !embeddingHealth.checked {
+		return nil
+	}
+	status := embeddingHealth.status
+	return &status
+}
+
+// EmbeddingAvailable 向量化服务是否可用，未开启探测或尚未探测时视为可用
+func EmbeddingAvailable() bool {
+	status := EmbeddingHealth()
+	return status == nil || status.Healthy
+}
@@ -0,0 +1,347 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"doc-analysis-backend/config"
+	"doc-analysis-backend/metrics"
+)
+
+// EmbeddingModelKey 块元数据中记录生成向量所用模型的键
+const EmbeddingModelKey = "embedding_model"
+
+// IsStaleEmbeddingModel 向量是否由与当前配置不同的模型生成，需要重新向量化。
+// 未记录模型（记录模型之前生成的向量）时无法判断，返回 false
+func IsStaleEmbeddingModel(model string) bool {
+	return model != "" && model != config.AppConfig.Embedding.Model
+}
+
+// EmbeddingClient OpenAI 兼容的向量化接口客户端（OpenAI、Ollama /v1 等）
+type EmbeddingClient struct {
+	BaseURL          string
+	APIKey           string
+	Model            string
+	MaxRequestTokens int
+	Preprocess       PreprocessOptions
+	HTTPClient       *http.Client
+	// 主服务连续失败后使用的备用服务，未配置时为 nil
+	Fallback *EmbeddingClient
+}
+
+// 主服务返回的向量维度，用于拒绝维度不一致的备用服务结果；配置了 EMBEDDING_DIMENSION 时以配置为准
+var primaryDimension atomic.Int64
+
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+func NewEmbeddingClient() *EmbeddingClient {
+	cfg := config.AppConfig.Embedding
+	client := &EmbeddingClient{
+		BaseURL:          strings.TrimRight(cfg.BaseURL, "/"),
+		APIKey:           cfg.APIKey,
+		Model:            cfg.Model,
+		MaxRequestTokens: cfg.MaxRequestTokens,
+		Preprocess:       preprocessOptionsFromConfig(),
+		HTTPClient: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+	}
+	if cfg.FallbackBaseURL != "" {
+		client.Fallback = &EmbeddingClient{
+			BaseURL:          strings.TrimRight(cfg.FallbackBaseURL, "/"),
+			APIKey:           cfg.FallbackAPIKey,
+			Model:            cfg.FallbackModel,
+			MaxRequestTokens: cfg.MaxRequestTokens,
+			Preprocess:       client.Preprocess,
+			HTTPClient:       client.HTTPClient,
+		}
+	}
+	return client
+}
+
+// Embed 为一组文本生成向量，按 token 预算和 maxBatchSize 自动分批请求，返回结果与输入顺序一致
+func (c *EmbeddingClient) Embed(ctx context.Context, texts []string, maxBatchSize int) ([][]float32, error) {
+	texts = c.preprocess(texts)
+	embeddings := make([][]float32, len(texts))
+
+	for _, batch := range BatchByTokenBudget(texts, c.MaxRequestTokens, maxBatchSize) {
+		inputs := make([]string, len(batch))
+		for i, idx := range batch {
+			inputs[i] = texts[idx]
+		}
+
+		vectors, _, err := c.embedWithFailover(ctx, inputs)
+		if err != nil {
+			return nil, err
+		}
+		for i, idx := range batch {
+			embeddings[idx] = vectors[i]
+		}
+	}
+
+	return embeddings, nil
+}
+
+// EmbedPartial 与 Embed 相同，但某个批次失败时继续请求其余批次，并将失败的批次拆分重试，
+// 找出导致失败的具体文本。失败的文本对应的向量为 nil，错误按文本下标记录在 failures 中。
+// models 记录每条文本实际使用的模型，改用备用服务时可能与 EMBEDDING_MODEL 不同，失败的文本为空。
+// ctx 取消后不再发送请求，正在进行的请求立即中断，剩余的文本都以 ctx 的错误记为失败
+func (c *EmbeddingClient) EmbedPartial(ctx context.Context, texts []string, maxBatchSize int) ([][]float32, []string, map[int]error) {
+	texts = c.preprocess(texts)
+	out := &partialEmbeddings{
+		embeddings: make([][]float32, len(texts)),
+		models:     make([]string, len(texts)),
+		failures:   map[int]error{},
+	}
+
+	for _, batch := range BatchByTokenBudget(texts, c.MaxRequestTokens, maxBatchSize) {
+		if err := ctx.Err(); err != nil {
+			out.fail(batch, err)
+			continue
+		}
+		if err := c.embedInto(ctx, texts, batch, out); err != nil {
+			c.isolateFailures(ctx, texts, batch, err, out)
+		}
+	}
+
+	return out.embeddings, out.models, out.failures
+}
+
+// partialEmbeddings EmbedPartial 的结果，按文本下标记录
+type partialEmbeddings struct {
+	embeddings [][]float32
+	models     []string
+	failures   map[int]error
+}
+
+// fail 将批次中的文本都记为失败
+func (out *partialEmbeddings) fail(batch []int, err error) {
+	for _, idx := range batch {
+		out.failures[idx] = err
+	}
+}
+
+// embedInto 请求一个批次的向量，成功时按下标写入结果
+func (c *EmbeddingClient) embedInto(ctx context.Context, texts []string, batch []int, out *partialEmbeddings) error {
+	inputs := make([]string, len(batch))
+	for i, idx := range batch {
+		inputs[i] = texts[idx]
+	}
+
+	vectors, model, err := c.embedWithFailover(ctx, inputs)
+	if err != nil {
+		return err
+	}
+	for i, idx := range batch {
+		out.embeddings[idx] = vectors[i]
+		out.models[idx] = model
+	}
+	return nil
+}
+
+// embedWithFailover 经过熔断器发送一个批次的请求，熔断中时直接返回 ErrEmbeddingCircuitOpen。
+// 主服务和备用服务都失败时才计为一次失败，ctx 取消导致的失败与服务状态无关，不计入
+func (c *EmbeddingClient) embedWithFailover(ctx context.Context, texts []string) ([][]float32, string, error) {
+	if err := allowEmbeddingRequest(); err != nil {
+		return nil, "", err
+	}
+	vectors, model, err := c.requestWithFailover(ctx, texts)
+	if ctx.Err() == nil {
+		recordEmbeddingResult(err)
+	}
+	return vectors, model, err
+}
+
+// requestWithFailover 请求主服务，配置了备用服务时主服务最多尝试 EMBEDDING_FAILOVER_ATTEMPTS 次，
+// 仍失败则改用备用服务。返回实际使用的模型
+func (c *EmbeddingClient) requestWithFailover(ctx context.Context, texts []string) ([][]float32, string, error) {
+	if c.Fallback == nil {
+		vectors, err := c.embedBatch(ctx, texts)
+		return vectors, c.Model, err
+	}
+
+	var err error
+	for attempt := 0; attempt < config.AppConfig.Embedding.FailoverAttempts; attempt++ {
+		var vectors [][]float32
+		if vectors, err = c.embedBatch(ctx, texts); err == nil {
+			if len(vectors) > 0 {
+				primaryDimension.Store(int64(len(vectors[0])))
+			}
+			return vectors, c.Model, nil
+		}
+		if ctx.Err() != nil {
+			return nil, "", err
+		}
+	}
+
+	log.Printf("主向量化服务请求失败，改用备用服务 %s: %v", c.Fallback.BaseURL, err)
+	metrics.EmbeddingFailovers.Inc()
+	vectors, fallbackErr := c.Fallback.embedBatch(ctx, texts)
+	if fallbackErr != nil {
+		return nil, "", fmt.Errorf("主服务: %v; 备用服务: %w", err, fallbackErr)
+	}
+	if expected := expectedDimension(); expected > 0 && len(vectors) > 0 && len(vectors[0]) != expected {
+		return nil, "", fmt.Errorf("备用服务返回的向量维度为 %d，与主服务的 %d 不一致", len(vectors[0]), expected)
+	}
+	return vectors, c.Fallback.Model, nil
+}
+
+func expectedDimension() int {
+	if dimension := config.AppConfig.Embedding.Dimension; dimension > 0 {
+		return dimension
+	}
+	return int(primaryDimension.Load())
+}
+
+// VerifyFallbackDimension 启动时分别探测主服务和备用服务，两者返回的向量维度不一致时返回错误，
+// 避免同一集合中混入不同维度的向量。任一服务暂时不可用时只记录日志，运行时仍会校验备用服务的结果
+func VerifyFallbackDimension() error {
+	client := NewEmbeddingClient()
+	if client.Fallback == nil {
+		return nil
+	}
+
+	primary, err := client.embedBatch(context.Background(), []string{"ping"})
+	if err != nil {
+		log.Printf("启动时探测主向量化服务失败，暂不校验备用服务的向量维度: %v", err)
+		return nil
+	}
+	primaryDimension.Store(int64(len(primary[0])))
+
+	fallback, err := client.Fallback.embedBatch(context.Background(), []string{"ping"})
+	if err != nil {
+		log.Printf("启动时探测备用向量化服务失败，暂不校验其向量维度: %v", err)
+		return nil
+	}
+	if expected := expectedDimension(); len(primary[0]) != expected || len(fallback[0]) != expected {
+		return fmt.Errorf("主向量化服务维度 %d、备用服务维度 %d 与期望的 %d 不一致",
+			len(primary[0]), len(fallback[0]), expected)
+	}
+	log.Printf("已启用备用向量化服务 %s（模型 %s），向量维度 %d", client.Fallback.BaseURL, client.Fallback.Model, len(fallback[0]))
+	return nil
+}
+
+// isolateFailures 将失败的批次对半拆分后分别重试，只有一半失败时继续拆分该半，直到定位到单条文本。
+// 两半都失败时更可能是服务本身不可用，不再继续拆分，整批记为失败，避免大量无效请求；ctx 已取消时同样不再拆分
+func (c *EmbeddingClient) isolateFailures(ctx context.Context, texts []string, batch []int, err error, out *partialEmbeddings) {
+	if len(batch) == 1 || ctx.Err() != nil {
+		out.fail(batch, err)
+		return
+	}
+
+	mid := len(batch) / 2
+	left, right := batch[:mid], batch[mid:]
+	leftErr := c.embedInto(ctx, texts, left, out)
+	rightErr := c.embedInto(ctx, texts, right, out)
+
+	switch {
+	case leftErr != nil && rightErr != nil:
+		out.fail(left, leftErr)
+		out.fail(right, rightErr)
+	case leftErr != nil:
+		c.isolateFailures(ctx, texts, left, leftErr, out)
+	case rightErr != nil:
+		c.isolateFailures(ctx, texts, right, rightErr, out)
+	}
+}
+
+// preprocess 按配置预处理发送给模型的文本，返回新的切片，不修改调用方的原文
+func (c *EmbeddingClient) preprocess(texts []string) []string {
+	if !c.Preprocess.Enabled() {
+		return texts
+	}
+	processed := make([]string, len(texts))
+	for i, text := range texts {
+		processed[i] = PreprocessText(text, c.Preprocess)
+	}
+	return processed
+}
+
+// BatchByTokenBudget 将文本按顺序打包成批次，每批估算 token 总数不超过 maxTokens、条数不超过 maxCount。
+// 单条文本本身超过预算时单独成批。返回每批对应的文本下标。
+func BatchByTokenBudget(texts []string, maxTokens, maxCount int) [][]int {
+	var batches [][]int
+	var current []int
+	currentTokens := 0
+
+	for i, text := range texts {
+		tokens := EstimateTokens(text)
+		overBudget := maxTokens > 0 && currentTokens+tokens > maxTokens
+		overCount := maxCount > 0 && len(current) >= maxCount
+		if len(current) > 0 && (overBudget || overCount) {
+			batches = append(batches, current)
+			current = nil
+			currentTokens = 0
+		}
+		current = append(current, i)
+		currentTokens += tokens
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+
+	return batches
+}
+
+func (c *EmbeddingClient) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	data, err := json.Marshal(embeddingRequest{Model: c.Model, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/embeddings", bytes.NewBuffer(data))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("向量化失败，状态码: %d, 响应: %s", resp.StatusCode, string(body))
+	}
+
+	var result embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+	if len(result.Data) != len(texts) {
+		return nil, fmt.Errorf("向量数量不匹配: 期望 %d，实际 %d", len(texts), len(result.Data))
+	}
+
+	sort.Slice(result.Data, func(i, j int) bool {
+		return result.Data[i].Index < result.Data[j].Index
+	})
+
+	vectors := make([][]float32, len(result.Data))
+	for i, item := range result.Data {
+		vectors[i] = item.Embedding
+	}
+	return vectors, nil
+}
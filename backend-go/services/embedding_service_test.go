@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"doc-analysis-backend/config"
+)
+
+// newRejectingEmbeddingServer 启动一个 OpenAI 兼容的向量化服务，请求中包含 reject 文本时整批返回 400。
+// 返回的函数获取目前为止收到的每个请求的输入
+func newRejectingEmbeddingServer(t *testing.T, reject string) (*EmbeddingClient, func() [][]string) {
+	t.Helper()
+	saved := config.AppConfig
+	config.AppConfig = &config.Config{} // BreakerThreshold 为 0，不熔断
+	t.Cleanup(func() { config.AppConfig = saved })
+
+	var mu sync.Mutex
+	var requests [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req embeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, req.Input)
+		mu.Unlock()
+
+		var resp embeddingResponse
+		for i, input := range req.Input {
+			if strings.Contains(input, reject) {
+				http.Error(w, "input rejected", http.StatusBadRequest)
+				return
+			}
+			resp.Data = append(resp.Data, struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			}{Index: i, Embedding: []float32{float32(len(input)), 1}})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	client := &EmbeddingClient{BaseURL: server.URL, Model: "test-embedding", HTTPClient: server.Client()}
+	return client, func() [][]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+func embeddingTexts(n int, bad ...int) []string {
+	texts := make([]string, n)
+	for i := range texts {
+		texts[i] = strings.Repeat("x", i+1)
+	}
+	for _, i := range bad {
+		texts[i] = "BAD " + texts[i]
+	}
+	return texts
+}
+
+func TestEmbedPartialIsolatesSingleFailure(t *testing.T) {
+	client, requests := newRejectingEmbeddingServer(t, "BAD")
+	texts := embeddingTexts(8, 5)
+
+	embeddings, models, failures := client.EmbedPartial(context.Background(), texts, 8)
+
+	if len(failures) != 1 || failures[5] == nil {
+		t.Fatalf("failures = %v, want only index 5", failures)
+	}
+	for i := range texts {
+		if i == 5 {
+			if embeddings[i] != nil || models[i] != "" {
+				t.Fatalf("failed text has embedding %v and model %q", embeddings[i], models[i])
+			}
+			continue
+		}
+		if len(embeddings[i]) == 0 || embeddings[i][0] != float32(len(texts[i])) {
+			t.Fatalf("text %d got embedding %v", i, embeddings[i])
+		}
+		if models[i] != "test-embedding" {
+			t.Fatalf("text %d got model %q", i, models[i])
+		}
+	}
+
+	// 整批 8 条失败 -> [0-3] 成功、[4-7] 失败 -> [4,5] 失败、[6,7] 成功 -> [4] 成功、[5] 失败
+	if got := len(requests()); got != 7 {
+		t.Fatalf("sent %d requests, want 7 for a binary search over 8 texts", got)
+	}
+}
+
+func TestEmbedPartialBothHalvesFailMarksWholeBatch(t *testing.T) {
+	client, requests := newRejectingEmbeddingServer(t, "BAD")
+	// 第 1 条和第 6 条分别位于两半中
+	texts := embeddingTexts(8, 1, 6)
+
+	embeddings, models, failures := client.EmbedPartial(context.Background(), texts, 8)
+
+	if len(failures) != len(texts) {
+		t.Fatalf("failures = %v, want the whole batch", failures)
+	}
+	for i := range texts {
+		if failures[i] == nil || embeddings[i] != nil || models[i] != "" {
+			t.Fatalf("text %d: failure %v, embedding %v, model %q", i, failures[i], embeddings[i], models[i])
+		}
+	}
+	// 整批一次，两半各一次，两半都失败后不再继续拆分
+	if got := len(requests()); got != 3 {
+		t.Fatalf("sent %d requests, want 3", got)
+	}
+}
+
+func TestEmbedPartialOnlyRetriesFailedBatch(t *testing.T) {
+	client, requests := newRejectingEmbeddingServer(t, "BAD")
+	texts := embeddingTexts(6, 4)
+
+	_, _, failures := client.EmbedPartial(context.Background(), texts, 3)
+
+	if len(failures) != 1 || failures[4] == nil {
+		t.Fatalf("failures = %v, want only index 4", failures)
+	}
+	// 第一批 [0-2] 一次成功，不会重试
+	for _, input := range requests()[1:] {
+		for _, text := range input {
+			if text == texts[0] {
+				t.Fatalf("successful batch was retried: %v", input)
+			}
+		}
+	}
+}
+
+func TestEmbedPartialCancelled(t *testing.T) {
+	client, requests := newRejectingEmbeddingServer(t, "BAD")
+	texts := embeddingTexts(6)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	embeddings, _, failures := client.EmbedPartial(ctx, texts, 2)
+
+	if len(failures) != len(texts) {
+		t.Fatalf("failures = %v, want every text", failures)
+	}
+	for i := range texts {
+		if !errors.Is(failures[i], context.Canceled) || embeddings[i] != nil {
+			t.Fatalf("text %d: failure %v, embedding %v", i, failures[i], embeddings[i])
+		}
+	}
+	if got := len(requests()); got != 0 {
+		t.Fatalf("sent %d requests after cancellation", got)
+	}
+}
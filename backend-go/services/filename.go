@@ -0,0 +1,79 @@
+package services
+
+import (
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"doc-analysis-backend/config"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// 清理后没有剩余内容时使用的文件名主体
+const fallbackFilename = "unnamed"
+
+// SanitizeFilename 清理客户端提供的原始文件名，用于保存到文件记录和下载时的 Content-Disposition:
+// 去掉路径部分（同时识别 / 和 \），Unicode NFC 规范化，去除控制字符，连续空白合并为一个空格，
+// 去掉首尾的空白和点，超过 UPLOAD_FILENAME_MAX_BYTES 时截断文件名主体、保留扩展名。
+// 存储路径始终由文件 ID 生成，不使用该文件名
+func SanitizeFilename(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = path.Base(name)
+	name = norm.NFC.String(name)
+
+	var b strings.Builder
+	space := false
+	for _, r := range name {
+		switch {
+		case r == utf8.RuneError, unicode.IsControl(r):
+			continue
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	name = strings.TrimRight(b.String(), " .")
+
+	ext := path.Ext(name)
+	stem := strings.Trim(strings.TrimSuffix(name, ext), " .")
+	if stem == "" {
+		// 只有扩展名的文件（如 ".pdf"）保留扩展名
+		stem = fallbackFilename
+	}
+	if maxBytes := config.AppConfig.Upload.FilenameMaxBytes; len(stem)+len(ext) > maxBytes {
+		if len(ext) > maxBytes/2 {
+			ext = ""
+		}
+		stem = truncateUTF8(stem, maxBytes-len(ext))
+	}
+	return stem + ext
+}
+
+// truncateUTF8 截断到不超过 maxBytes 字节，不会截断在多字节字符的中间
+func truncateUTF8(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	for maxBytes > 0 && !utf8.RuneStart(s[maxBytes]) {
+		maxBytes--
+	}
+	return strings.TrimRight(s[:maxBytes], " .")
+}
+
+// IsAllowedFileType 按扩展名（不区分大小写）判断文件类型是否在 allowedExt 中，上传、上传前校验和解压共用
+func IsAllowedFileType(filename string, allowedExt []string) bool {
+	ext := strings.ToLower(path.Ext(filename))
+	for _, allowed := range allowedExt {
+		if ext == allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"doc-analysis-backend/config"
+)
+
+// withFilenameMaxBytes 临时替换 config.AppConfig，测试结束后恢复
+func withFilenameMaxBytes(t *testing.T, maxBytes int) {
+	t.Helper()
+	saved := config.AppConfig
+	cfg := &config.Config{}
+	cfg.Upload.FilenameMaxBytes = maxBytes
+	config.AppConfig = cfg
+	t.Cleanup(func() { config.AppConfig = saved })
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	withFilenameMaxBytes(t, 255)
+
+	cases := []struct {
+		name, in, want string
+	}{
+		{"unix traversal", "../evil.pdf", "evil.pdf"},
+		{"windows traversal", "..\\evil.pdf", "evil.pdf"},
+		{"nested windows path", "C:\\Users\\..\\..\\win.pdf", "win.pdf"},
+		{"surrounding spaces", " a.pdf ", "a.pdf"},
+		{"inner whitespace collapsed", "a \t  b .pdf", "a b.pdf"},
+		{"extension only", ".pdf", "unnamed.pdf"},
+		{"dot dot", "..", "unnamed"},
+		{"empty", "", "unnamed"},
+		{"trailing dot", "file.pdf.", "file.pdf"},
+		{"control characters", "x\x00y\x1f\x7f.pdf", "xy.pdf"},
+		{"newline in name", "report\r\n2024.pdf", "report2024.pdf"},
+		{"invalid utf8", "a\xffb.pdf", "ab.pdf"},
+		{"nfc normalization", "cafe\u0301.pdf", "caf\u00e9.pdf"},
+		{"unicode kept", "年度报告.docx", "年度报告.docx"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := SanitizeFilename(tc.in); got != tc.want {
+				t.Fatalf("SanitizeFilename(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilenameTruncation(t *testing.T) {
+	withFilenameMaxBytes(t, 20)
+
+	cases := []struct {
+		name, in, want string
+	}{
+		// 每个 "中" 占 3 字节，主体只剩 16 字节时截断到 5 个字符，不拆开多字节字符
+		{"multibyte stem", strings.Repeat("中", 20) + ".pdf", "中中中中中.pdf"},
+		{"ascii stem", strings.Repeat("a", 30) + ".pdf", strings.Repeat("a", 16) + ".pdf"},
+		{"fits exactly", strings.Repeat("a", 16) + ".pdf", strings.Repeat("a", 16) + ".pdf"},
+		// 扩展名超过上限的一半时丢弃扩展名
+		{"long extension dropped", "ab." + strings.Repeat("x", 20), "ab"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := SanitizeFilename(tc.in)
+			if got != tc.want {
+				t.Fatalf("SanitizeFilename(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+			if len(got) > 20 || !utf8.ValidString(got) {
+				t.Fatalf("SanitizeFilename(%q) = %q: %d bytes or invalid UTF-8", tc.in, got, len(got))
+			}
+		})
+	}
+}
+
+func TestIsAllowedFileType(t *testing.T) {
+	allowed := []string{".pdf", ".docx"}
+	for in, want := range map[string]bool{
+		"a.pdf":      true,
+		"A.PDF":      true,
+		"dir/b.docx": true,
+		"c.txt":      false,
+		"pdf":        false,
+		"d.pdf.exe":  false,
+	} {
+		if got := IsAllowedFileType(in, allowed); got != want {
+			t.Errorf("IsAllowedFileType(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
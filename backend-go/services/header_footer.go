@@ -0,0 +1,109 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	// 每页只在开头和结尾各检查这么多行，页眉页脚通常不超过两行
+	headerFooterScanLines = 2
+	// 页数少于该值时无法可靠地判断重复，不做处理
+	headerFooterMinPages = 3
+)
+
+// StripHeadersFooters 找出在大量页面的相同位置（开头或结尾的第几行）重复出现的行，视为页眉页脚并从页面文本中删除，
+// 返回删除的行数。比较时忽略数字，"第 3 页"、"Page 3 of 10" 这类带页码的行同样能识别；
+// minRatio 为重复出现的页面数占总页数的最小比例
+func StripHeadersFooters(doc *ParsedDocument, minRatio float64) int {
+	if len(doc.Pages) < headerFooterMinPages {
+		return 0
+	}
+
+	pageLines := make([][]string, len(doc.Pages))
+	counts := make(map[string]int)
+	for i, page := range doc.Pages {
+		lines := strings.Split(page.Text, "\n")
+		pageLines[i] = lines
+		// 同一页中同一位置键只计一次
+		seen := make(map[string]bool)
+		for _, key := range edgeLineKeys(lines) {
+			if key != "" && !seen[key] {
+				seen[key] = true
+				counts[key]++
+			}
+		}
+	}
+
+	threshold := max(int(float64(len(doc.Pages))*minRatio+0.5), headerFooterMinPages)
+	repeated := make(map[string]bool)
+	for key, count := range counts {
+		if count >= threshold {
+			repeated[key] = true
+		}
+	}
+	if len(repeated) == 0 {
+		return 0
+	}
+
+	stripped := 0
+	for i := range doc.Pages {
+		lines := pageLines[i]
+		keys := edgeLineKeys(lines)
+		kept := make([]string, 0, len(lines))
+		for j, line := range lines {
+			if key, ok := keys[j]; ok && repeated[key] {
+				stripped++
+				continue
+			}
+			kept = append(kept, line)
+		}
+		doc.Pages[i].Text = strings.Join(kept, "\n")
+	}
+	return stripped
+}
+
+// edgeLineKeys 为页面开头和结尾的行生成位置键，键为 top/bottom、第几行和去掉数字后的文本，空行不参与比较
+func edgeLineKeys(lines []string) map[int]string {
+	var nonEmpty []int
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			nonEmpty = append(nonEmpty, i)
+		}
+	}
+
+	keys := make(map[int]string)
+	for k := 0; k < headerFooterScanLines && k < len(nonEmpty); k++ {
+		if idx := nonEmpty[k]; keys[idx] == "" {
+			keys[idx] = edgeLineKey("top", k, lines[idx])
+		}
+		if idx := nonEmpty[len(nonEmpty)-1-k]; keys[idx] == "" {
+			keys[idx] = edgeLineKey("bottom", k, lines[idx])
+		}
+	}
+	return keys
+}
+
+// edgeLineKey 连续的数字替换为一个 #，页码位数不同（9 与 10）时仍视为同一行；空白忽略
+func edgeLineKey(edge string, position int, line string) string {
+	var sb strings.Builder
+	inDigits := false
+	for _, r := range line {
+		switch {
+		case unicode.IsDigit(r):
+			if !inDigits {
+				sb.WriteByte('#')
+			}
+			inDigits = true
+			continue
+		case !unicode.IsSpace(r):
+			sb.WriteRune(r)
+		}
+		inDigits = false
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d:%s", edge, position, sb.String())
+}
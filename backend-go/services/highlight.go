@@ -0,0 +1,153 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// HighlightOptions 摘要长度（字符数）及包裹关键词的标记
+type HighlightOptions struct {
+	SnippetLength int
+	PreTag        string
+	PostTag       string
+}
+
+// QueryTerms 按空白拆分查询，转为小写并去重
+func QueryTerms(query string) []string {
+	seen := map[string]bool{}
+	var terms []string
+	for _, field := range strings.Fields(strings.ToLower(query)) {
+		if !seen[field] {
+			seen[field] = true
+			terms = append(terms, field)
+		}
+	}
+	return terms
+}
+
+type termMatch struct {
+	start, end int // 字符下标 [start, end)
+	term       string
+}
+
+// findTermMatches 忽略大小写查找所有关键词出现的位置，重叠时保留靠前且较长的匹配
+func findTermMatches(runes []rune, terms []string) []termMatch {
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	var matches []termMatch
+	for _, term := range terms {
+		termRunes := []rune(term)
+		if len(termRunes) == 0 {
+			continue
+		}
+		for i := 0; i+len(termRunes) <= len(lower); i++ {
+			if hasRunePrefix(lower[i:], termRunes) {
+				matches = append(matches, termMatch{start: i, end: i + len(termRunes), term: term})
+			}
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].start != matches[j].start {
+			return matches[i].start < matches[j].start
+		}
+		return matches[i].end > matches[j].end
+	})
+
+	var result []termMatch
+	lastEnd := 0
+	for _, m := range matches {
+		if m.start >= lastEnd {
+			result = append(result, m)
+			lastEnd = m.end
+		}
+	}
+	return result
+}
+
+// KeywordScore 计算块与关键词的匹配程度: 命中的关键词种类优先，其次是命中次数
+func KeywordScore(content string, terms []string) float64 {
+	matches := findTermMatches([]rune(content), terms)
+	if len(matches) == 0 {
+		return 0
+	}
+	distinct := map[string]bool{}
+	for _, m := range matches {
+		distinct[m.term] = true
+	}
+	return float64(len(distinct)) + float64(len(matches))/float64(len(matches)+10)
+}
+
+// HighlightSnippet 截取包含关键词最多的一段文本，并用标记包裹其中的关键词。
+// 没有命中关键词时返回开头部分。
+func HighlightSnippet(content string, terms []string, opts HighlightOptions) string {
+	runes := []rune(content)
+	matches := findTermMatches(runes, terms)
+	if len(matches) == 0 {
+		return ChunkStart(content, opts.SnippetLength)
+	}
+
+	length := opts.SnippetLength
+	if length <= 0 || length > len(runes) {
+		length = len(runes)
+	}
+
+	// 以每个匹配为窗口起点，找出包含匹配最多的窗口
+	best, bestCount := 0, 0
+	for i := range matches {
+		count := 0
+		for j := i; j < len(matches) && matches[j].end <= matches[i].start+length; j++ {
+			count++
+		}
+		if count > bestCount {
+			best, bestCount = i, count
+		}
+	}
+
+	// 让命中的区域居中
+	clusterStart := matches[best].start
+	clusterEnd := matches[best+bestCount-1].end
+	start := clusterStart - (length-(clusterEnd-clusterStart))/2
+	if start < 0 {
+		start = 0
+	}
+	end := start + length
+	if end > len(runes) {
+		end = len(runes)
+		start = end - length
+	}
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("...")
+	}
+	pos := start
+	for _, m := range matches {
+		if m.start < start || m.end > end {
+			continue
+		}
+		sb.WriteString(string(runes[pos:m.start]))
+		sb.WriteString(opts.PreTag)
+		sb.WriteString(string(runes[m.start:m.end]))
+		sb.WriteString(opts.PostTag)
+		pos = m.end
+	}
+	sb.WriteString(string(runes[pos:end]))
+	if end < len(runes) {
+		sb.WriteString("...")
+	}
+	return sb.String()
+}
+
+// ChunkStart 返回文本开头的 length 个字符
+func ChunkStart(content string, length int) string {
+	runes := []rune(content)
+	if length <= 0 || len(runes) <= length {
+		return content
+	}
+	return string(runes[:length]) + "..."
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"doc-analysis-backend/config"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// ErrPageOutOfRange 请求的页码超出文档页数
+var ErrPageOutOfRange = errors.New("页码超出范围")
+
+// PageImageContentType 返回配置的页面图片格式对应的 Content-Type
+func PageImageContentType() string {
+	if config.AppConfig.PageImage.Format == "jpeg" {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+// PDFPageCount 读取 PDF 的页数，用于尚未处理（记录中没有页数）的文件
+func PDFPageCount(path string) (count int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("PDF解析异常: %v", r)
+		}
+	}()
+
+	f, reader, err := pdf.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("打开PDF失败: %w", err)
+	}
+	defer f.Close()
+	return reader.NumPage(), nil
+}
+
+// RenderPageImage 将 PDF 的第 page 页（从 1 开始）渲染为图片并缓存到磁盘，返回图片路径。
+// 已缓存时直接返回；渲染通过 pdftoppm 完成，先输出到临时目录再移动到缓存目录
+func RenderPageImage(ctx context.Context, fileID, pdfPath string, page, totalPages int) (string, error) {
+	cfg := config.AppConfig.PageImage
+	if page < 1 || page > totalPages {
+		return "", ErrPageOutOfRange
+	}
+
+	cached, ok := CachedPageImage(fileID, page)
+	if ok {
+		return cached, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cached), 0755); err != nil {
+		return "", fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+	tmpDir, err := os.MkdirTemp(config.AppConfig.Upload.TempDir, "render-*")
+	if err != nil {
+		return "", fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	// -singlefile 时输出文件名为 <前缀>.<扩展名>，不带页码后缀
+	prefix := filepath.Join(tmpDir, "page")
+	args := []string{
+		"-f", fmt.Sprint(page), "-l", fmt.Sprint(page),
+		"-r", fmt.Sprint(cfg.DPI),
+		"-" + cfg.Format, "-singlefile",
+		pdfPath, prefix,
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, cfg.Command, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("渲染页面失败: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	if err := MoveFile(prefix+filepath.Ext(cached), cached); err != nil {
+		return "", fmt.Errorf("保存页面图片失败: %w", err)
+	}
+	return cached, nil
+}
+
+// CachedPageImage 返回页面图片的缓存路径以及缓存是否存在。
+// DPI 写入文件名，修改配置后不会返回旧分辨率的缓存
+func CachedPageImage(fileID string, page int) (string, bool) {
+	cfg := config.AppConfig.PageImage
+	ext := "png"
+	if cfg.Format == "jpeg" {
+		ext = "jpg"
+	}
+	path := filepath.Join(cfg.CacheDir, fileID, fmt.Sprintf("%d_%d.%s", page, cfg.DPI, ext))
+	_, err := os.Stat(path)
+	return path, err == nil
+}
+
+// RemovePageImages 删除文件的页面图片缓存
+func RemovePageImages(fileID string) {
+	os.RemoveAll(filepath.Join(config.AppConfig.PageImage.CacheDir, fileID))
+}
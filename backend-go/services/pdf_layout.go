@@ -0,0 +1,127 @@
+package services
+
+import (
+	"math"
+
+	"github.com/ledongthuc/pdf"
+)
+
+const (
+	// 栏间空白的最小宽度（单位: 点）
+	columnGutterMinWidth = 10.0
+	// 跨过栏间空白的行不超过该比例时仍视为分栏，标题、通栏图注等会跨栏
+	columnGutterMaxCrossRatio = 0.1
+	// 栏间空白两侧各至少占页面文本宽度的比例，避免把缩进或行尾空白当作分栏
+	columnMinSideRatio = 0.2
+	// 递归拆分的最大深度，最多识别到 8 栏
+	columnMaxDepth = 3
+)
+
+// OrderRowsByColumns 识别多栏排版，将行重新排列为阅读顺序: 先读完左栏再读右栏。
+// 跨栏的行（标题、通栏图注）作为分隔，之前的分栏内容先输出，之后重新开始分栏。
+// 每一栏内部会继续尝试拆分，支持三栏以上的排版；未识别到分栏时原样返回
+func OrderRowsByColumns(rows pdf.Rows) pdf.Rows {
+	return orderRows(rows, 0)
+}
+
+func orderRows(rows pdf.Rows, depth int) pdf.Rows {
+	if depth >= columnMaxDepth || len(rows) < 2 {
+		return rows
+	}
+	start, end, ok := findGutter(rows)
+	if !ok {
+		return rows
+	}
+
+	var result, left, right pdf.Rows
+	flush := func() {
+		result = append(result, orderRows(left, depth+1)...)
+		result = append(result, orderRows(right, depth+1)...)
+		left, right = nil, nil
+	}
+
+	for _, row := range rows {
+		var leftWords, rightWords pdf.TextHorizontal
+		spanning := false
+		for _, word := range row.Content {
+			switch {
+			case word.X+word.W <= start:
+				leftWords = append(leftWords, word)
+			case word.X >= end:
+				rightWords = append(rightWords, word)
+			default:
+				spanning = true
+			}
+		}
+
+		if spanning {
+			flush()
+			result = append(result, row)
+			continue
+		}
+		if len(leftWords) > 0 {
+			left = append(left, &pdf.Row{Position: row.Position, Content: leftWords})
+		}
+		if len(rightWords) > 0 {
+			right = append(right, &pdf.Row{Position: row.Position, Content: rightWords})
+		}
+	}
+	flush()
+
+	return result
+}
+
+// findGutter 在文本的水平范围内寻找栏间空白，返回空白的起止 X 坐标。
+// 按 1 点的精度统计每个位置被多少行的文本覆盖，覆盖行数很少且足够宽的连续区域视为栏间空白，取最宽的一段
+func findGutter(rows pdf.Rows) (float64, float64, bool) {
+	minX, maxX := math.MaxFloat64, -math.MaxFloat64
+	for _, row := range rows {
+		for _, word := range row.Content {
+			minX = math.Min(minX, word.X)
+			maxX = math.Max(maxX, word.X+word.W)
+		}
+	}
+	width := int(math.Ceil(maxX - minX))
+	if width <= 0 {
+		return 0, 0, false
+	}
+
+	coverage := make([]int, width+1)
+	for _, row := range rows {
+		covered := make([]bool, width+1)
+		for _, word := range row.Content {
+			from := int(word.X - minX)
+			to := int(math.Ceil(word.X + word.W - minX))
+			for x := from; x <= to && x <= width; x++ {
+				covered[x] = true
+			}
+		}
+		for x, c := range covered {
+			if c {
+				coverage[x]++
+			}
+		}
+	}
+
+	maxCross := int(math.Ceil(float64(len(rows)) * columnGutterMaxCrossRatio))
+	minSide := int(float64(width) * columnMinSideRatio)
+	bestStart, bestEnd := -1, -1
+	for x := minSide; x < width-minSide; {
+		if coverage[x] > maxCross {
+			x++
+			continue
+		}
+		gapStart := x
+		for x < width-minSide && coverage[x] <= maxCross {
+			x++
+		}
+		if x-gapStart > bestEnd-bestStart {
+			bestStart, bestEnd = gapStart, x
+		}
+	}
+
+	if bestStart < 0 || float64(bestEnd-bestStart) < columnGutterMinWidth {
+		return 0, 0, false
+	}
+	return minX + float64(bestStart), minX + float64(bestEnd), true
+}
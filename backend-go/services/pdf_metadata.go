@@ -0,0 +1,115 @@
+package services
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// 文档信息字段保留的最大字符数，超出的部分截断
+const pdfMetadataMaxLength = 255
+
+// 块元数据中记录 PDF 标题和作者的键
+const (
+	DocumentTitleKey  = "document_title"
+	DocumentAuthorKey = "document_author"
+)
+
+// PDFMetadata PDF 文档信息字典（Info）中的字段，缺失或无法解码的字段为空
+type PDFMetadata struct {
+	Title     string     `json:"title,omitempty"`
+	Author    string     `json:"author,omitempty"`
+	Subject   string     `json:"subject,omitempty"`
+	Keywords  string     `json:"keywords,omitempty"`
+	Creator   string     `json:"creator,omitempty"`
+	Producer  string     `json:"producer,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// readPDFMetadata 读取文档信息字典。很多 PDF 的信息字段缺失、是占位符或使用了错误的编码，
+// 无法解码为有效文本的字段直接丢弃，不影响正文解析
+func readPDFMetadata(reader *pdf.Reader) *PDFMetadata {
+	info := reader.Trailer().Key("Info")
+	if info.Kind() != pdf.Dict {
+		return &PDFMetadata{}
+	}
+
+	return &PDFMetadata{
+		Title:     cleanPDFText(info.Key("Title")),
+		Author:    cleanPDFText(info.Key("Author")),
+		Subject:   cleanPDFText(info.Key("Subject")),
+		Keywords:  cleanPDFText(info.Key("Keywords")),
+		Creator:   cleanPDFText(info.Key("Creator")),
+		Producer:  cleanPDFText(info.Key("Producer")),
+		CreatedAt: parsePDFDate(info.Key("CreationDate").RawString()),
+	}
+}
+
+// 常见的无意义标题，如 Word 转换时留下的默认值
+var placeholderTitles = map[string]bool{
+	"untitled": true, "無題": true, "无标题": true, "title": true, "microsoft word": true,
+}
+
+// cleanPDFText 解码文本字段并去除控制字符，不是有效 UTF-8（编码错误）或只有占位内容时返回空
+func cleanPDFText(value pdf.Value) string {
+	if value.Kind() != pdf.String {
+		return ""
+	}
+	text := value.Text()
+	if !utf8.ValidString(text) {
+		return ""
+	}
+	text = strings.Join(strings.FieldsFunc(text, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r) || r == utf8.RuneError
+	}), " ")
+	if placeholderTitles[strings.ToLower(text)] {
+		return ""
+	}
+	if utf8.RuneCountInString(text) > pdfMetadataMaxLength {
+		text = string([]rune(text)[:pdfMetadataMaxLength])
+	}
+	return text
+}
+
+// PDF 日期格式 D:YYYYMMDDHHmmSSOHH'mm'，月份之后的部分都可以省略
+var pdfDatePattern = regexp.MustCompile(`^(?:D:)?(\d{4})(\d{2})?(\d{2})?(\d{2})?(\d{2})?(\d{2})?(?:([Zz+\-])(\d{2})?'?(\d{2})?'?)?`)
+
+// parsePDFDate 解析 PDF 日期，无法解析或日期不合法时返回 nil；没有时区时按 UTC 处理
+func parsePDFDate(s string) *time.Time {
+	m := pdfDatePattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return nil
+	}
+	part := func(i, def int) int {
+		if m[i] == "" {
+			return def
+		}
+		n, _ := strconv.Atoi(m[i])
+		return n
+	}
+	year, month, day := part(1, 0), part(2, 1), part(3, 1)
+	hour, minute, second := part(4, 0), part(5, 0), part(6, 0)
+	if month < 1 || month > 12 || day < 1 || day > 31 || hour > 23 || minute > 59 || second > 59 {
+		return nil
+	}
+
+	loc := time.UTC
+	if sign := m[7]; sign == "+" || sign == "-" {
+		offset := part(8, 0)*3600 + part(9, 0)*60
+		if sign == "-" {
+			offset = -offset
+		}
+		loc = time.FixedZone("", offset)
+	}
+	t := time.Date(year, time.Month(month), day, hour, minute, second, 0, loc)
+	// time.Date 会把 2 月 30 日之类的日期顺延，顺延说明日期不合法
+	if t.Day() != day {
+		return nil
+	}
+	return &t
+}
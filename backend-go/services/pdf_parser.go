@@ -0,0 +1,188 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// ParsedPage 单页解析结果
+type ParsedPage struct {
+	Number int    `json:"number"`
+	Text   string `json:"text"`
+}
+
+// ParsedDocument PDF 文档解析结果
+type ParsedDocument struct {
+	TotalPages  int          `json:"total_pages"`
+	Pages       []ParsedPage `json:"pages"`
+	TablesCount int          `json:"tables_count"`
+	// 识别为页眉页脚并删除的行数
+	StrippedLines int `json:"stripped_lines"`
+	// 文档信息字典，未开启 ExtractMetadata 时为空
+	Metadata *PDFMetadata `json:"metadata,omitempty"`
+}
+
+// TextLength 返回所有页面中非空白字符的数量
+func (d *ParsedDocument) TextLength() int {
+	count := 0
+	for _, page := range d.Pages {
+		for _, r := range page.Text {
+			if !unicode.IsSpace(r) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// ParseOptions PDF 解析选项
+type ParseOptions struct {
+	// 识别表格并输出为 Markdown，速度较慢
+	ExtractTables bool
+	// 识别多栏排版并按阅读顺序输出，速度较慢
+	DetectColumns bool
+	// 读取标题、作者、创建时间等文档信息
+	ExtractMetadata bool
+}
+
+// ParsePDF 逐页提取 PDF 中的文本，按行还原阅读顺序
+func ParsePDF(path string, opts ParseOptions) (doc *ParsedDocument, err error) {
+	// 底层解析库遇到损坏的文件会直接 panic，这里统一转换为错误
+	defer func() {
+		if r := recover(); r != nil {
+			doc = nil
+			err = fmt.Errorf("PDF解析异常: %v", r)
+		}
+	}()
+
+	f, reader, err := pdf.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开PDF失败: %w", err)
+	}
+	defer f.Close()
+
+	totalPages := reader.NumPage()
+	doc = &ParsedDocument{
+		TotalPages: totalPages,
+		Pages:      make([]ParsedPage, 0, totalPages),
+	}
+	if opts.ExtractMetadata {
+		doc.Metadata = readPDFMetadata(reader)
+	}
+
+	for i := 1; i <= totalPages; i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+
+		text, tables, err := extractPageText(page, opts)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 页解析失败: %w", i, err)
+		}
+		doc.TablesCount += tables
+
+		doc.Pages = append(doc.Pages, ParsedPage{
+			Number: i,
+			Text:   text,
+		})
+	}
+
+	return doc, nil
+}
+
+// PDFInfo PDF 文件的基本信息，用于上传前校验
+type PDFInfo struct {
+	TotalPages int  `json:"total_pages"`
+	Encrypted  bool `json:"encrypted"`
+	// 第一页提取到的非空白字符数，为 0 时通常是扫描件
+	FirstPageChars int `json:"first_page_chars"`
+}
+
+// InspectPDF 打开 PDF 并提取第一页文本，检查文件能否被解析。
+// 需要密码才能打开的加密文件返回错误；空密码加密的文件可以正常解析，Encrypted 为 true
+func InspectPDF(path string) (info *PDFInfo, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			info = nil
+			err = fmt.Errorf("PDF解析异常: %v", r)
+		}
+	}()
+
+	f, reader, err := pdf.Open(path)
+	if errors.Is(err, pdf.ErrInvalidPassword) {
+		return nil, errors.New("PDF 已加密，需要密码才能打开")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("打开PDF失败: %w", err)
+	}
+	defer f.Close()
+
+	info = &PDFInfo{
+		TotalPages: reader.NumPage(),
+		Encrypted:  !reader.Trailer().Key("Encrypt").IsNull(),
+	}
+	if info.TotalPages == 0 {
+		return nil, errors.New("PDF 没有页面")
+	}
+
+	if page := reader.Page(1); !page.V.IsNull() {
+		text, _, err := extractPageText(page, ParseOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("第 1 页解析失败: %w", err)
+		}
+		for _, r := range text {
+			if !unicode.IsSpace(r) {
+				info.FirstPageChars++
+			}
+		}
+	}
+	return info, nil
+}
+
+// Text 返回整篇文档的纯文本
+func (d *ParsedDocument) Text() string {
+	var sb strings.Builder
+	for _, page := range d.Pages {
+		sb.WriteString(page.Text)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func extractPageText(page pdf.Page, opts ParseOptions) (string, int, error) {
+	rows, err := page.GetTextByRow()
+	if err != nil {
+		return "", 0, err
+	}
+	if opts.DetectColumns {
+		rows = OrderRowsByColumns(rows)
+	}
+
+	if opts.ExtractTables {
+		lines, tables := extractRowsWithTables(rows)
+		return strings.Join(lines, "\n"), tables, nil
+	}
+
+	lines := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if line := rowText(row); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.Join(lines, "\n"), 0, nil
+}
+
+// rowText 拼接一行中的所有文本块
+func rowText(row *pdf.Row) string {
+	var sb strings.Builder
+	for _, word := range row.Content {
+		sb.WriteString(word.S)
+	}
+	return strings.TrimSpace(sb.String())
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"math"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+const (
+	// 连续多少行列对齐才视为表格
+	tableMinRows = 3
+	// 列起始 X 坐标的对齐容差（单位: 点）
+	tableColumnTolerance = 3.0
+)
+
+// rowCells 将一行中的文本块作为单元格，返回每个单元格的起始 X 坐标和文本
+func rowCells(row *pdf.Row) ([]float64, []string) {
+	var xs []float64
+	var cells []string
+	for _, word := range row.Content {
+		text := strings.TrimSpace(word.S)
+		if text == "" {
+			continue
+		}
+		xs = append(xs, word.X)
+		cells = append(cells, text)
+	}
+	return xs, cells
+}
+
+// columnsAligned 判断两行的单元格数量相同且各列起始位置对齐
+func columnsAligned(a, b []float64) bool {
+	if len(a) != len(b) || len(a) < 2 {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > tableColumnTolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// extractRowsWithTables 按行输出文本，其中连续 tableMinRows 行以上、列位置对齐的区域识别为表格，
+// 以 Markdown 表格输出，返回识别到的表格数量
+func extractRowsWithTables(rows pdf.Rows) ([]string, int) {
+	type parsedRow struct {
+		line  string
+		xs    []float64
+		cells []string
+	}
+	parsed := make([]parsedRow, 0, len(rows))
+	for _, row := range rows {
+		line := rowText(row)
+		if line == "" {
+			continue
+		}
+		xs, cells := rowCells(row)
+		parsed = append(parsed, parsedRow{line: line, xs: xs, cells: cells})
+	}
+
+	var lines []string
+	tables := 0
+	for i := 0; i < len(parsed); {
+		// 找出从第 i 行开始列对齐的连续行
+		j := i + 1
+		for j < len(parsed) && columnsAligned(parsed[i].xs, parsed[j].xs) {
+			j++
+		}
+
+		if j-i >= tableMinRows {
+			tableRows := make([][]string, 0, j-i)
+			for _, row := range parsed[i:j] {
+				tableRows = append(tableRows, row.cells)
+			}
+			lines = append(lines, formatMarkdownTable(tableRows))
+			tables++
+			i = j
+			continue
+		}
+
+		lines = append(lines, parsed[i].line)
+		i++
+	}
+
+	return lines, tables
+}
+
+// formatMarkdownTable 将表格输出为 Markdown，第一行作为表头
+func formatMarkdownTable(rows [][]string) string {
+	var sb strings.Builder
+	writeRow := func(cells []string) {
+		sb.WriteString("|")
+		for _, cell := range cells {
+			sb.WriteString(" ")
+			sb.WriteString(strings.ReplaceAll(cell, "|", "\\|"))
+			sb.WriteString(" |")
+		}
+		sb.WriteString("\n")
+	}
+
+	writeRow(rows[0])
+	sb.WriteString("|")
+	for range rows[0] {
+		sb.WriteString(" --- |")
+	}
+	sb.WriteString("\n")
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
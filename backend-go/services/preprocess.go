@@ -0,0 +1,60 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+
+	"doc-analysis-backend/config"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// PreprocessOptions 向量化前对文本的预处理，只影响发送给模型的文本，存储和返回的仍是原文
+type PreprocessOptions struct {
+	NormalizeUnicode    bool // Unicode NFC 规范化，统一全角/组合字符等不同编码形式
+	StripControlChars   bool // 去除控制字符（保留换行和制表符）
+	NormalizeWhitespace bool // 连续空白合并为一个空格
+	Lowercase           bool // 转为小写
+}
+
+func preprocessOptionsFromConfig() PreprocessOptions {
+	cfg := config.AppConfig.Embedding
+	return PreprocessOptions{
+		NormalizeUnicode:    cfg.NormalizeUnicode,
+		StripControlChars:   cfg.StripControlChars,
+		NormalizeWhitespace: cfg.NormalizeWhitespace,
+		Lowercase:           cfg.Lowercase,
+	}
+}
+
+// Enabled 是否开启了任一预处理
+func (o PreprocessOptions) Enabled() bool {
+	return o.NormalizeUnicode || o.StripControlChars || o.NormalizeWhitespace || o.Lowercase
+}
+
+// PreprocessText 按选项处理文本，处理后为空时返回原文，避免向模型发送空输入
+func PreprocessText(text string, opts PreprocessOptions) string {
+	result := text
+	if opts.NormalizeUnicode {
+		result = norm.NFC.String(result)
+	}
+	if opts.StripControlChars {
+		result = strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) && r != '\n' && r != '\t' {
+				return -1
+			}
+			return r
+		}, result)
+	}
+	if opts.NormalizeWhitespace {
+		result = strings.Join(strings.Fields(result), " ")
+	}
+	if opts.Lowercase {
+		result = strings.ToLower(result)
+	}
+
+	if strings.TrimSpace(result) == "" {
+		return text
+	}
+	return result
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"doc-analysis-backend/config"
+)
+
+// SearchCache 检索结果的 LRU 缓存，条目超过 TTL 后失效。
+// 每个条目记录其检索范围内的文件，文件被重新处理或删除时使相关条目失效
+type SearchCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	order    *list.List // 最近使用的在前
+	entries  map[string]*list.Element
+}
+
+type searchCacheEntry struct {
+	key     string
+	value   interface{}
+	fileIDs map[string]struct{} // 为 nil 表示检索范围是所有文件
+	expires time.Time
+}
+
+func NewSearchCache(capacity int, ttl time.Duration) *SearchCache {
+	return &SearchCache{
+		capacity: capacity,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Get 返回未过期的缓存值
+func (sc *SearchCache) Get(key string) (interface{}, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	elem, ok := sc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*searchCacheEntry)
+	if time.Now().After(entry.expires) {
+		sc.remove(elem)
+		return nil, false
+	}
+	sc.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Set 写入缓存，fileIDs 为空表示检索范围是所有文件，超出容量时淘汰最久未使用的条目
+func (sc *SearchCache) Set(key string, value interface{}, fileIDs []string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	entry := &searchCacheEntry{
+		key:     key,
+		value:   value,
+		expires: time.Now().Add(sc.ttl),
+	}
+	if len(fileIDs) > 0 {
+		entry.fileIDs = make(map[string]struct{}, len(fileIDs))
+		for _, id := range fileIDs {
+			entry.fileIDs[id] = struct{}{}
+		}
+	}
+
+	if elem, ok := sc.entries[key]; ok {
+		elem.Value = entry
+		sc.order.MoveToFront(elem)
+		return
+	}
+	sc.entries[key] = sc.order.PushFront(entry)
+	for sc.order.Len() > sc.capacity {
+		sc.remove(sc.order.Back())
+	}
+}
+
+// InvalidateFile 删除检索范围包含该文件的条目（包括范围是所有文件的条目）
+func (sc *SearchCache) InvalidateFile(fileID string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	for elem := sc.order.Front(); elem != nil; {
+		next := elem.Next()
+		entry := elem.Value.(*searchCacheEntry)
+		if _, ok := entry.fileIDs[fileID]; ok || entry.fileIDs == nil {
+			sc.remove(elem)
+		}
+		elem = next
+	}
+}
+
+func (sc *SearchCache) remove(elem *list.Element) {
+	sc.order.Remove(elem)
+	delete(sc.entries, elem.Value.(*searchCacheEntry).key)
+}
+
+var (
+	searchCacheOnce sync.Once
+	searchCache     *SearchCache
+)
+
+// SearchResultCache 返回全局的检索结果缓存，SEARCH_CACHE_SIZE 为 0 时返回 nil
+func SearchResultCache() *SearchCache {
+	searchCacheOnce.Do(func() {
+		cfg := config.AppConfig.Search
+		if cfg.CacheSize > 0 && cfg.CacheTTL > 0 {
+			searchCache = NewSearchCache(cfg.CacheSize, cfg.CacheTTL)
+		}
+	})
+	return searchCache
+}
+
+// InvalidateSearchCache 文件的向量发生变化（重新处理、删除、移动集合等）时调用
+func InvalidateSearchCache(fileID string) {
+	if cache := SearchResultCache(); cache != nil {
+		cache.InvalidateFile(fileID)
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"doc-analysis-backend/config"
+)
+
+// InitTempDir 创建存放处理过程中临时文件的目录。
+// 同时将 TMPDIR 指向该目录，大文件上传时表单解析产生的临时文件也写到这里
+func InitTempDir() error {
+	dir := config.AppConfig.Upload.TempDir
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建临时目录 %s 失败: %w", dir, err)
+	}
+	os.Setenv("TMPDIR", dir)
+	log.Printf("临时目录: %s", dir)
+	return nil
+}
+
+// InitUploadDir 启动时检查上传目录可以写入，不可用时直接报错，而不是等到上传时才失败
+func InitUploadDir() error {
+	if err := EnsureUploadDir(); err != nil {
+		return err
+	}
+	dir := config.AppConfig.Upload.Dir
+	probe, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("上传目录 %s 不可写: %w", dir, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	log.Printf("上传目录: %s", dir)
+	return nil
+}
+
+// EnsureUploadDir 确保上传目录存在，UPLOAD_CREATE_DIR 关闭时只检查不创建
+func EnsureUploadDir() error {
+	cfg := config.AppConfig.Upload
+	if cfg.CreateDir {
+		if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+			return fmt.Errorf("创建上传目录 %s 失败: %w", cfg.Dir, err)
+		}
+		return nil
+	}
+
+	info, err := os.Stat(cfg.Dir)
+	if err != nil {
+		return fmt.Errorf("上传目录 %s 不可用: %w", cfg.Dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("上传目录 %s 不是目录", cfg.Dir)
+	}
+	return nil
+}
+
+// CreateTempFile 在临时目录中创建文件，调用方负责关闭，并在未移动到最终位置时删除
+func CreateTempFile(pattern string) (*os.File, error) {
+	file, err := os.CreateTemp(config.AppConfig.Upload.TempDir, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	return file, nil
+}
+
+// MoveFile 将临时文件移动到目标路径。临时目录与目标不在同一文件系统时无法直接重命名，
+// 改为复制后删除源文件；复制失败时不会留下不完整的目标文件
+func MoveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"doc-analysis-backend/config"
+)
+
+func withUploadDir(t *testing.T, dir string, createDir bool) {
+	t.Helper()
+	saved := config.AppConfig
+	cfg := &config.Config{}
+	cfg.Upload.Dir = dir
+	cfg.Upload.CreateDir = createDir
+	config.AppConfig = cfg
+	t.Cleanup(func() { config.AppConfig = saved })
+}
+
+// readOnlyDir 创建一个只读目录；以 root 运行时权限检查不生效，跳过测试
+func readOnlyDir(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "readonly")
+	if err := os.Mkdir(dir, 0555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(dir, 0755) })
+	if f, err := os.CreateTemp(dir, "probe-*"); err == nil {
+		f.Close()
+		t.Skip("directory permissions are not enforced for this user")
+	}
+	return dir
+}
+
+func TestInitUploadDir(t *testing.T) {
+	t.Run("creates missing directory", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "uploads", "nested")
+		withUploadDir(t, dir, true)
+		if err := InitUploadDir(); err != nil {
+			t.Fatalf("InitUploadDir: %v", err)
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			t.Fatalf("upload dir was not created: %v", err)
+		}
+		// 写入检查用的探测文件不应残留
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Fatalf("probe file left behind: %v", entries)
+		}
+	})
+
+	t.Run("missing directory without create", func(t *testing.T) {
+		withUploadDir(t, filepath.Join(t.TempDir(), "missing"), false)
+		if err := InitUploadDir(); err == nil || !strings.Contains(err.Error(), "不可用") {
+			t.Fatalf("err = %v, want upload dir unavailable", err)
+		}
+	})
+
+	t.Run("path is a file", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "file")
+		if err := os.WriteFile(file, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		withUploadDir(t, file, false)
+		if err := InitUploadDir(); err == nil || !strings.Contains(err.Error(), "不是目录") {
+			t.Fatalf("err = %v, want not a directory", err)
+		}
+		withUploadDir(t, file, true)
+		if err := InitUploadDir(); err == nil || !strings.Contains(err.Error(), "创建上传目录") {
+			t.Fatalf("err = %v, want create failure", err)
+		}
+	})
+
+	t.Run("read-only directory", func(t *testing.T) {
+		dir := readOnlyDir(t)
+		withUploadDir(t, dir, true)
+		if err := InitUploadDir(); err == nil || !strings.Contains(err.Error(), "不可写") {
+			t.Fatalf("err = %v, want not writable", err)
+		}
+	})
+}
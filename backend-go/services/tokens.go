@@ -0,0 +1,38 @@
+package services
+
+import (
+	"unicode"
+)
+
+// EstimateTokens 粗略估算文本的 token 数量:
+// 中日韩字符按每字 1 个 token 计算，其他字符按约 4 个字符 1 个 token 计算。
+// 只用于分批和成本估算，不追求与具体模型的分词结果完全一致。
+func EstimateTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+			unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// TruncateToTokens 截取文本开头估算 token 数不超过 maxTokens 的部分，估算方式与 EstimateTokens 相同
+func TruncateToTokens(text string, maxTokens int) string {
+	cjk, other := 0, 0
+	for i, r := range text {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+			unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			cjk++
+		} else {
+			other++
+		}
+		if cjk+(other+3)/4 > maxTokens {
+			return text[:i]
+		}
+	}
+	return text
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"io"
+	"log"
+	"os"
+
+	"doc-analysis-backend/config"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// InitLogger 根据配置设置日志输出位置: stdout（默认）、file 或 both。
+// 写入文件时按大小切割，并按保留天数/份数清理旧日志。
+func InitLogger() {
+	cfg := config.AppConfig.Log
+
+	var writer io.Writer
+	switch cfg.Output {
+	case "stdout", "":
+		return
+	case "file", "both":
+		fileWriter := &lumberjack.Logger{
+			Filename:   cfg.File,
+			MaxSize:    cfg.MaxSizeMB,
+			MaxBackups: cfg.MaxBackups,
+			MaxAge:     cfg.MaxAgeDays,
+			Compress:   cfg.Compress,
+			LocalTime:  true,
+		}
+		writer = fileWriter
+		if cfg.Output == "both" {
+			writer = io.MultiWriter(os.Stdout, fileWriter)
+		}
+	default:
+		log.Fatalf("不支持的日志输出方式: %s (可选 stdout/file/both)", cfg.Output)
+	}
+
+	log.SetOutput(writer)
+	gin.DefaultWriter = writer
+	gin.DefaultErrorWriter = writer
+
+	log.Printf("日志输出到: %s", cfg.File)
+}
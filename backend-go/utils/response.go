@@ -2,6 +2,7 @@ package utils
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -39,6 +40,44 @@ func BadRequest(c *gin.Context, message string) {
 	Error(c, http.StatusBadRequest, message)
 }
 
+// FieldError 单个字段的校验错误，field 为请求中的字段名，如 query、files[0]
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors 收集请求中各字段的校验错误，与字段无关的错误仍使用 BadRequest
+type ValidationErrors []FieldError
+
+func (e *ValidationErrors) Add(field, message string) {
+	*e = append(*e, FieldError{Field: field, Message: message})
+}
+
+// Check 条件不成立时记录错误，返回条件本身
+func (e *ValidationErrors) Check(ok bool, field, message string) bool {
+	if !ok {
+		e.Add(field, message)
+	}
+	return ok
+}
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// ValidationFailed 返回 400，message 为所有错误的汇总，data.errors 中按字段列出错误，前端可据此标记对应的表单项
+func ValidationFailed(c *gin.Context, errs ValidationErrors) {
+	c.JSON(http.StatusBadRequest, Response{
+		Code:    http.StatusBadRequest,
+		Message: errs.Error(),
+		Data:    map[string]interface{}{"errors": errs},
+	})
+}
+
 func NotFound(c *gin.Context, message string) {
 	Error(c, http.StatusNotFound, message)
 }
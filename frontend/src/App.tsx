@@ -124,7 +124,7 @@ function App() {
     ['uploading', 'parsing', 'chunking', 'embedding', 'storing'].includes(f.status)
   );
   const completedFiles = (files || []).filter(f => f.status === 'completed');
-  const errorFiles = (files || []).filter(f => f.status === 'error');
+  const errorFiles = (files || []).filter(f => f.status === 'error' || f.status === 'failed_permanent');
 
   return (
     <div className="min-h-screen bg-gray-50 dark:bg-gray-900 py-8">
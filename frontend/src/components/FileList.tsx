@@ -77,7 +77,7 @@ const FileList: React.FC<FileListProps> = ({ files, onDeleteFile }) => {
     files.find(f => f.id === selectedFile)?.filename || '' : '';
 
   const getProgressColor = (status: string, progress: number) => {
-    if (status === 'error') return 'bg-red-500';
+    if (status === 'error' || status === 'failed_permanent') return 'bg-red-500';
     if (status === 'completed') return 'bg-green-500';
     if (progress > 0) return 'bg-blue-500';
     return 'bg-gray-300';
@@ -2,7 +2,7 @@
 export interface FileInfo {
   id: string;
   filename: string;
-  status: 'pending' | 'uploading' | 'parsing' | 'chunking' | 'embedding' | 'storing' | 'completed' | 'error';
+  status: 'pending' | 'uploading' | 'parsing' | 'chunking' | 'embedding' | 'storing' | 'completed' | 'error' | 'failed_permanent';
   progress: number;
   message: string;
   updated_at: string;